@@ -42,6 +42,12 @@ func CreateAWSSecretsManagerSecret(namespace, name, secret string) error {
 	return err
 }
 
+// LocalstackEndpoint returns the endpoint of the localstack instance deployed
+// into the given namespace
+func LocalstackEndpoint(namespace string) string {
+	return fmt.Sprintf("http://localstack.%s", namespace)
+}
+
 // localResolver resolves endpoints to
 type localResolver struct {
 	endpoints.Resolver
@@ -51,6 +57,6 @@ type localResolver struct {
 // ResolveEndpoint resolves custom endpoints if provided
 func (r *localResolver) ResolveEndpoint(service, region string) (aws.Endpoint, error) {
 	return aws.Endpoint{
-		URL: fmt.Sprintf("http://localstack.%s", r.namespace),
+		URL: LocalstackEndpoint(r.namespace),
 	}, nil
 }
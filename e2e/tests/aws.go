@@ -60,6 +60,10 @@ var _ = ginkgo.Describe("[aws]", func() {
 			Spec: smv1alpha1.SecretStoreSpec{
 				AWS: &smv1alpha1.AWSStore{
 					Region: smmeta.String("us-east-1"),
+					Endpoints: &smv1alpha1.AWSEndpoints{
+						SecretsManager: smmeta.String(framework.LocalstackEndpoint(f.Namespace)),
+						STS:            smmeta.String(framework.LocalstackEndpoint(f.Namespace)),
+					},
 				},
 			},
 		}
@@ -72,3 +72,24 @@ type SecretKeySelector struct {
 	// +optional
 	Key string `json:"key,omitempty"`
 }
+
+// A reference to a specific 'key' within a ConfigMap resource.
+type ConfigMapKeySelector struct {
+	// The name of the ConfigMap resource being referred to.
+	LocalObjectReference `json:",inline"`
+	// The key of the entry in the ConfigMap resource's `data` field to be used.
+	Key string `json:"key"`
+}
+
+// A reference to a ServiceAccount resource.
+type ServiceAccountSelector struct {
+	// The name of the ServiceAccount resource being referred to.
+	Name string `json:"name"`
+	// Namespace of the resource being referred to. Ignored if referent is not cluster-scoped. cluster-scoped defaults
+	// to the namespace of the referent.
+	// +optional
+	Namespace *string `json:"namespace,omitempty"`
+	// Audiences of the tokens requested for the ServiceAccount. Some instances of this field may be defaulted.
+	// +optional
+	Audiences []string `json:"audiences,omitempty"`
+}
@@ -19,8 +19,10 @@ import "time"
 const (
 	DefaultRenewalLeeway = time.Second * 30
 	DefaultSecretKey     = "secret"
+	DefaultFindSeparator = "_"
 
 	DefaultVaultAppRoleAuthMountPath    = "approle"
 	DefaultVaultKubernetesAuthMountPath = "kubernetes"
+	DefaultVaultTransitMountPath        = "transit"
 	DefaultVaultKVEngineVersion         = VaultKVStoreV2
 )
@@ -46,6 +46,30 @@ type ExternalSecretSpec struct {
 	// DataFrom references a map of secrets to embed within the generated secret.
 	// +optional
 	DataFrom []RemoteReference `json:"dataFrom,omitempty"`
+
+	// CustomMetadata configures which custom metadata of the referenced remote
+	// secrets is set on the generated secret. Only supported by SecretStores
+	// which store custom metadata alongside secrets, e.g. the Vault KV v2 engine.
+	// Custom metadata of secrets fetched with a Find reference is not mapped.
+	// Values mapped to labels must be valid label values.
+	// +optional
+	CustomMetadata *CustomMetadataMapping `json:"customMetadata,omitempty"`
+}
+
+// CustomMetadataMapping maps custom metadata keys of remote secrets to labels
+// and annotations of the generated secret. If multiple remote secrets hold the
+// same custom metadata key, the value of the last reference is used, with
+// Data references taking precedence over DataFrom references.
+type CustomMetadataMapping struct {
+	// Labels maps label keys of the generated secret to custom metadata keys
+	// of the remote secrets.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations maps annotation keys of the generated secret to custom
+	// metadata keys of the remote secrets.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 // ObjectReference is a reference to an object with a given name, kind and group.
@@ -75,14 +99,71 @@ type RemoteReference struct {
 
 	// Property to extract secret value at path in the SecretStore.
 	// Can be omitted if not supported by SecretStore or if entire secret should
-	// be fetched as in dataFrom reference.
+	// be fetched as in dataFrom reference. In a dataFrom reference to a secret
+	// which holds a single value rather than a map, e.g. a plain text AWS
+	// Secrets Manager secret, Property sets the key of the value in the
+	// generated secret, defaulting to "secret", or to the secret id for GCP
+	// SecretStores.
 	// +optional
 	Property *string `json:"property,omitempty"`
 
 	// Version of the secret to fetch from the SecretStore. Must be a supported parameter
-	// by the referenced SecretStore.
+	// by the referenced SecretStore. AWS SecretStores select a version by
+	// staging label, or by VersionId if the version is prefixed with "uuid/".
+	// Parameter Store SecretStores select a version by number or by label.
+	// GCP SecretStores select a version by number or by alias, defaulting to
+	// "latest", and report the number of the version the alias resolved to.
 	// +optional
 	Version *string `json:"version,omitempty"`
+
+	// Transit decrypts ciphertext with the Vault Transit secrets engine key
+	// given by Name, instead of reading the secret at Name. Only supported by
+	// Vault SecretStores.
+	// +optional
+	Transit *VaultTransitReference `json:"transit,omitempty"`
+
+	// Find fetches every secret found below the path given by Name instead
+	// of the single secret at Name. AWS SecretStores find the secrets whose
+	// name starts with Name and merge their keys into the generated secret.
+	// GCP SecretStores find the latest enabled versions of the secrets whose
+	// id starts with Name and set them keyed by secret id.
+	// Only supported in dataFrom references.
+	// +optional
+	Find *FindReference `json:"find,omitempty"`
+}
+
+// FindReference configures how multiple secrets are found in the SecretStore
+// and how their keys are flattened into keys of the generated secret.
+type FindReference struct {
+	// Recursive finds secrets in nested paths below Name as well.
+	// +optional
+	Recursive bool `json:"recursive,omitempty"`
+
+	// MaxDepth limits the number of nested path levels below Name which are
+	// searched when Recursive is set. Unlimited if not set.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxDepth *int32 `json:"maxDepth,omitempty"`
+
+	// Separator is used to join the path of a found secret relative to Name
+	// and its keys into keys of the generated secret. Defaults to "_".
+	// +optional
+	Separator *string `json:"separator,omitempty"`
+
+	// Tags limits the found secrets to those having all of the given tags.
+	// Only supported by AWS SecretStores.
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Labels limits the found secrets to those having all of the given
+	// labels. Only supported by GCP SecretStores.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// NameRegex limits the found secrets to those whose id matches the
+	// regular expression. Only supported by GCP SecretStores.
+	// +optional
+	NameRegex *string `json:"nameRegex,omitempty"`
 }
 
 // ExternalSecretStatus defines the observed state of ExternalSecret
@@ -90,6 +171,21 @@ type ExternalSecretStatus struct {
 	// List of status conditions to indicate the status of ExternalSecret.
 	// Known condition types are `Ready`.
 	smmeta.ConditionedStatus `json:",inline"`
+
+	// RemoteVersions lists the versions of the remote secrets fetched during
+	// the last successful sync. Only populated for SecretStores which report
+	// the version of fetched secrets.
+	// +optional
+	RemoteVersions []RemoteVersion `json:"remoteVersions,omitempty"`
+}
+
+// RemoteVersion is the version of a remote secret fetched from a SecretStore.
+type RemoteVersion struct {
+	// Name of the key, path, or id in the SecretStore.
+	Name string `json:"name"`
+
+	// Version of the secret fetched from the SecretStore.
+	Version string `json:"version"`
 }
 
 // +kubebuilder:object:root=true
@@ -14,21 +14,106 @@ limitations under the License.
 
 package v1alpha1
 
-import smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+import (
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
 
-// Configures an store to sync secrets using AWS SecretManager
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Configures an store to sync secrets using AWS SecretManager.
+// Secrets holding a JSON object can be referenced by property, which may be
+// a dot separated path to a nested value, e.g. "db.primary.password". Values
+// which are not strings are returned as JSON.
+// The version of a reference selects the secret version by staging label,
+// e.g. "AWSCURRENT", or by VersionId if prefixed with "uuid/", e.g.
+// "uuid/EXAMPLE1-90ab-cdef-fedc-ba987SECRET1".
 type AWSStore struct {
 	// Region configures the region to send requests to.
 	// +optional
 	Region *string `json:"region,omitempty"`
+	// Endpoints overrides the endpoints of AWS services, e.g. to use VPC or
+	// FIPS endpoints, or a local stand-in like LocalStack.
+	// +optional
+	Endpoints *AWSEndpoints `json:"endpoints,omitempty"`
 	// Auth configures how secret-manager authenticates with AWS.
 	// +optional
 	AuthSecretRef *AWSAuth `json:"authSecretRef,omitempty"`
+	// JWTAuth authenticates with AWS using web identity federation (IRSA) with
+	// a token requested for a Kubernetes ServiceAccount.
+	// Mutually exclusive with AuthSecretRef.
+	// +optional
+	JWTAuth *AWSJWTAuth `json:"jwtAuth,omitempty"`
+	// AssumeRoles is a chain of roles assumed in order, each using the
+	// credentials of the previous role. The first role is assumed with the
+	// credentials given by AuthSecretRef or JWTAuth, or the inferred
+	// credentials from environment variables, shared credentials file or AWS
+	// Instance metadata.
+	// +optional
+	AssumeRoles []AWSAssumeRole `json:"assumeRoles,omitempty"`
+}
+
+// AWSEndpoints are the URLs of the AWS service endpoints used by a store.
+// Services without an endpoint use the default endpoint of the region.
+type AWSEndpoints struct {
+	// SecretsManager is the URL of the Secrets Manager endpoint.
+	// +optional
+	SecretsManager *string `json:"secretsManager,omitempty"`
+	// STS is the URL of the STS endpoint used to assume roles.
+	// +optional
+	STS *string `json:"sts,omitempty"`
+	// SSM is the URL of the Systems Manager endpoint.
+	// +optional
+	SSM *string `json:"ssm,omitempty"`
+}
+
+// AWSJWTAuth authenticates with AWS by exchanging a token requested for a
+// Kubernetes ServiceAccount for the credentials of an IAM role, as done by
+// IAM Roles for Service Accounts (IRSA) on EKS.
+type AWSJWTAuth struct {
+	// ServiceAccountRef is the ServiceAccount a token is requested for. The
+	// audiences of the token default to "sts.amazonaws.com". The namespace
+	// must be specified for a ClusterSecretStore.
+	ServiceAccountRef smmeta.ServiceAccountSelector `json:"serviceAccountRef"`
+	// RoleARN is the ARN of the IAM role trusting the cluster OIDC provider.
+	// Defaults to the role given by the "eks.amazonaws.com/role-arn"
+	// annotation of the ServiceAccount.
+	// +optional
+	RoleARN *string `json:"roleARN,omitempty"`
+	// SessionName is the name of the role session. Defaults to a name
+	// generated by the AWS SDK.
+	// +optional
+	SessionName *string `json:"sessionName,omitempty"`
+}
+
+// AWSAssumeRole configures an IAM role assumed with STS AssumeRole.
+type AWSAssumeRole struct {
+	// RoleARN is the ARN of the IAM role to assume.
+	RoleARN string `json:"roleARN"`
+	// ExternalID is passed to STS if set, as required by the trust policy of
+	// some cross account roles.
+	// +optional
+	ExternalID *string `json:"externalID,omitempty"`
+	// SessionName is the name of the role session. Defaults to a name
+	// generated by the AWS SDK.
+	// +optional
+	SessionName *string `json:"sessionName,omitempty"`
+	// SessionDuration is the duration of the role session. Defaults to 15
+	// minutes.
+	// +optional
+	SessionDuration *metav1.Duration `json:"sessionDuration,omitempty"`
+	// SessionTags are passed as session tags to STS.
+	// +optional
+	SessionTags map[string]string `json:"sessionTags,omitempty"`
+	// TransitiveTagKeys are the keys of the session tags passed on to roles
+	// assumed later in the chain.
+	// +optional
+	TransitiveTagKeys []string `json:"transitiveTagKeys,omitempty"`
 }
 
 // Configuration used to authenticate with AWS.
 // Any of `AccessKeyID`, `SecretAccessKey` or `Role` can be specified. If not set we fall-back to using env vars, shared
 // credentials file or AWS Instance metadata
+// The Secrets referenced by a ClusterSecretStore must specify their namespace.
 type AWSAuth struct {
 	// The AccessKeyID is used for authentication. If not set we fall-back to using env vars, shared credentials file
 	// or AWS Instance metadata
@@ -0,0 +1,74 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+
+// AzureKVStore configures a store to sync secrets, keys and certificates
+// using Azure Key Vault.
+// The name of a reference is the name of a Key Vault object, prefixed by its
+// type as in "secret/db-password", "key/signing" or "cert/ingress". Names
+// without prefix refer to secrets. The version of a reference is the version
+// id of the object, defaulting to the current version.
+// The property of a secret reference selects a field of a JSON value, using
+// dots to descend into nested objects. Keys are exported as PEM encoded
+// public keys, or as JSON Web Keys with the property "jwk". Certificates are
+// exported as PEM encoded certificate chain followed by the private key, or
+// as PKCS#12 archive without password with the property "pfx". In dataFrom, certificates are split into the keys "tls.crt" and "tls.key".
+type AzureKVStore struct {
+	// VaultURL is the URL of the vault, e.g. https://my-vault.vault.azure.net.
+	VaultURL string `json:"vaultURL"`
+	// TenantID is the id of the Azure AD tenant of the service principal.
+	// Defaults to the "azure.workload.identity/tenant-id" annotation of the
+	// ServiceAccount with WorkloadIdentity.
+	// +optional
+	TenantID *string `json:"tenantID,omitempty"`
+	// AuthorityHost is the Azure AD endpoint issuing access tokens. Defaults
+	// to https://login.microsoftonline.com/.
+	// +optional
+	AuthorityHost *string `json:"authorityHost,omitempty"`
+	// AuthSecretRef authenticates as a service principal with a client secret.
+	// +optional
+	AuthSecretRef *AzureKVAuth `json:"authSecretRef,omitempty"`
+	// WorkloadIdentity authenticates with a token requested for a Kubernetes
+	// ServiceAccount, as done by Azure Workload Identity. Mutually exclusive
+	// with AuthSecretRef.
+	// +optional
+	WorkloadIdentity *AzureWorkloadIdentity `json:"workloadIdentity,omitempty"`
+}
+
+// AzureKVAuth references the credentials of an Azure AD service principal.
+// The namespace of the referenced secrets must be specified for a
+// ClusterSecretStore.
+type AzureKVAuth struct {
+	// ClientID is the application id of the service principal.
+	ClientID smmeta.SecretKeySelector `json:"clientID"`
+	// ClientSecret is a client secret of the service principal.
+	ClientSecret smmeta.SecretKeySelector `json:"clientSecret"`
+}
+
+// AzureWorkloadIdentity authenticates with Azure AD using a token of a
+// Kubernetes ServiceAccount trusted by a federated identity credential.
+type AzureWorkloadIdentity struct {
+	// ServiceAccountRef is the ServiceAccount a token is requested for. The
+	// namespace must be specified for a ClusterSecretStore. The audience of
+	// the token defaults to "api://AzureADTokenExchange".
+	ServiceAccountRef smmeta.ServiceAccountSelector `json:"serviceAccountRef"`
+	// ClientID is the application id of the identity federated with the
+	// ServiceAccount. Defaults to the "azure.workload.identity/client-id"
+	// annotation of the ServiceAccount.
+	// +optional
+	ClientID *string `json:"clientID,omitempty"`
+}
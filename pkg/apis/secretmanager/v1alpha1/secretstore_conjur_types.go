@@ -0,0 +1,68 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+
+// ConjurStore configures a store to sync secrets using CyberArk Conjur.
+// The name of a reference is the id of a variable, e.g. "prod/db/password",
+// and the version selects a previous version of its value, defaulting to the
+// latest. The property selects a field of a JSON value, using dots to descend
+// into nested objects. In dataFrom, the fields of the JSON value of the
+// variable are synced, or a single key for other values, or with find all
+// variables whose id starts with the name, keyed by their id relative to the
+// name with "/" replaced by the find separator.
+type ConjurStore struct {
+	// URL is the URL of the Conjur appliance, e.g. https://conjur.example.com.
+	URL string `json:"url"`
+	// Account is the Conjur organization account of the variables.
+	Account string `json:"account"`
+	// CABundle is the PEM encoded CA bundle verifying the Conjur appliance.
+	// Defaults to the system roots.
+	// +optional
+	CABundle []byte `json:"caBundle,omitempty"`
+	// AuthSecretRef authenticates a user or host with its API key.
+	// +optional
+	AuthSecretRef *ConjurAuth `json:"authSecretRef,omitempty"`
+	// JWT authenticates with a token requested for a Kubernetes
+	// ServiceAccount using the JWT authenticator. Mutually exclusive with
+	// AuthSecretRef.
+	// +optional
+	JWT *ConjurJWT `json:"jwt,omitempty"`
+}
+
+// ConjurAuth references the credentials of a Conjur user or host. The
+// namespace of the referenced secrets must be specified for a
+// ClusterSecretStore.
+type ConjurAuth struct {
+	// Login is the login of the user or host, e.g. "host/secret-manager".
+	Login smmeta.SecretKeySelector `json:"login"`
+	// APIKey is the API key of the user or host.
+	APIKey smmeta.SecretKeySelector `json:"apiKey"`
+}
+
+// ConjurJWT configures the JWT authenticator of Conjur.
+type ConjurJWT struct {
+	// ServiceID is the id of the JWT authenticator, as in
+	// "authn-jwt/<service id>".
+	ServiceID string `json:"serviceID"`
+	// HostID is the id of the host authenticated with the token. Defaults to
+	// the host resolved by the authenticator from the token claims.
+	// +optional
+	HostID *string `json:"hostID,omitempty"`
+	// ServiceAccountRef is the ServiceAccount a token is requested for. The
+	// namespace must be specified for a ClusterSecretStore. The audience of
+	// the token must match the audience configured for the authenticator.
+	ServiceAccountRef smmeta.ServiceAccountSelector `json:"serviceAccountRef"`
+}
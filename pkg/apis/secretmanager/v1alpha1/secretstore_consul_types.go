@@ -0,0 +1,50 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+
+// ConsulStore configures a store to sync secrets using the HashiCorp Consul
+// KV store.
+// The name of a reference is a key, and the property selects a field of its
+// JSON value, using dots to descend into nested objects. In dataFrom, the
+// name is a key prefix whose keys are synced recursively, keyed by their
+// path relative to the prefix with "/" replaced by the find separator.
+// Versions are not supported.
+type ConsulStore struct {
+	// Server is the URL of the Consul HTTP API, e.g. https://consul.example.com:8501.
+	Server string `json:"server"`
+	// Datacenter of the keys. Defaults to the datacenter of the agent.
+	// +optional
+	Datacenter *string `json:"datacenter,omitempty"`
+	// Namespace of the keys. Only supported by Consul Enterprise.
+	// +optional
+	Namespace *string `json:"namespace,omitempty"`
+	// CABundle is the PEM encoded CA bundle verifying the Consul server.
+	// Defaults to the system roots.
+	// +optional
+	CABundle []byte `json:"caBundle,omitempty"`
+	// AuthSecretRef configures the ACL token of secret-manager. Requests are
+	// anonymous if not set.
+	// +optional
+	AuthSecretRef *ConsulAuth `json:"authSecretRef,omitempty"`
+}
+
+// ConsulAuth references the ACL token used to read keys.
+type ConsulAuth struct {
+	// Token references the ACL token in a Secret. The namespace must be
+	// specified for a ClusterSecretStore.
+	Token smmeta.SecretKeySelector `json:"token"`
+}
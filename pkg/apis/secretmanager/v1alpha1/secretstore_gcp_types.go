@@ -17,13 +17,63 @@ package v1alpha1
 import smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
 
 // Configures an store to sync secrets using GCP Secret Manager
+// The property of a reference selects a field of a JSON payload, using dots
+// to descend into nested objects as in "db.primary.password".
 type GCPStore struct {
 	// ProjectID is a convenience string to allow the shortening of secret paths.
-	// When set, the prefix projects/<ProjectID> can be removed from the name
+	// When set, the prefix projects/<ProjectID> can be removed from the name.
+	// Secrets in other projects are referenced by their full resource name,
+	// e.g. projects/<project>/secrets/<secret>, optionally followed by
+	// /versions/<version>.
 	ProjectID *string `json:"projectID,omitempty"`
+	// Location of the regional secrets of the store, e.g. "europe-west1".
+	// Secrets are read from the regional endpoint of the location, and the
+	// full resource names of secrets must include the location. Global
+	// secrets are read if not set.
+	// +optional
+	Location *string `json:"location,omitempty"`
 	// Auth configures how secret-manager authenticates with GCP Secret Manager.
 	// +optional
 	AuthSecretRef *GCPAuth `json:"authSecretRef,omitempty"`
+	// WorkloadIdentity authenticates with GCP by exchanging a token requested
+	// for a Kubernetes ServiceAccount, as done by GKE Workload Identity.
+	// Mutually exclusive with AuthSecretRef.
+	// +optional
+	WorkloadIdentity *GCPWorkloadIdentity `json:"workloadIdentity,omitempty"`
+	// Impersonate configures a GCP service account impersonated with the
+	// credentials given by AuthSecretRef or WorkloadIdentity, or the default
+	// credentials.
+	// +optional
+	Impersonate *GCPImpersonation `json:"impersonate,omitempty"`
+}
+
+// GCPWorkloadIdentity authenticates with GCP using a token of a Kubernetes
+// ServiceAccount, issued by a GKE cluster with Workload Identity enabled.
+type GCPWorkloadIdentity struct {
+	// ServiceAccountRef is the ServiceAccount a token is requested for. The
+	// namespace must be specified for a ClusterSecretStore. If the
+	// ServiceAccount is annotated with "iam.gke.io/gcp-service-account", that
+	// GCP service account is impersonated unless Impersonate is set.
+	ServiceAccountRef smmeta.ServiceAccountSelector `json:"serviceAccountRef"`
+	// ClusterProjectID is the project of the GKE cluster. Defaults to ProjectID.
+	// +optional
+	ClusterProjectID *string `json:"clusterProjectID,omitempty"`
+	// ClusterLocation is the region or zone of the GKE cluster.
+	ClusterLocation string `json:"clusterLocation"`
+	// ClusterName is the name of the GKE cluster.
+	ClusterName string `json:"clusterName"`
+}
+
+// GCPImpersonation configures the GCP service account whose short-lived
+// credentials are used to access Secret Manager.
+type GCPImpersonation struct {
+	// ServiceAccount is the email of the impersonated service account.
+	ServiceAccount string `json:"serviceAccount"`
+	// Delegates are the emails of the service accounts in the delegation
+	// chain, each having the Service Account Token Creator role on the next,
+	// the last on ServiceAccount.
+	// +optional
+	Delegates []string `json:"delegates,omitempty"`
 }
 
 // Configuration used to authenticate with GCP.
@@ -0,0 +1,60 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+
+// GitLabStore configures a store to sync secrets from GitLab CI/CD variables
+// of a project and its groups.
+// The name of a reference is the key of a variable, and the property selects
+// a field of its JSON value, using dots to descend into nested objects.
+// Project variables take precedence over group variables. Of variables with
+// the same key, the one whose environment scope matches Environment most
+// specifically is used: an exact match over a wildcard scope such as
+// "review/*", over the "*" scope. In dataFrom, all variables whose key starts
+// with the name are synced, keyed by variable key. Versions are not
+// supported.
+type GitLabStore struct {
+	// URL is the URL of the GitLab instance. Defaults to https://gitlab.com.
+	// +optional
+	URL *string `json:"url,omitempty"`
+	// ProjectID is the id or full path of the project, e.g. "my-group/my-app".
+	// +optional
+	ProjectID *string `json:"projectID,omitempty"`
+	// GroupIDs are the ids or full paths of groups whose variables are
+	// synced, in order of priority.
+	// +optional
+	GroupIDs []string `json:"groupIDs,omitempty"`
+	// DisableGroupInheritance only syncs the variables of the project, not
+	// those of the groups it belongs to. Inherited variables take precedence
+	// over GroupIDs, and variables of subgroups over those of their parent
+	// groups.
+	// +optional
+	DisableGroupInheritance bool `json:"disableGroupInheritance,omitempty"`
+	// Environment is matched against the environment scope of variables.
+	// Only variables of the "*" scope are synced if not set.
+	// +optional
+	Environment *string `json:"environment,omitempty"`
+	// AuthSecretRef configures the access token of secret-manager.
+	AuthSecretRef GitLabAuth `json:"authSecretRef"`
+}
+
+// GitLabAuth references a GitLab access token with the read_api scope. The
+// token must have at least the Maintainer role in the project and groups to
+// read their CI/CD variables.
+type GitLabAuth struct {
+	// AccessToken references the personal, group or project access token in
+	// a Secret. The namespace must be specified for a ClusterSecretStore.
+	AccessToken smmeta.SecretKeySelector `json:"accessToken"`
+}
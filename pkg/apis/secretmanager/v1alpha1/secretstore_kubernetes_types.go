@@ -0,0 +1,67 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+
+// KubernetesStore configures a store to sync secrets from Secrets of the
+// cluster secret-manager runs in, or of a remote cluster.
+// The name of a reference is the name of a Secret in the remote namespace,
+// and the property selects one of its keys. In dataFrom, all keys of the
+// Secret are synced. Versions are not supported.
+type KubernetesStore struct {
+	// RemoteNamespace is the namespace of the Secrets. Defaults to the
+	// namespace of the SecretStore, or to the namespace of the ExternalSecret
+	// for a ClusterSecretStore. A SecretStore without AuthSecretRef can only
+	// read Secrets in its own namespace.
+	// +optional
+	RemoteNamespace *string `json:"remoteNamespace,omitempty"`
+	// Server is the API server of the remote cluster authenticated with a
+	// token. Not used with a kubeconfig, which configures the server itself.
+	// +optional
+	Server *KubernetesServer `json:"server,omitempty"`
+	// AuthSecretRef configures the credentials of the remote cluster. The
+	// cluster secret-manager runs in is read with its own credentials if not
+	// set.
+	// +optional
+	AuthSecretRef *KubernetesAuth `json:"authSecretRef,omitempty"`
+}
+
+// KubernetesServer configures the connection to a Kubernetes API server.
+type KubernetesServer struct {
+	// URL of the API server, e.g. https://kubernetes.example.com:6443.
+	URL string `json:"url"`
+	// CABundle is the PEM encoded CA bundle verifying the API server.
+	// +optional
+	CABundle []byte `json:"caBundle,omitempty"`
+	// CARef references the PEM encoded CA bundle verifying the API server in
+	// a Secret. The namespace must be specified for a ClusterSecretStore.
+	// +optional
+	CARef *smmeta.SecretKeySelector `json:"caRef,omitempty"`
+}
+
+// KubernetesAuth references the credentials of a remote cluster in Secrets.
+// Exactly one of Kubeconfig or Token must be specified. The namespace of the
+// referenced secrets must be specified for a ClusterSecretStore.
+type KubernetesAuth struct {
+	// Kubeconfig references a kubeconfig whose current context is used. Only
+	// the server, inline CA data, token and client certificate data are
+	// supported; file paths, exec and auth-provider plugins are rejected.
+	// +optional
+	Kubeconfig *smmeta.SecretKeySelector `json:"kubeconfig,omitempty"`
+	// Token references a bearer token authenticating with Server.
+	// +optional
+	Token *smmeta.SecretKeySelector `json:"token,omitempty"`
+}
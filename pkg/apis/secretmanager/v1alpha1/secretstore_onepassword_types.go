@@ -0,0 +1,43 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+
+// OnePasswordStore configures a store to sync secrets using a 1Password
+// Connect server.
+// The name of a reference is the title of an item, looked up in the vaults in
+// order of priority. The property selects a field of the item by label, or a
+// file attachment by name, defaulting to the field labeled "password". In
+// dataFrom, all fields and file attachments of the item are synced, keyed by
+// label and file name. Versions are not supported.
+type OnePasswordStore struct {
+	// ConnectHost is the URL of the 1Password Connect server, e.g.
+	// http://onepassword-connect:8080.
+	ConnectHost string `json:"connectHost"`
+	// Vaults are the names of the vaults searched for items, in order of
+	// priority.
+	// +kubebuilder:validation:MinItems=1
+	Vaults []string `json:"vaults"`
+	// AuthSecretRef configures the access token of the Connect server.
+	AuthSecretRef OnePasswordAuth `json:"authSecretRef"`
+}
+
+// OnePasswordAuth references the access token of a 1Password Connect server.
+type OnePasswordAuth struct {
+	// ConnectToken references the access token in a Secret. The namespace
+	// must be specified for a ClusterSecretStore.
+	ConnectToken smmeta.SecretKeySelector `json:"connectToken"`
+}
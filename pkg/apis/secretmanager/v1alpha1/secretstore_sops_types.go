@@ -0,0 +1,83 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+
+// SopsStore configures a store to sync secrets from SOPS encrypted files of
+// a Git repository.
+// The name of a reference is the path of a file in the repository, e.g.
+// "apps/prod/secrets.yaml". YAML and JSON files are decrypted into a
+// document, and the property selects a value of the document, using dots to
+// descend into nested objects and arrays. Without property, the whole
+// decrypted document is synced. Files of other types are decrypted as
+// binary files holding a single value. In dataFrom, the top-level values of
+// the document are synced. The commit the file was read from is reported as
+// version. Versions are not supported in references.
+type SopsStore struct {
+	// Git configures the repository holding the encrypted files.
+	Git SopsGitRepository `json:"git"`
+	// Keys references the private keys decrypting the files.
+	Keys SopsKeys `json:"keys"`
+}
+
+// SopsGitRepository configures how a Git repository is fetched.
+type SopsGitRepository struct {
+	// URL of the repository, e.g. https://github.com/org/repo.git or
+	// ssh://git@github.com/org/repo.git.
+	URL string `json:"url"`
+	// Ref pins the branch, tag or full commit hash files are read from.
+	// Defaults to the default branch of the repository.
+	// +optional
+	Ref *string `json:"ref,omitempty"`
+	// AuthSecretRef configures the credentials of the repository. Public
+	// repositories are fetched anonymously if not set.
+	// +optional
+	AuthSecretRef *SopsGitAuth `json:"authSecretRef,omitempty"`
+}
+
+// SopsGitAuth references the credentials of a Git repository, either a
+// password or access token for HTTPS, or a private key for SSH. The namespace
+// of the referenced secrets must be specified for a ClusterSecretStore.
+type SopsGitAuth struct {
+	// Username of HTTPS or SSH authentication. Defaults to "git", or to the
+	// user of an SSH URL.
+	// +optional
+	Username *smmeta.SecretKeySelector `json:"username,omitempty"`
+	// Password or access token for HTTPS authentication.
+	// +optional
+	Password *smmeta.SecretKeySelector `json:"password,omitempty"`
+	// SSHPrivateKey is the PEM encoded private key for SSH authentication.
+	// Mutually exclusive with Password.
+	// +optional
+	SSHPrivateKey *smmeta.SecretKeySelector `json:"sshPrivateKey,omitempty"`
+	// KnownHosts holds the host keys of the SSH server in known_hosts
+	// format. Required for SSH authentication.
+	// +optional
+	KnownHosts *smmeta.SecretKeySelector `json:"knownHosts,omitempty"`
+}
+
+// SopsKeys references the private keys SOPS data keys are encrypted for. Files
+// with several key groups, whose data key is split by shamir secret sharing,
+// are not supported. The namespace of the referenced secrets must be
+// specified for a ClusterSecretStore.
+type SopsKeys struct {
+	// Age references age identities, one AGE-SECRET-KEY per line.
+	// +optional
+	Age *smmeta.SecretKeySelector `json:"age,omitempty"`
+	// PGP references ASCII armored PGP private keys, which must not be
+	// protected by a passphrase.
+	// +optional
+	PGP *smmeta.SecretKeySelector `json:"pgp,omitempty"`
+}
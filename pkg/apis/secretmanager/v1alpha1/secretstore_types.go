@@ -29,9 +29,40 @@ type SecretStoreSpec struct {
 	// AWS configures this store to sync secrets using AWS SecretManager
 	// +optional
 	AWS *AWSStore `json:"aws,omitempty"`
+	// ParameterStore configures this store to sync secrets using AWS Systems
+	// Manager Parameter Store. Authentication is configured as for AWS.
+	// +optional
+	ParameterStore *AWSStore `json:"parameterStore,omitempty"`
 	// GCP configures this store to sync secrets using GCP Secret Manager
 	// +optional
 	GCP *GCPStore `json:"gcp,omitempty"`
+	// AzureKV configures this store to sync secrets, keys and certificates
+	// using Azure Key Vault.
+	// +optional
+	AzureKV *AzureKVStore `json:"azureKV,omitempty"`
+	// Kubernetes configures this store to sync secrets from Secrets of the
+	// same or a remote Kubernetes cluster.
+	// +optional
+	Kubernetes *KubernetesStore `json:"kubernetes,omitempty"`
+	// Consul configures this store to sync secrets using the HashiCorp
+	// Consul KV store.
+	// +optional
+	Consul *ConsulStore `json:"consul,omitempty"`
+	// OnePassword configures this store to sync secrets using a 1Password
+	// Connect server.
+	// +optional
+	OnePassword *OnePasswordStore `json:"onepassword,omitempty"`
+	// Conjur configures this store to sync secrets using CyberArk Conjur.
+	// +optional
+	Conjur *ConjurStore `json:"conjur,omitempty"`
+	// GitLab configures this store to sync secrets from GitLab CI/CD
+	// variables.
+	// +optional
+	GitLab *GitLabStore `json:"gitlab,omitempty"`
+	// Sops configures this store to sync secrets from SOPS encrypted files of
+	// a Git repository.
+	// +optional
+	Sops *SopsStore `json:"sops,omitempty"`
 }
 
 type SecretStoreStatus struct {
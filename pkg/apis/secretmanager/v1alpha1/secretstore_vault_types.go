@@ -41,7 +41,9 @@ type VaultStore struct {
 	Path string `json:"path"`
 
 	// Version is the Vault KV secret engine version. This can be either "v1" or
-	// "v2". Version defaults to "v2".
+	// "v2". If not set, the version is detected from the configuration of the
+	// mount at Path when the KV engine is first read, which requires the token
+	// to be permitted to read the mount configuration.
 	// +optional
 	Version *VaultKVStoreVersion `json:"version,omitempty"`
 
@@ -56,6 +58,31 @@ type VaultStore struct {
 	// are used to validate the TLS connection.
 	// +optional
 	CABundle []byte `json:"caBundle,omitempty"`
+
+	// The provider for the CA bundle to use to validate Vault server certificate.
+	// The referenced certificates are added to those given by CABundle.
+	// ExternalSecrets using the store are refreshed when the referenced object
+	// changes.
+	// +optional
+	CAProvider *CAProvider `json:"caProvider,omitempty"`
+
+	// TLSClientCert configures a client certificate presented to the Vault
+	// server when it requires mutual TLS authentication. ExternalSecrets using
+	// the store are refreshed when the referenced Secrets change.
+	// +optional
+	TLSClientCert *VaultClientCertificate `json:"tlsClientCert,omitempty"`
+}
+
+// VaultClientCertificate references a PEM encoded client certificate and
+// private key stored in Kubernetes Secret resources.
+type VaultClientCertificate struct {
+	// CertSecretRef is a reference to a key in a Secret that contains the PEM
+	// encoded client certificate.
+	CertSecretRef smmeta.SecretKeySelector `json:"certSecretRef"`
+
+	// KeySecretRef is a reference to a key in a Secret that contains the PEM
+	// encoded private key of the client certificate.
+	KeySecretRef smmeta.SecretKeySelector `json:"keySecretRef"`
 }
 
 // Configuration used to authenticate with a Vault server.
@@ -114,3 +141,22 @@ type VaultKubernetesAuth struct {
 	// Kubernetes ServiceAccount with a set of Vault policies.
 	Role string `json:"role"`
 }
+
+// VaultTransitReference configures the ciphertext to decrypt with the Vault
+// Transit secrets engine.
+// Exactly one of `ciphertext` or `ciphertextFrom` must be specified.
+type VaultTransitReference struct {
+	// Path where the Transit secrets engine is mounted in Vault, e.g:
+	// "transit"
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Ciphertext to decrypt, e.g: "vault:v1:8SDd3WHDOjf7mq69CyCqYjBXAiQQAVZRkFM13ok481zoCmHnSeDX9vyf7w=="
+	// +optional
+	Ciphertext *string `json:"ciphertext,omitempty"`
+
+	// CiphertextFrom references a key in a ConfigMap in the namespace of the
+	// ExternalSecret which contains the ciphertext to decrypt.
+	// +optional
+	CiphertextFrom *smmeta.ConfigMapKeySelector `json:"ciphertextFrom,omitempty"`
+}
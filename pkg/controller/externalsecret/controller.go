@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -37,12 +38,15 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"k8s.io/utils/clock"
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const (
@@ -53,6 +57,7 @@ const (
 	errStoreSetupFailed    = "cannot setup store client"
 	errGetSecretDataFailed = "cannot get ExternalSecret data from store"
 	errTemplateFailed      = "failed to merge secret with template field"
+	errCustomMetadata      = "cannot map custom metadata"
 )
 
 // ExternalSecretReconciler reconciles a ExternalSecret object
@@ -63,6 +68,10 @@ type ExternalSecretReconciler struct {
 	Clock  clock.Clock
 
 	Reader client.Reader
+
+	// cache serves the indexed lists mapping changed store dependencies to
+	// ExternalSecrets.
+	cache client.Reader
 }
 
 func (r *ExternalSecretReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
@@ -83,6 +92,7 @@ func (r *ExternalSecretReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 		},
 	}
 
+	var remoteVersions []smv1alpha1.RemoteVersion
 	result, err := ctrl.CreateOrUpdate(ctx, r.Client, secret, func() error {
 		s, err := r.getStore(ctx, extSecret)
 		if err != nil {
@@ -104,13 +114,26 @@ func (r *ExternalSecretReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 			return fmt.Errorf("failed to set ExternalSecret controller reference: %w", err)
 		}
 
-		secret.Labels = extSecret.Labels
-		secret.Annotations = extSecret.Annotations
-		secret.Data, err = r.getSecret(ctx, storeClient, extSecret)
+		fetched, err := r.getSecret(ctx, storeClient, extSecret)
 		if err != nil {
 			return fmt.Errorf("%s: %w", errGetSecretDataFailed, err)
 		}
 
+		secret.Labels = extSecret.Labels
+		secret.Annotations = extSecret.Annotations
+		if extSecret.Spec.CustomMetadata != nil {
+			secret.Labels, err = mapCustomMetadata(secret.Labels, extSecret.Spec.CustomMetadata.Labels, fetched.customMetadata, validation.IsValidLabelValue)
+			if err != nil {
+				return fmt.Errorf("%s: %w", errCustomMetadata, err)
+			}
+			secret.Annotations, err = mapCustomMetadata(secret.Annotations, extSecret.Spec.CustomMetadata.Annotations, fetched.customMetadata, nil)
+			if err != nil {
+				return fmt.Errorf("%s: %w", errCustomMetadata, err)
+			}
+		}
+		secret.Data = fetched.data
+		remoteVersions = fetched.versions
+
 		if extSecret.Spec.Template != nil {
 			err = r.templateSecret(secret, extSecret.Spec.Template)
 			if err != nil {
@@ -130,6 +153,7 @@ func (r *ExternalSecretReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 
 	log.Info("successfully reconcile ExternalSecret", "operation", result)
 	extSecret.Status.SetConditions(smmeta.Available())
+	extSecret.Status.RemoteVersions = remoteVersions
 	_ = r.Status().Update(ctx, extSecret)
 	return ctrl.Result{}, nil
 }
@@ -153,31 +177,108 @@ func (r *ExternalSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}); err != nil {
 		return err
 	}
+	if err := r.setupDependencyIndexes(mgr); err != nil {
+		return err
+	}
+
+	dependencyHandler := &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.requestsForDependency)}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&smv1alpha1.ExternalSecret{}).
 		Owns(&corev1.Secret{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, dependencyHandler).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, dependencyHandler).
 		Complete(r)
 }
 
-func (r *ExternalSecretReconciler) getSecret(ctx context.Context, storeClient store.Client, extSecret *smv1alpha1.ExternalSecret) (map[string][]byte, error) {
-	secretDataMap := make(map[string][]byte)
+// fetchedSecret is the data and metadata of the remote secrets referenced by
+// an ExternalSecret.
+type fetchedSecret struct {
+	data           map[string][]byte
+	versions       []smv1alpha1.RemoteVersion
+	customMetadata map[string]string
+}
+
+func (f *fetchedSecret) addMetadata(name string, metadata *store.SecretMetadata) {
+	if metadata == nil {
+		return
+	}
+	if metadata.Version != "" {
+		f.versions = append(f.versions, smv1alpha1.RemoteVersion{
+			Name:    name,
+			Version: metadata.Version,
+		})
+	}
+	for k, v := range metadata.CustomMetadata {
+		f.customMetadata[k] = v
+	}
+}
+
+func (r *ExternalSecretReconciler) getSecret(ctx context.Context, storeClient store.Client, extSecret *smv1alpha1.ExternalSecret) (*fetchedSecret, error) {
+	fetched := &fetchedSecret{
+		data:           make(map[string][]byte),
+		customMetadata: make(map[string]string),
+	}
+	metadataClient, hasMetadata := storeClient.(store.MetadataClient)
+
 	for _, remoteRef := range extSecret.Spec.DataFrom {
-		secretMap, err := storeClient.GetSecretMap(ctx, remoteRef)
+		var secretMap map[string][]byte
+		var metadata *store.SecretMetadata
+		var err error
+		if hasMetadata {
+			secretMap, metadata, err = metadataClient.GetSecretMapWithMetadata(ctx, remoteRef)
+		} else {
+			secretMap, err = storeClient.GetSecretMap(ctx, remoteRef)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("name %q: %w", remoteRef.Name, err)
 		}
-		secretDataMap = merge.Merge(secretDataMap, secretMap)
+		fetched.data = merge.Merge(fetched.data, secretMap)
+		fetched.addMetadata(remoteRef.Name, metadata)
 	}
 
 	for _, secretRef := range extSecret.Spec.Data {
-		secretData, err := storeClient.GetSecret(ctx, secretRef.RemoteRef)
+		var secretData []byte
+		var metadata *store.SecretMetadata
+		var err error
+		if hasMetadata {
+			secretData, metadata, err = metadataClient.GetSecretWithMetadata(ctx, secretRef.RemoteRef)
+		} else {
+			secretData, err = storeClient.GetSecret(ctx, secretRef.RemoteRef)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("name %q: %w", secretRef.RemoteRef.Name, err)
 		}
-		secretDataMap[secretRef.SecretKey] = secretData
+		fetched.data[secretRef.SecretKey] = secretData
+		fetched.addMetadata(secretRef.RemoteRef.Name, metadata)
 	}
 
-	return secretDataMap, nil
+	return fetched, nil
+}
+
+// mapCustomMetadata returns a copy of dst with the keys of mapping set to
+// the custom metadata values they refer to. If validate is set, each value
+// is checked with it before being set.
+func mapCustomMetadata(dst, mapping, customMetadata map[string]string, validate func(string) []string) (map[string]string, error) {
+	if len(mapping) == 0 {
+		return dst, nil
+	}
+	mapped := make(map[string]string, len(dst)+len(mapping))
+	for k, v := range dst {
+		mapped[k] = v
+	}
+	for k, metadataKey := range mapping {
+		v, ok := customMetadata[metadataKey]
+		if !ok {
+			continue
+		}
+		if validate != nil {
+			if errs := validate(v); len(errs) > 0 {
+				return nil, fmt.Errorf("value of custom metadata %q is invalid for %q: %s", metadataKey, k, strings.Join(errs, "; "))
+			}
+		}
+		mapped[k] = v
+	}
+	return mapped, nil
 }
 
 func (r *ExternalSecretReconciler) getStore(ctx context.Context, extSecret *smv1alpha1.ExternalSecret) (smv1alpha1.GenericStore, error) {
@@ -18,6 +18,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"testing"
 	"time"
 
 	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
@@ -31,6 +33,7 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -343,6 +346,177 @@ var _ = Describe("ExternalSecrets Controller", func() {
 			Expect(fetchedSecret.Data).Should(Equal(expectedMap), "Secret data should match test data")
 		})
 
+		It("An ExternalSecret should record remote versions and map custom metadata", func() {
+			store := sampleStore.DeepCopy()
+			By("Creating the SecretStore successfully")
+			Expect(k8sClient.Create(context.Background(), store)).Should(Succeed())
+			defer func() {
+				By("Deleting the SecretStore successfully")
+				Expect(k8sClient.Delete(context.Background(), store)).Should(Succeed())
+			}()
+			spec := smv1alpha1.ExternalSecretSpec{
+				StoreRef: smv1alpha1.ObjectReference{
+					Name: store.Name,
+					Kind: smv1alpha1.SecretStoreKind,
+				},
+				Data: []smv1alpha1.KeyReference{
+					{
+						SecretKey: "key",
+						RemoteRef: smv1alpha1.RemoteReference{
+							Name:     "secret/data/foo",
+							Property: smmeta.String("key"),
+						},
+					},
+				},
+				CustomMetadata: &smv1alpha1.CustomMetadataMapping{
+					Labels: map[string]string{
+						"example.com/owner": "owner",
+					},
+					Annotations: map[string]string{
+						"example.com/rotation-date": "rotation-date",
+					},
+				},
+			}
+
+			key := types.NamespacedName{
+				Name:      secretType.Name,
+				Namespace: secretType.Namespace,
+			}
+
+			toCreate := &smv1alpha1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+				},
+				Spec: spec,
+			}
+
+			storeFactory.WithGetSecret([]byte("this-is-a-secret"), nil)
+			storeFactory.WithMetadata(&storeint.SecretMetadata{
+				Version: "3",
+				CustomMetadata: map[string]string{
+					"owner":         "team-a",
+					"rotation-date": "2020-12-01",
+				},
+			})
+			defer storeFactory.WithMetadata(nil)
+			storeFactory.WithNew(func(context.Context, smv1alpha1.GenericStore,
+				client.Client, string) (storeint.Client, error) {
+				return storeFactory, nil
+			})
+
+			By("Creating the ExternalSecret successfully")
+			Expect(k8sClient.Create(context.Background(), toCreate)).Should(Succeed())
+			defer func() {
+				By("Deleting the ExternalSecret successfully")
+				Expect(k8sClient.Delete(context.Background(), toCreate)).Should(Succeed())
+			}()
+
+			fetched := &smv1alpha1.ExternalSecret{}
+			Eventually(func() bool {
+				By("Fetching the ExternalSecret successfully")
+				Expect(k8sClient.Get(context.Background(), key, fetched)).Should(Succeed())
+				By("Checking the Status Condition")
+				fetchedCond := fetched.Status.GetCondition(smmeta.TypeReady)
+				return fetchedCond.Matches(smmeta.Available())
+			}, timeout, interval).Should(BeTrue(), "The ExternalSecret should have a ready condition")
+
+			Expect(fetched.Status.RemoteVersions).Should(Equal([]smv1alpha1.RemoteVersion{
+				{
+					Name:    "secret/data/foo",
+					Version: "3",
+				},
+			}), "ExternalSecret status should have the remote version")
+
+			fetchedSecret := &corev1.Secret{}
+			Eventually(func() bool {
+				By("Fetching the Secret successfully")
+				Expect(k8sClient.Get(context.Background(), key, fetchedSecret)).Should(Succeed())
+				return true
+			}, timeout, interval).Should(BeTrue(), "The generated secret should be created")
+			defer func() {
+				By("Deleting the Secret successfully")
+				Expect(k8sClient.Delete(context.Background(), fetchedSecret)).Should(Succeed())
+			}()
+
+			Expect(fetchedSecret.Labels["example.com/owner"]).Should(Equal("team-a"),
+				"The secret should have labels from the custom metadata")
+			Expect(fetchedSecret.Annotations["example.com/rotation-date"]).Should(Equal("2020-12-01"),
+				"The secret should have annotations from the custom metadata")
+		})
+
+		It("An ExternalSecret with custom metadata that is not a valid label value should not be ready", func() {
+			store := sampleStore.DeepCopy()
+			By("Creating the SecretStore successfully")
+			Expect(k8sClient.Create(context.Background(), store)).Should(Succeed())
+			defer func() {
+				By("Deleting the SecretStore successfully")
+				Expect(k8sClient.Delete(context.Background(), store)).Should(Succeed())
+			}()
+			spec := smv1alpha1.ExternalSecretSpec{
+				StoreRef: smv1alpha1.ObjectReference{
+					Name: store.Name,
+					Kind: smv1alpha1.SecretStoreKind,
+				},
+				Data: []smv1alpha1.KeyReference{
+					{
+						SecretKey: "key",
+						RemoteRef: smv1alpha1.RemoteReference{
+							Name:     "secret/data/foo",
+							Property: smmeta.String("key"),
+						},
+					},
+				},
+				CustomMetadata: &smv1alpha1.CustomMetadataMapping{
+					Labels: map[string]string{
+						"example.com/owner": "owner",
+					},
+				},
+			}
+
+			key := types.NamespacedName{
+				Name:      secretType.Name,
+				Namespace: secretType.Namespace,
+			}
+
+			toCreate := &smv1alpha1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+				},
+				Spec: spec,
+			}
+
+			storeFactory.WithGetSecret([]byte("this-is-a-secret"), nil)
+			storeFactory.WithMetadata(&storeint.SecretMetadata{
+				CustomMetadata: map[string]string{
+					"owner": "Team A",
+				},
+			})
+			defer storeFactory.WithMetadata(nil)
+			storeFactory.WithNew(func(context.Context, smv1alpha1.GenericStore,
+				client.Client, string) (storeint.Client, error) {
+				return storeFactory, nil
+			})
+
+			By("Creating the ExternalSecret successfully")
+			Expect(k8sClient.Create(context.Background(), toCreate)).Should(Succeed())
+			defer func() {
+				By("Deleting the ExternalSecret successfully")
+				Expect(k8sClient.Delete(context.Background(), toCreate)).Should(Succeed())
+			}()
+
+			fetched := &smv1alpha1.ExternalSecret{}
+			Eventually(func() bool {
+				By("Fetching the ExternalSecret successfully")
+				Expect(k8sClient.Get(context.Background(), key, fetched)).Should(Succeed())
+				By("Checking the Status Condition")
+				fetchedCond := fetched.Status.GetCondition(smmeta.TypeReady)
+				return fetchedCond.Matches(smmeta.Unavailable()) &&
+					matches(fetchedCond.Message, errCustomMetadata)
+			}, timeout, interval).Should(BeTrue(), "The ExternalSecret should have a NotReady condition")
+		})
+
 		It("An ExternalSecret with a template fields should be set", func() {
 			store := sampleStore.DeepCopy()
 			By("Creating the SecretStore successfully")
@@ -518,3 +692,60 @@ var sampleStore = &smv1alpha1.SecretStore{
 		},
 	},
 }
+
+func TestMapCustomMetadata(t *testing.T) {
+	customMetadata := map[string]string{
+		"owner":   "team-a",
+		"contact": "Team A <team-a@example.com>",
+	}
+
+	tests := map[string]struct {
+		dst      map[string]string
+		mapping  map[string]string
+		validate func(string) []string
+		want     map[string]string
+		wantErr  bool
+	}{
+		"no mapping": {
+			dst:  map[string]string{"app": "db"},
+			want: map[string]string{"app": "db"},
+		},
+		"mapped keys": {
+			dst:     map[string]string{"app": "db"},
+			mapping: map[string]string{"example.com/owner": "owner", "example.com/missing": "missing"},
+			want:    map[string]string{"app": "db", "example.com/owner": "team-a"},
+		},
+		"valid label value": {
+			mapping:  map[string]string{"example.com/owner": "owner"},
+			validate: validation.IsValidLabelValue,
+			want:     map[string]string{"example.com/owner": "team-a"},
+		},
+		"invalid label value": {
+			mapping:  map[string]string{"example.com/contact": "contact"},
+			validate: validation.IsValidLabelValue,
+			wantErr:  true,
+		},
+		"annotation value": {
+			mapping: map[string]string{"example.com/contact": "contact"},
+			want:    map[string]string{"example.com/contact": "Team A <team-a@example.com>"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := mapCustomMetadata(tc.dst, tc.mapping, customMetadata, tc.validate)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
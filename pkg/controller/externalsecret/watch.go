@@ -0,0 +1,190 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	storeRefKey        = ".spec.storeRef"
+	storeDependencyKey = ".spec.dependencies"
+
+	secretKind    = "Secret"
+	configMapKind = "ConfigMap"
+)
+
+// storeRefIndexKey returns the index key of a store as referenced by
+// ExternalSecrets. Only SecretStores are namespaced.
+func storeRefIndexKey(kind, namespace, name string) string {
+	if kind == smv1alpha1.ClusterSecretStoreKind {
+		return fmt.Sprintf("%s/%s", kind, name)
+	}
+	return fmt.Sprintf("%s/%s/%s", smv1alpha1.SecretStoreKind, namespace, name)
+}
+
+// dependencyIndexKey returns the index key of a Secret or ConfigMap a store
+// depends on. An empty namespace stands for references of ClusterSecretStores
+// which resolve to the namespace of the ExternalSecret.
+func dependencyIndexKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+func indexStoreRef(obj runtime.Object) []string {
+	extSecret := obj.(*smv1alpha1.ExternalSecret)
+	ref := extSecret.Spec.StoreRef
+	return []string{storeRefIndexKey(ref.Kind, extSecret.Namespace, ref.Name)}
+}
+
+func indexStoreDependencies(obj runtime.Object) []string {
+	s := obj.(smv1alpha1.GenericStore)
+	return storeDependencies(s)
+}
+
+// storeDependencies returns the index keys of the Secrets and ConfigMaps the
+// TLS configuration of a store is read from, so that rotated certificates
+// are picked up without waiting for the next reconcile.
+func storeDependencies(s smv1alpha1.GenericStore) []string {
+	spec := s.GetSpec()
+	if spec == nil || spec.Vault == nil {
+		return nil
+	}
+	// objects read from the cache carry no TypeMeta
+	_, clusterScoped := s.(*smv1alpha1.ClusterSecretStore)
+	namespace := func(declared *string) string {
+		if !clusterScoped {
+			return s.GetObjectMeta().Namespace
+		}
+		if declared != nil {
+			return *declared
+		}
+		return ""
+	}
+
+	var keys []string
+	if caProvider := spec.Vault.CAProvider; caProvider != nil {
+		kind := secretKind
+		if caProvider.Type == smv1alpha1.CAProviderTypeConfigMap {
+			kind = configMapKind
+		}
+		keys = append(keys, dependencyIndexKey(kind, namespace(caProvider.Namespace), caProvider.Name))
+	}
+	if clientCert := spec.Vault.TLSClientCert; clientCert != nil {
+		certKey := dependencyIndexKey(secretKind, namespace(clientCert.CertSecretRef.Namespace), clientCert.CertSecretRef.Name)
+		keyKey := dependencyIndexKey(secretKind, namespace(clientCert.KeySecretRef.Namespace), clientCert.KeySecretRef.Name)
+		keys = append(keys, certKey)
+		if keyKey != certKey {
+			keys = append(keys, keyKey)
+		}
+	}
+	return keys
+}
+
+// requestsForDependency maps a changed Secret or ConfigMap to the
+// ExternalSecrets using a store that depends on it.
+func (r *ExternalSecretReconciler) requestsForDependency(obj handler.MapObject) []reconcile.Request {
+	ctx := context.Background()
+	kind := secretKind
+	if _, ok := obj.Object.(*corev1.ConfigMap); ok {
+		kind = configMapKind
+	}
+	namespace, name := obj.Meta.GetNamespace(), obj.Meta.GetName()
+	log := r.Log.WithValues("kind", kind, "name", types.NamespacedName{Namespace: namespace, Name: name})
+
+	var storeRefs []client.ListOption
+	secretStores := &smv1alpha1.SecretStoreList{}
+	if err := r.cache.List(ctx, secretStores, client.InNamespace(namespace),
+		client.MatchingFields{storeDependencyKey: dependencyIndexKey(kind, namespace, name)}); err != nil {
+		log.Error(err, "unable to list SecretStores")
+		return nil
+	}
+	for i := range secretStores.Items {
+		storeRefs = append(storeRefs, client.MatchingFields{
+			storeRefKey: storeRefIndexKey(smv1alpha1.SecretStoreKind, namespace, secretStores.Items[i].Name),
+		})
+	}
+
+	// ClusterSecretStores referencing the object by its namespace apply to
+	// ExternalSecrets of any namespace, those without a namespace only to
+	// ExternalSecrets of the object's namespace.
+	var clusterStoreRefs []client.ListOption
+	for _, declared := range []string{namespace, ""} {
+		clusterStores := &smv1alpha1.ClusterSecretStoreList{}
+		if err := r.cache.List(ctx, clusterStores,
+			client.MatchingFields{storeDependencyKey: dependencyIndexKey(kind, declared, name)}); err != nil {
+			log.Error(err, "unable to list ClusterSecretStores")
+			return nil
+		}
+		for i := range clusterStores.Items {
+			ref := client.MatchingFields{
+				storeRefKey: storeRefIndexKey(smv1alpha1.ClusterSecretStoreKind, "", clusterStores.Items[i].Name),
+			}
+			if declared == "" {
+				storeRefs = append(storeRefs, ref)
+			} else {
+				clusterStoreRefs = append(clusterStoreRefs, ref)
+			}
+		}
+	}
+
+	var requests []reconcile.Request
+	list := func(opts ...client.ListOption) {
+		extSecrets := &smv1alpha1.ExternalSecretList{}
+		if err := r.cache.List(ctx, extSecrets, opts...); err != nil {
+			log.Error(err, "unable to list ExternalSecrets")
+			return
+		}
+		for i := range extSecrets.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Namespace: extSecrets.Items[i].Namespace,
+				Name:      extSecrets.Items[i].Name,
+			}})
+		}
+	}
+	for _, ref := range storeRefs {
+		list(client.InNamespace(namespace), ref)
+	}
+	for _, ref := range clusterStoreRefs {
+		list(ref)
+	}
+	return requests
+}
+
+// setupDependencyIndexes indexes ExternalSecrets by store and stores by the
+// Secrets and ConfigMaps they depend on.
+func (r *ExternalSecretReconciler) setupDependencyIndexes(mgr ctrl.Manager) error {
+	r.cache = mgr.GetCache()
+	indexer := mgr.GetFieldIndexer()
+	if err := indexer.IndexField(context.Background(), &smv1alpha1.ExternalSecret{}, storeRefKey, indexStoreRef); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(context.Background(), &smv1alpha1.SecretStore{}, storeDependencyKey, indexStoreDependencies); err != nil {
+		return err
+	}
+	return indexer.IndexField(context.Background(), &smv1alpha1.ClusterSecretStore{}, storeDependencyKey, indexStoreDependencies)
+}
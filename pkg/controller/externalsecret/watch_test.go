@@ -0,0 +1,128 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStoreDependencies(t *testing.T) {
+	tlsVault := &smv1alpha1.VaultStore{
+		Server: "https://vault.example.com",
+		CAProvider: &smv1alpha1.CAProvider{
+			Type: smv1alpha1.CAProviderTypeConfigMap,
+			Name: "vault-ca",
+			Key:  "ca.crt",
+		},
+		TLSClientCert: &smv1alpha1.VaultClientCertificate{
+			CertSecretRef: smmeta.SecretKeySelector{
+				LocalObjectReference: smmeta.LocalObjectReference{Name: "vault-client"},
+				Key:                  "tls.crt",
+				Namespace:            smmeta.String("vault"),
+			},
+			KeySecretRef: smmeta.SecretKeySelector{
+				LocalObjectReference: smmeta.LocalObjectReference{Name: "vault-client"},
+				Key:                  "tls.key",
+				Namespace:            smmeta.String("vault"),
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		store smv1alpha1.GenericStore
+		want  []string
+	}{
+		"no TLS configuration": {
+			store: &smv1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "vault", Namespace: "default"},
+				Spec:       smv1alpha1.SecretStoreSpec{Vault: &smv1alpha1.VaultStore{Server: "https://vault.example.com"}},
+			},
+		},
+		"other backend": {
+			store: &smv1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws", Namespace: "default"},
+				Spec:       smv1alpha1.SecretStoreSpec{AWS: &smv1alpha1.AWSStore{}},
+			},
+		},
+		"namespaced store ignores declared namespaces": {
+			store: &smv1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "vault", Namespace: "default"},
+				Spec:       smv1alpha1.SecretStoreSpec{Vault: tlsVault},
+			},
+			want: []string{
+				"ConfigMap/default/vault-ca",
+				"Secret/default/vault-client",
+			},
+		},
+		"cluster store uses declared namespaces": {
+			store: &smv1alpha1.ClusterSecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "vault"},
+				Spec:       smv1alpha1.SecretStoreSpec{Vault: tlsVault},
+			},
+			want: []string{
+				"ConfigMap//vault-ca",
+				"Secret/vault/vault-client",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := storeDependencies(tc.store)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIndexStoreRef(t *testing.T) {
+	tests := map[string]struct {
+		ref  smv1alpha1.ObjectReference
+		want string
+	}{
+		"secret store": {
+			ref:  smv1alpha1.ObjectReference{Name: "vault", Kind: smv1alpha1.SecretStoreKind},
+			want: "SecretStore/default/vault",
+		},
+		"default kind": {
+			ref:  smv1alpha1.ObjectReference{Name: "vault"},
+			want: "SecretStore/default/vault",
+		},
+		"cluster secret store": {
+			ref:  smv1alpha1.ObjectReference{Name: "vault", Kind: smv1alpha1.ClusterSecretStoreKind},
+			want: "ClusterSecretStore/vault",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			extSecret := &smv1alpha1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "default"},
+				Spec:       smv1alpha1.ExternalSecretSpec{StoreRef: tc.ref},
+			}
+			got := indexStoreRef(extSecret)
+			if !reflect.DeepEqual(got, []string{tc.want}) {
+				t.Errorf("expected %v, got %v", []string{tc.want}, got)
+			}
+		})
+	}
+}
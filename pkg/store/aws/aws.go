@@ -16,38 +16,54 @@ package aws
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/endpoints"
 	"github.com/aws/aws-sdk-go-v2/aws/external"
 	"github.com/aws/aws-sdk-go-v2/aws/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 
 	"github.com/go-logr/logr"
 
-	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
 	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
 	ctxlog "github.com/itscontained/secret-manager/pkg/log"
 	"github.com/itscontained/secret-manager/pkg/store"
 	"github.com/itscontained/secret-manager/pkg/store/schema"
+	"github.com/itscontained/secret-manager/pkg/util/property"
+	"github.com/itscontained/secret-manager/pkg/util/serviceaccount"
+	"github.com/itscontained/secret-manager/pkg/util/storeref"
 
 	corev1 "k8s.io/api/core/v1"
 
 	"k8s.io/apimachinery/pkg/types"
 
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ store.Client = &AWS{}
+var _ store.MetadataClient = &AWS{}
 
 const (
-	AWSSecretsmanagerEndpoint = "AWS_SECRETSMANAGER_ENDPOINT"
-	AWSSTSEndpoint            = "AWS_STS_ENDPOINT"
+	// RoleARNAnnotation is the annotation of a ServiceAccount giving the IAM
+	// role assumed with its tokens.
+	RoleARNAnnotation = "eks.amazonaws.com/role-arn"
+	// DefaultJWTAudience is the audience of ServiceAccount tokens exchanged
+	// for AWS credentials.
+	DefaultJWTAudience = "sts.amazonaws.com"
+
+	versionIDPrefix = "uuid/"
+
+	// tokenTimeout bounds ServiceAccount token requests.
+	tokenTimeout = 30 * time.Second
 )
 
 type AWS struct {
@@ -56,12 +72,17 @@ type AWS struct {
 	log       logr.Logger
 	client    *secretsmanager.Client
 	namespace string
+
+	serviceAccounts typedcorev1.ServiceAccountsGetter
 }
 
 func init() {
 	schema.Register(&AWS{}, &smv1alpha1.SecretStoreSpec{
 		AWS: &smv1alpha1.AWSStore{},
 	})
+	schema.Register(&ParameterStore{}, &smv1alpha1.SecretStoreSpec{
+		ParameterStore: &smv1alpha1.AWSStore{},
+	})
 }
 
 func (a *AWS) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctrlclient.Client, namespace string) (store.Client, error) {
@@ -73,7 +94,7 @@ func (a *AWS) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctrlc
 		namespace: namespace,
 	}
 
-	cfg, err := awsClient.newConfig(ctx)
+	cfg, err := awsClient.newConfig(ctx, store.GetSpec().AWS)
 	if err != nil {
 		return nil, err
 	}
@@ -83,140 +104,398 @@ func (a *AWS) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctrlc
 }
 
 func (a *AWS) GetSecret(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, error) {
+	data, _, err := a.GetSecretWithMetadata(ctx, ref)
+	return data, err
+}
+
+func (a *AWS) GetSecretWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, *store.SecretMetadata, error) {
+	if ref.Find != nil {
+		return nil, nil, fmt.Errorf("find is only supported in dataFrom")
+	}
 	version := ""
 	if ref.Version != nil {
 		version = *ref.Version
 	}
-	data, err := a.readSecret(ctx, ref.Name, version)
+	value, metadata, err := a.readSecret(ctx, ref.Name, version)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	property := ""
-	if ref.Property != nil {
-		property = *ref.Property
+	if ref.Property == nil {
+		return value, metadata, nil
 	}
-	value, exists := data[property]
-	if !exists {
-		return nil, fmt.Errorf("property %q not found in secret response", property)
+	propValue, err := property.Get(value, *ref.Property)
+	if err != nil {
+		return nil, nil, err
 	}
-	return value, nil
+	return propValue, metadata, nil
 }
 
 func (a *AWS) GetSecretMap(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, error) {
+	data, _, err := a.GetSecretMapWithMetadata(ctx, ref)
+	return data, err
+}
+
+func (a *AWS) GetSecretMapWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, *store.SecretMetadata, error) {
+	if ref.Find != nil {
+		if ref.Version != nil {
+			return nil, nil, fmt.Errorf("version is not supported with find")
+		}
+		data, err := a.findSecrets(ctx, ref.Name, ref.Find)
+		return data, nil, err
+	}
 	version := ""
 	if ref.Version != nil {
 		version = *ref.Version
 	}
-	return a.readSecret(ctx, ref.Name, version)
+	value, metadata, err := a.readSecret(ctx, ref.Name, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := property.Map(value)
+	if errors.Is(err, property.ErrNotJSONObject) {
+		key := smv1alpha1.DefaultSecretKey
+		if ref.Property != nil {
+			key = *ref.Property
+		}
+		return map[string][]byte{key: value}, metadata, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, metadata, nil
 }
 
-func (a *AWS) readSecret(ctx context.Context, id, version string) (map[string][]byte, error) {
+// findSecrets merges the keys of the secrets whose name starts with
+// namePrefix and which have all tags of find. Secrets not holding a JSON
+// object are added under their name, with slashes replaced by the find
+// separator. Secrets are merged in order of their name and a key found in
+// more than one secret is an error.
+func (a *AWS) findSecrets(ctx context.Context, namePrefix string, find *smv1alpha1.FindReference) (map[string][]byte, error) {
+	separator := smv1alpha1.DefaultFindSeparator
+	if find.Separator != nil {
+		separator = *find.Separator
+	}
+
+	names, err := a.listSecrets(ctx, namePrefix, find.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	secretMap := make(map[string][]byte)
+	owners := make(map[string]string)
+	for _, name := range names {
+		value, _, err := a.readSecret(ctx, name, "")
+		if err != nil {
+			return nil, fmt.Errorf("error reading secret %q: %w", name, err)
+		}
+		data, err := property.Map(value)
+		if errors.Is(err, property.ErrNotJSONObject) {
+			data = map[string][]byte{strings.ReplaceAll(name, "/", separator): value}
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading secret %q: %w", name, err)
+		}
+		for k, v := range data {
+			if owner, exists := owners[k]; exists {
+				return nil, fmt.Errorf("key %q of secret %q conflicts with secret %q", k, name, owner)
+			}
+			owners[k] = name
+			secretMap[k] = v
+		}
+	}
+	return secretMap, nil
+}
+
+// listSecrets returns the sorted names of the secrets whose name starts with
+// namePrefix and which have all of the given tags.
+func (a *AWS) listSecrets(ctx context.Context, namePrefix string, tags map[string]string) ([]string, error) {
+	var filters []secretsmanager.Filter
+	if namePrefix != "" {
+		filters = append(filters, secretsmanager.Filter{
+			Key:    secretsmanager.FilterNameStringTypeName,
+			Values: []string{namePrefix},
+		})
+	}
+	if len(tags) > 0 {
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		filters = append(filters, secretsmanager.Filter{
+			Key:    secretsmanager.FilterNameStringTypeTagKey,
+			Values: keys,
+		})
+	}
+
+	req := a.client.ListSecretsRequest(&secretsmanager.ListSecretsInput{
+		Filters: filters,
+	})
+	pager := secretsmanager.NewListSecretsPaginator(req)
+	var names []string
+	for pager.Next(ctx) {
+		// the filters match any of their values, so the name prefix and all
+		// tags are checked here
+		for _, entry := range pager.CurrentPage().SecretList {
+			name := aws.StringValue(entry.Name)
+			if strings.HasPrefix(name, namePrefix) && hasTags(entry.Tags, tags) {
+				names = append(names, name)
+			}
+		}
+	}
+	if err := pager.Err(); err != nil {
+		return nil, fmt.Errorf("error listing secrets: %w", err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// hasTags returns whether all of the wanted tags are in tags.
+func hasTags(tags []secretsmanager.Tag, wanted map[string]string) bool {
+	found := 0
+	for _, tag := range tags {
+		if value, ok := wanted[aws.StringValue(tag.Key)]; ok && value == aws.StringValue(tag.Value) {
+			found++
+		}
+	}
+	return found == len(wanted)
+}
+
+// readSecret returns the value of the secret, which is either the
+// SecretString or the SecretBinary of the secret version, and the VersionId
+// of the secret version. A version prefixed with "uuid/" selects the secret
+// version by VersionId, any other version by VersionStage.
+func (a *AWS) readSecret(ctx context.Context, id, version string) ([]byte, *store.SecretMetadata, error) {
 	input := &secretsmanager.GetSecretValueInput{
 		SecretId: aws.String(id),
 	}
-	if version != "" {
+	if strings.HasPrefix(version, versionIDPrefix) {
+		input.VersionId = aws.String(strings.TrimPrefix(version, versionIDPrefix))
+	} else if version != "" {
 		input.VersionStage = aws.String(version)
 	}
 	req := a.client.GetSecretValueRequest(input)
 	resp, err := req.Send(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error getting secret value: %w", err)
+		return nil, nil, fmt.Errorf("error getting secret value: %w", err)
 	}
-	smData := make(map[string]string)
-	err = json.Unmarshal([]byte(*resp.SecretString), &smData)
-	if err != nil {
-		return nil, fmt.Errorf("unable to unmarshal secret value: %w", err)
+	metadata := &store.SecretMetadata{
+		Version: aws.StringValue(resp.VersionId),
 	}
-	secretData := make(map[string][]byte)
-	for k, v := range smData {
-		secretData[k] = []byte(v)
+	if resp.SecretString != nil {
+		return []byte(*resp.SecretString), metadata, nil
 	}
-	return secretData, nil
+	if resp.SecretBinary != nil {
+		return resp.SecretBinary, metadata, nil
+	}
+	return nil, nil, fmt.Errorf("secret %q has no value", id)
 }
 
-func (a *AWS) newConfig(ctx context.Context) (*aws.Config, error) {
+// newConfig returns the config of a client authenticated as configured in
+// spec, which is the AWS or ParameterStore spec of the store.
+func (a *AWS) newConfig(ctx context.Context, spec *smv1alpha1.AWSStore) (*aws.Config, error) {
 	cfg, err := external.LoadDefaultAWSConfig()
 	if err != nil {
 		return nil, err
 	}
-	cfg.EndpointResolver = &EndpointResolver{}
-	spec := *a.store.GetSpec().AWS
+	cfg.EndpointResolver = NewEndpointResolver(spec.Endpoints, cfg.EndpointResolver)
 	if spec.Region != nil {
 		cfg.Region = *spec.Region
 	}
-	if spec.AuthSecretRef == nil {
-		return &cfg, nil
+	if spec.AuthSecretRef != nil && spec.JWTAuth != nil {
+		return nil, fmt.Errorf("authSecretRef and jwtAuth are mutually exclusive")
 	}
-	scoped := true
-	if a.store.GetTypeMeta().String() == "ClusterSecretStore" {
-		scoped = false
+	if spec.AuthSecretRef != nil {
+		if err := a.secretCredentials(ctx, &cfg, spec.AuthSecretRef); err != nil {
+			return nil, err
+		}
 	}
-	if spec.AuthSecretRef.AccessKeyID == nil || spec.AuthSecretRef.SecretAccessKey == nil {
-		return nil, fmt.Errorf("missing accessKeyID/secretAccessKey in store config")
+	if spec.JWTAuth != nil {
+		if err := a.jwtCredentials(ctx, &cfg, spec.JWTAuth); err != nil {
+			return nil, err
+		}
+	}
+	for _, role := range spec.AssumeRoles {
+		cfg.Credentials = assumeRoleProvider(cfg, role)
+	}
+	return &cfg, nil
+}
+
+// secretCredentials sets the credentials of cfg to the access key stored in
+// Secrets, assuming the role stored in a Secret if set.
+func (a *AWS) secretCredentials(ctx context.Context, cfg *aws.Config, auth *smv1alpha1.AWSAuth) error {
+	if auth.AccessKeyID == nil || auth.SecretAccessKey == nil {
+		return fmt.Errorf("missing accessKeyID/secretAccessKey in store config")
 	}
-	aKid, err := a.secretKeyRef(ctx, a.store.GetNamespace(), *spec.AuthSecretRef.AccessKeyID, scoped)
+	aKid, err := storeref.SecretKey(ctx, a.kube, a.store, "accessKeyID", *auth.AccessKeyID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	sak, err := a.secretKeyRef(ctx, a.store.GetNamespace(), *spec.AuthSecretRef.SecretAccessKey, scoped)
+	sak, err := storeref.SecretKey(ctx, a.kube, a.store, "secretAccessKey", *auth.SecretAccessKey)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	nScp := aws.NewStaticCredentialsProvider(aKid, sak, "secret-manager")
 	cfg.Credentials = nScp
-	if spec.AuthSecretRef.Role != nil {
-		role, err := a.secretKeyRef(ctx, a.store.GetNamespace(), *spec.AuthSecretRef.Role, scoped)
+	if auth.Role != nil {
+		role, err := storeref.SecretKey(ctx, a.kube, a.store, "role", *auth.Role)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		stsClient := sts.New(cfg)
+		stsClient := sts.New(*cfg)
 		stsCp := stscreds.NewAssumeRoleProvider(stsClient, role)
 		cfg.Credentials = stsCp
 	}
-	return &cfg, nil
+	return nil
 }
 
-func (a *AWS) secretKeyRef(ctx context.Context, namespace string, secretRef smmeta.SecretKeySelector, scoped bool) (string, error) {
-	var secret corev1.Secret
+// jwtCredentials sets the credentials of cfg to the credentials of the role
+// assumed with a token requested for the referenced ServiceAccount.
+func (a *AWS) jwtCredentials(ctx context.Context, cfg *aws.Config, auth *smv1alpha1.AWSJWTAuth) error {
+	saRef := auth.ServiceAccountRef
+	namespace, err := storeref.Namespace(a.store, "serviceAccountRef", saRef.Namespace)
+	if err != nil {
+		return err
+	}
 	ref := types.NamespacedName{
 		Namespace: namespace,
-		Name:      secretRef.Name,
+		Name:      saRef.Name,
 	}
-	if !scoped && secretRef.Namespace != nil {
-		ref.Namespace = *secretRef.Namespace
+	roleARN := ""
+	if auth.RoleARN != nil {
+		roleARN = *auth.RoleARN
+	} else {
+		var sa corev1.ServiceAccount
+		if err := a.kube.Get(ctx, ref, &sa); err != nil {
+			return err
+		}
+		roleARN = sa.Annotations[RoleARNAnnotation]
+		if roleARN == "" {
+			return fmt.Errorf("no roleARN in store config and no %q annotation on service account '%s/%s'", RoleARNAnnotation, ref.Namespace, ref.Name)
+		}
 	}
-	err := a.kube.Get(ctx, ref, &secret)
-	if err != nil {
-		return "", err
+	if a.serviceAccounts == nil {
+		client, err := serviceaccount.Client()
+		if err != nil {
+			return err
+		}
+		a.serviceAccounts = client
+	}
+	audiences := saRef.Audiences
+	if len(audiences) == 0 {
+		audiences = []string{DefaultJWTAudience}
+	}
+	sessionName := ""
+	if auth.SessionName != nil {
+		sessionName = *auth.SessionName
+	}
+	tokenRetriever := &serviceAccountToken{
+		client:    a.serviceAccounts,
+		ref:       ref,
+		audiences: audiences,
+	}
+	cfg.Credentials = stscreds.NewWebIdentityRoleProvider(sts.New(*cfg), roleARN, sessionName, tokenRetriever)
+	return nil
+}
+
+// assumeRoleProvider returns a provider of the credentials of role, assumed
+// with the credentials of cfg.
+func assumeRoleProvider(cfg aws.Config, role smv1alpha1.AWSAssumeRole) aws.CredentialsProvider {
+	client := &sessionTagger{
+		Client:            sts.New(cfg),
+		transitiveTagKeys: role.TransitiveTagKeys,
+	}
+	keys := make([]string, 0, len(role.SessionTags))
+	for k := range role.SessionTags {
+		keys = append(keys, k)
 	}
-	keyBytes, ok := secret.Data[secretRef.Key]
-	if !ok {
-		return "", fmt.Errorf("no data for %q in secret '%s/%s'", secretRef.Key, secretRef.Name, namespace)
+	sort.Strings(keys)
+	for _, k := range keys {
+		client.tags = append(client.tags, sts.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(role.SessionTags[k]),
+		})
 	}
-	value := strings.TrimSpace(string(keyBytes))
-	return value, nil
+	return stscreds.NewAssumeRoleProvider(client, role.RoleARN, func(o *stscreds.AssumeRoleProviderOptions) {
+		o.ExternalID = role.ExternalID
+		if role.SessionName != nil {
+			o.RoleSessionName = *role.SessionName
+		}
+		if role.SessionDuration != nil {
+			o.Duration = role.SessionDuration.Duration
+		}
+	})
+}
+
+// sessionTagger passes session tags along with the AssumeRole requests of an
+// STS client, which stscreds.AssumeRoleProvider does not support.
+type sessionTagger struct {
+	*sts.Client
+	tags              []sts.Tag
+	transitiveTagKeys []string
+}
+
+func (s *sessionTagger) AssumeRoleRequest(input *sts.AssumeRoleInput) sts.AssumeRoleRequest {
+	input.Tags = s.tags
+	input.TransitiveTagKeys = s.transitiveTagKeys
+	return s.Client.AssumeRoleRequest(input)
 }
 
-// EndpointResolver resolves custom endpoints for aws services
+// serviceAccountToken retrieves web identity tokens from the Kubernetes API.
+type serviceAccountToken struct {
+	client    typedcorev1.ServiceAccountsGetter
+	ref       types.NamespacedName
+	audiences []string
+}
+
+func (t *serviceAccountToken) GetIdentityToken() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenTimeout)
+	defer cancel()
+	token, err := serviceaccount.Token(ctx, t.client, t.ref.Namespace, t.ref.Name, t.audiences)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(token), nil
+}
+
+// EndpointResolver resolves the endpoints of AWS services, using the endpoints
+// configured in the store before falling back to the default endpoints.
 type EndpointResolver struct {
-	res endpoints.Resolver
+	endpoints map[string]string
+	res       aws.EndpointResolver
+}
+
+// NewEndpointResolver returns a resolver of the endpoints configured in the
+// store, falling back to res for services without a configured endpoint.
+func NewEndpointResolver(spec *smv1alpha1.AWSEndpoints, res aws.EndpointResolver) *EndpointResolver {
+	if res == nil {
+		res = endpoints.NewDefaultResolver()
+	}
+	r := &EndpointResolver{
+		endpoints: make(map[string]string),
+		res:       res,
+	}
+	if spec == nil {
+		return r
+	}
+	if spec.SecretsManager != nil {
+		r.endpoints[secretsmanager.EndpointsID] = *spec.SecretsManager
+	}
+	if spec.STS != nil {
+		r.endpoints[sts.EndpointsID] = *spec.STS
+	}
+	if spec.SSM != nil {
+		r.endpoints[ssm.EndpointsID] = *spec.SSM
+	}
+	return r
 }
 
 // ResolveEndpoint resolves custom endpoints if provided
 func (r *EndpointResolver) ResolveEndpoint(service, region string) (aws.Endpoint, error) {
-	if ep := os.Getenv(AWSSecretsmanagerEndpoint); ep != "" {
-		if service == "secretsmanager" {
-			return aws.Endpoint{
-				URL: ep,
-			}, nil
-		}
-	}
-	if ep := os.Getenv(AWSSTSEndpoint); ep != "" {
-		if service == "sts" {
-			return aws.Endpoint{
-				URL: ep,
-			}, nil
-		}
+	if ep, ok := r.endpoints[service]; ok {
+		return aws.Endpoint{
+			URL:           ep,
+			SigningRegion: region,
+		}, nil
 	}
 	return r.res.ResolveEndpoint(service, region)
 }
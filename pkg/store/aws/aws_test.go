@@ -0,0 +1,801 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/defaults"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeSecret is a Secrets Manager secret served by newTestAWS.
+type fakeSecret struct {
+	SecretString  *string  `json:",omitempty"`
+	SecretBinary  []byte   `json:",omitempty"`
+	VersionID     *string  `json:"VersionId,omitempty"`
+	VersionStages []string `json:",omitempty"`
+	// Tags are only returned by ListSecrets.
+	Tags map[string]string `json:"-"`
+}
+
+// matches reports whether the secret version is selected by the request.
+func (s fakeSecret) matches(input *secretsmanager.GetSecretValueInput) bool {
+	if input.VersionId != nil && aws.StringValue(input.VersionId) != aws.StringValue(s.VersionID) {
+		return false
+	}
+	if input.VersionStage == nil {
+		return true
+	}
+	for _, stage := range s.VersionStages {
+		if stage == *input.VersionStage {
+			return true
+		}
+	}
+	return false
+}
+
+// listSecretsPageSize is the number of secrets returned per ListSecrets page.
+const listSecretsPageSize = 2
+
+// listSecrets returns a page of the secrets matching any value of each of
+// the name and tag-key filters of the request, as ListSecrets does.
+func listSecrets(secrets map[string]fakeSecret, input *secretsmanager.ListSecretsInput) map[string]interface{} {
+	var names []string
+	for name, secret := range secrets {
+		if matchesFilters(name, secret, input.Filters) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var entries []map[string]interface{}
+	start, _ := strconv.Atoi(aws.StringValue(input.NextToken))
+	end := start + listSecretsPageSize
+	resp := map[string]interface{}{}
+	if end < len(names) {
+		resp["NextToken"] = strconv.Itoa(end)
+	} else {
+		end = len(names)
+	}
+	for _, name := range names[start:end] {
+		var tags []map[string]string
+		for k, v := range secrets[name].Tags {
+			tags = append(tags, map[string]string{"Key": k, "Value": v})
+		}
+		entries = append(entries, map[string]interface{}{"Name": name, "Tags": tags})
+	}
+	resp["SecretList"] = entries
+	return resp
+}
+
+func matchesFilters(name string, secret fakeSecret, filters []secretsmanager.Filter) bool {
+	for _, filter := range filters {
+		matched := false
+		for _, value := range filter.Values {
+			switch filter.Key {
+			case secretsmanager.FilterNameStringTypeName:
+				matched = matched || strings.HasPrefix(name, value)
+			case secretsmanager.FilterNameStringTypeTagKey:
+				_, ok := secret.Tags[value]
+				matched = matched || ok
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// newTestAWS returns an AWS store client sending requests to a stand-in
+// for the Secrets Manager API serving the given secrets by id.
+func newTestAWS(t *testing.T, secrets map[string]fakeSecret) *AWS {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch target := r.Header.Get("X-Amz-Target"); target {
+		case "secretsmanager.GetSecretValue":
+			input := secretsmanager.GetSecretValueInput{}
+			if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+				t.Errorf("error decoding request: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			secret, ok := secrets[aws.StringValue(input.SecretId)]
+			if !ok || !secret.matches(&input) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"__type": "ResourceNotFoundException", "Message": "Secrets Manager can't find the specified secret."}`))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(secret)
+		case "secretsmanager.ListSecrets":
+			input := secretsmanager.ListSecretsInput{}
+			if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+				t.Errorf("error decoding request: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(listSecrets(secrets, &input))
+		default:
+			t.Errorf("unexpected operation %q", target)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := defaults.Config()
+	cfg.Region = "us-east-1"
+	cfg.Credentials = aws.NewStaticCredentialsProvider("AKID", "SECRET", "")
+	cfg.EndpointResolver = aws.ResolveWithEndpointURL(srv.URL)
+	cfg.Retryer = aws.NoOpRetryer{}
+
+	return &AWS{
+		client: secretsmanager.New(cfg),
+	}
+}
+
+func TestGetSecret(t *testing.T) {
+	a := newTestAWS(t, map[string]fakeSecret{
+		"json":   {SecretString: aws.String(`{"username": "bob", "password": "abc123"}`)},
+		"nested": {SecretString: aws.String(`{"db": {"primary": {"password": "abc123", "port": 5432}}}`)},
+		"plain":  {SecretString: aws.String("abc123")},
+		"binary": {SecretBinary: []byte{0xde, 0xad, 0xbe, 0xef}},
+	})
+
+	tests := map[string]struct {
+		ref     smv1alpha1.RemoteReference
+		want    []byte
+		wantErr bool
+	}{
+		"json property": {
+			ref:  smv1alpha1.RemoteReference{Name: "json", Property: smmeta.String("password")},
+			want: []byte("abc123"),
+		},
+		"json without property": {
+			ref:  smv1alpha1.RemoteReference{Name: "json"},
+			want: []byte(`{"username": "bob", "password": "abc123"}`),
+		},
+		"json missing property": {
+			ref:     smv1alpha1.RemoteReference{Name: "json", Property: smmeta.String("missing")},
+			wantErr: true,
+		},
+		"nested property": {
+			ref:  smv1alpha1.RemoteReference{Name: "nested", Property: smmeta.String("db.primary.password")},
+			want: []byte("abc123"),
+		},
+		"nested number property": {
+			ref:  smv1alpha1.RemoteReference{Name: "nested", Property: smmeta.String("db.primary.port")},
+			want: []byte("5432"),
+		},
+		"nested object property": {
+			ref:  smv1alpha1.RemoteReference{Name: "nested", Property: smmeta.String("db.primary")},
+			want: []byte(`{"password":"abc123","port":5432}`),
+		},
+		"plain text": {
+			ref:  smv1alpha1.RemoteReference{Name: "plain"},
+			want: []byte("abc123"),
+		},
+		"plain text property": {
+			ref:     smv1alpha1.RemoteReference{Name: "plain", Property: smmeta.String("password")},
+			wantErr: true,
+		},
+		"binary": {
+			ref:  smv1alpha1.RemoteReference{Name: "binary"},
+			want: []byte{0xde, 0xad, 0xbe, 0xef},
+		},
+		"not found": {
+			ref:     smv1alpha1.RemoteReference{Name: "missing"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := a.GetSecret(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetSecretMap(t *testing.T) {
+	a := newTestAWS(t, map[string]fakeSecret{
+		"json":   {SecretString: aws.String(`{"username": "bob", "password": "abc123", "port": 5432}`)},
+		"plain":  {SecretString: aws.String("abc123")},
+		"binary": {SecretBinary: []byte{0xde, 0xad, 0xbe, 0xef}},
+	})
+
+	tests := map[string]struct {
+		ref  smv1alpha1.RemoteReference
+		want map[string][]byte
+	}{
+		"json": {
+			ref: smv1alpha1.RemoteReference{Name: "json"},
+			want: map[string][]byte{
+				"username": []byte("bob"),
+				"password": []byte("abc123"),
+				"port":     []byte("5432"),
+			},
+		},
+		"plain text with default key": {
+			ref: smv1alpha1.RemoteReference{Name: "plain"},
+			want: map[string][]byte{
+				smv1alpha1.DefaultSecretKey: []byte("abc123"),
+			},
+		},
+		"binary with key from property": {
+			ref: smv1alpha1.RemoteReference{Name: "binary", Property: smmeta.String("keystore")},
+			want: map[string][]byte{
+				"keystore": {0xde, 0xad, 0xbe, 0xef},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := a.GetSecretMap(context.Background(), tc.ref)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetSecretMapFind(t *testing.T) {
+	a := newTestAWS(t, map[string]fakeSecret{
+		"team-a/db": {
+			SecretString: aws.String(`{"db_username": "bob", "db_password": "abc123"}`),
+			Tags:         map[string]string{"team": "a", "env": "prod"},
+		},
+		"team-a/api-key": {
+			SecretString: aws.String("xyz789"),
+			Tags:         map[string]string{"team": "a", "env": "prod"},
+		},
+		"team-a/staging": {
+			SecretString: aws.String(`{"db_password": "staging"}`),
+			Tags:         map[string]string{"team": "a", "env": "staging"},
+		},
+		"team-b/db": {
+			SecretString: aws.String(`{"db_password": "def456"}`),
+			Tags:         map[string]string{"team": "b", "env": "prod"},
+		},
+	})
+
+	tests := map[string]struct {
+		ref     smv1alpha1.RemoteReference
+		want    map[string][]byte
+		wantErr bool
+	}{
+		"tags": {
+			ref: smv1alpha1.RemoteReference{
+				Find: &smv1alpha1.FindReference{
+					Tags: map[string]string{"team": "a", "env": "prod"},
+				},
+			},
+			want: map[string][]byte{
+				"db_username":    []byte("bob"),
+				"db_password":    []byte("abc123"),
+				"team-a_api-key": []byte("xyz789"),
+			},
+		},
+		"name prefix and tags": {
+			ref: smv1alpha1.RemoteReference{
+				Name: "team-",
+				Find: &smv1alpha1.FindReference{
+					Tags:      map[string]string{"env": "staging"},
+					Separator: smmeta.String("."),
+				},
+			},
+			want: map[string][]byte{
+				"db_password": []byte("staging"),
+			},
+		},
+		"conflicting keys": {
+			ref: smv1alpha1.RemoteReference{
+				Name: "team-a/",
+				Find: &smv1alpha1.FindReference{},
+			},
+			wantErr: true,
+		},
+		"with version": {
+			ref: smv1alpha1.RemoteReference{
+				Name:    "team-a/",
+				Version: smmeta.String("AWSCURRENT"),
+				Find:    &smv1alpha1.FindReference{},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := a.GetSecretMap(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetSecretWithMetadata(t *testing.T) {
+	a := newTestAWS(t, map[string]fakeSecret{
+		"versioned": {
+			SecretString:  aws.String("abc123"),
+			VersionID:     aws.String("EXAMPLE1-90ab-cdef-fedc-ba987SECRET1"),
+			VersionStages: []string{"AWSCURRENT"},
+		},
+	})
+
+	tests := map[string]struct {
+		version *string
+		wantErr bool
+	}{
+		"latest": {},
+		"version stage": {
+			version: smmeta.String("AWSCURRENT"),
+		},
+		"version id": {
+			version: smmeta.String("uuid/EXAMPLE1-90ab-cdef-fedc-ba987SECRET1"),
+		},
+		"unknown version stage": {
+			version: smmeta.String("AWSPREVIOUS"),
+			wantErr: true,
+		},
+		"unknown version id": {
+			version: smmeta.String("uuid/EXAMPLE2-90ab-cdef-fedc-ba987SECRET2"),
+			wantErr: true,
+		},
+		"version id without prefix": {
+			version: smmeta.String("EXAMPLE1-90ab-cdef-fedc-ba987SECRET1"),
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ref := smv1alpha1.RemoteReference{Name: "versioned", Version: tc.version}
+			got, meta, err := a.GetSecretWithMetadata(context.Background(), ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != "abc123" {
+				t.Errorf("expected %q, got %q", "abc123", got)
+			}
+			if meta.Version != "EXAMPLE1-90ab-cdef-fedc-ba987SECRET1" {
+				t.Errorf("expected version id %q, got %q", "EXAMPLE1-90ab-cdef-fedc-ba987SECRET1", meta.Version)
+			}
+		})
+	}
+}
+
+// stsCall is a request received by the STS stand-in of newTestSTS.
+type stsCall struct {
+	// AccessKeyID is the access key the request was signed with.
+	AccessKeyID string
+	Form        url.Values
+}
+
+// newTestSTS starts a stand-in for the STS API and returns its endpoint.
+// Assumed roles are given the last part of their ARN as access key.
+func newTestSTS(t *testing.T) (string, *[]stsCall) {
+	t.Helper()
+	calls := &[]stsCall{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("error parsing request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		call := stsCall{Form: r.PostForm}
+		auth := r.Header.Get("Authorization")
+		if i := strings.Index(auth, "Credential="); i >= 0 {
+			call.AccessKeyID = strings.SplitN(auth[i+len("Credential="):], "/", 2)[0]
+		}
+		*calls = append(*calls, call)
+		action := r.PostForm.Get("Action")
+		roleARN := r.PostForm.Get("RoleArn")
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>%[2]s</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>2100-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </%[1]sResult>
+</%[1]sResponse>`, action, roleARN[strings.LastIndex(roleARN, "/")+1:])
+	}))
+	t.Cleanup(srv.Close)
+
+	// keep the default config from querying the instance metadata endpoint
+	setenv(t, "AWS_EC2_METADATA_DISABLED", "true")
+	return srv.URL, calls
+}
+
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	os.Setenv(key, value)
+	t.Cleanup(func() { os.Unsetenv(key) })
+}
+
+func newTestStore(spec *smv1alpha1.AWSStore) *smv1alpha1.SecretStore {
+	spec.Region = aws.String("us-east-1")
+	return &smv1alpha1.SecretStore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws",
+			Namespace: "default",
+		},
+		Spec: smv1alpha1.SecretStoreSpec{
+			AWS: spec,
+		},
+	}
+}
+
+func TestNewConfigAssumeRoles(t *testing.T) {
+	endpoint, calls := newTestSTS(t)
+	store := newTestStore(&smv1alpha1.AWSStore{
+		Endpoints: &smv1alpha1.AWSEndpoints{
+			STS: aws.String(endpoint),
+		},
+		AuthSecretRef: &smv1alpha1.AWSAuth{
+			AccessKeyID: &smmeta.SecretKeySelector{
+				LocalObjectReference: smmeta.LocalObjectReference{Name: "aws-creds"},
+				Key:                  "id",
+			},
+			SecretAccessKey: &smmeta.SecretKeySelector{
+				LocalObjectReference: smmeta.LocalObjectReference{Name: "aws-creds"},
+				Key:                  "secret",
+			},
+		},
+		AssumeRoles: []smv1alpha1.AWSAssumeRole{
+			{
+				RoleARN:           "arn:aws:iam::123456789012:role/first",
+				ExternalID:        aws.String("external"),
+				SessionName:       aws.String("secret-manager"),
+				SessionDuration:   &metav1.Duration{Duration: time.Hour},
+				SessionTags:       map[string]string{"team": "a", "env": "b"},
+				TransitiveTagKeys: []string{"team"},
+			},
+			{
+				RoleARN: "arn:aws:iam::210987654321:role/second",
+			},
+		},
+	})
+	a := &AWS{
+		kube: fakeclient.NewFakeClientWithScheme(scheme.Scheme, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "aws-creds", Namespace: "default"},
+			Data: map[string][]byte{
+				"id":     []byte("AKID"),
+				"secret": []byte("SECRET"),
+			},
+		}),
+		store: store,
+	}
+
+	cfg, err := a.newConfig(context.Background(), a.store.GetSpec().AWS)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	creds, err := cfg.Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if creds.AccessKeyID != "second" {
+		t.Errorf("expected credentials of role %q, got %q", "second", creds.AccessKeyID)
+	}
+
+	if len(*calls) != 2 {
+		t.Fatalf("expected 2 STS calls, got %d", len(*calls))
+	}
+	first, second := (*calls)[0], (*calls)[1]
+	want := map[string]string{
+		"Action":                     "AssumeRole",
+		"RoleArn":                    "arn:aws:iam::123456789012:role/first",
+		"ExternalId":                 "external",
+		"RoleSessionName":            "secret-manager",
+		"DurationSeconds":            "3600",
+		"Tags.member.1.Key":          "env",
+		"Tags.member.1.Value":        "b",
+		"Tags.member.2.Key":          "team",
+		"Tags.member.2.Value":        "a",
+		"TransitiveTagKeys.member.1": "team",
+	}
+	for k, v := range want {
+		if got := first.Form.Get(k); got != v {
+			t.Errorf("expected %s %q, got %q", k, v, got)
+		}
+	}
+	if first.AccessKeyID != "AKID" {
+		t.Errorf("expected first role assumed with %q, got %q", "AKID", first.AccessKeyID)
+	}
+	if got := second.Form.Get("RoleArn"); got != "arn:aws:iam::210987654321:role/second" {
+		t.Errorf("expected second role assumed, got %q", got)
+	}
+	if second.AccessKeyID != "first" {
+		t.Errorf("expected second role assumed with %q, got %q", "first", second.AccessKeyID)
+	}
+}
+
+func TestNewConfigRefNamespace(t *testing.T) {
+	setenv(t, "AWS_EC2_METADATA_DISABLED", "true")
+	kube := fakeclient.NewFakeClientWithScheme(scheme.Scheme,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "aws-creds", Namespace: "default"},
+			Data: map[string][]byte{
+				"id":     []byte("DEFAULT"),
+				"secret": []byte("SECRET"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "aws-creds", Namespace: "auth"},
+			Data: map[string][]byte{
+				"id":     []byte("AUTH"),
+				"secret": []byte("SECRET"),
+			},
+		},
+	)
+	auth := func(namespace *string) *smv1alpha1.AWSStore {
+		return &smv1alpha1.AWSStore{
+			Region: aws.String("us-east-1"),
+			AuthSecretRef: &smv1alpha1.AWSAuth{
+				AccessKeyID: &smmeta.SecretKeySelector{
+					LocalObjectReference: smmeta.LocalObjectReference{Name: "aws-creds"},
+					Namespace:            namespace,
+					Key:                  "id",
+				},
+				SecretAccessKey: &smmeta.SecretKeySelector{
+					LocalObjectReference: smmeta.LocalObjectReference{Name: "aws-creds"},
+					Namespace:            namespace,
+					Key:                  "secret",
+				},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		store     smv1alpha1.GenericStore
+		wantKeyID string
+		wantErr   bool
+	}{
+		"store ignores namespace": {
+			store: &smv1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws", Namespace: "default"},
+				Spec:       smv1alpha1.SecretStoreSpec{AWS: auth(aws.String("auth"))},
+			},
+			wantKeyID: "DEFAULT",
+		},
+		"cluster store uses namespace": {
+			store: &smv1alpha1.ClusterSecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws"},
+				Spec:       smv1alpha1.SecretStoreSpec{AWS: auth(aws.String("auth"))},
+			},
+			wantKeyID: "AUTH",
+		},
+		"cluster store without namespace": {
+			store: &smv1alpha1.ClusterSecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws"},
+				Spec:       smv1alpha1.SecretStoreSpec{AWS: auth(nil)},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := &AWS{
+				kube:  kube,
+				store: tc.store,
+			}
+			cfg, err := a.newConfig(context.Background(), tc.store.GetSpec().AWS)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			creds, err := cfg.Credentials.Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if creds.AccessKeyID != tc.wantKeyID {
+				t.Errorf("expected access key %q, got %q", tc.wantKeyID, creds.AccessKeyID)
+			}
+		})
+	}
+}
+
+func TestNewConfigJWTAuth(t *testing.T) {
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret-manager",
+			Namespace: "default",
+			Annotations: map[string]string{
+				RoleARNAnnotation: "arn:aws:iam::123456789012:role/irsa",
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		auth      *smv1alpha1.AWSJWTAuth
+		wantRole  string
+		wantAud   []string
+		wantError bool
+	}{
+		"role from annotation": {
+			auth: &smv1alpha1.AWSJWTAuth{
+				ServiceAccountRef: smmeta.ServiceAccountSelector{Name: "secret-manager"},
+			},
+			wantRole: "arn:aws:iam::123456789012:role/irsa",
+			wantAud:  []string{DefaultJWTAudience},
+		},
+		"role from store": {
+			auth: &smv1alpha1.AWSJWTAuth{
+				ServiceAccountRef: smmeta.ServiceAccountSelector{
+					Name:      "secret-manager",
+					Audiences: []string{"custom"},
+				},
+				RoleARN: aws.String("arn:aws:iam::123456789012:role/store"),
+			},
+			wantRole: "arn:aws:iam::123456789012:role/store",
+			wantAud:  []string{"custom"},
+		},
+		"missing service account": {
+			auth: &smv1alpha1.AWSJWTAuth{
+				ServiceAccountRef: smmeta.ServiceAccountSelector{Name: "missing"},
+			},
+			wantError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			endpoint, calls := newTestSTS(t)
+			var gotAud []string
+			clientset := fakekube.NewSimpleClientset()
+			clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				req := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest)
+				gotAud = req.Spec.Audiences
+				return true, &authenticationv1.TokenRequest{
+					Status: authenticationv1.TokenRequestStatus{Token: "header.payload.signature"},
+				}, nil
+			})
+			a := &AWS{
+				kube: fakeclient.NewFakeClientWithScheme(scheme.Scheme, sa),
+				store: newTestStore(&smv1alpha1.AWSStore{
+					Endpoints: &smv1alpha1.AWSEndpoints{
+						STS: aws.String(endpoint),
+					},
+					JWTAuth: tc.auth,
+				}),
+				serviceAccounts: clientset.CoreV1(),
+			}
+
+			cfg, err := a.newConfig(context.Background(), a.store.GetSpec().AWS)
+			if tc.wantError {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := cfg.Credentials.Retrieve(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(*calls) != 1 {
+				t.Fatalf("expected 1 STS call, got %d", len(*calls))
+			}
+			form := (*calls)[0].Form
+			if got := form.Get("Action"); got != "AssumeRoleWithWebIdentity" {
+				t.Errorf("expected action %q, got %q", "AssumeRoleWithWebIdentity", got)
+			}
+			if got := form.Get("RoleArn"); got != tc.wantRole {
+				t.Errorf("expected role %q, got %q", tc.wantRole, got)
+			}
+			if got := form.Get("WebIdentityToken"); got != "header.payload.signature" {
+				t.Errorf("expected token %q, got %q", "header.payload.signature", got)
+			}
+			if !reflect.DeepEqual(gotAud, tc.wantAud) {
+				t.Errorf("expected audiences %v, got %v", tc.wantAud, gotAud)
+			}
+		})
+	}
+}
+
+func TestEndpointResolver(t *testing.T) {
+	r := NewEndpointResolver(&smv1alpha1.AWSEndpoints{
+		SecretsManager: aws.String("https://vpce-1234.secretsmanager.us-east-1.vpce.amazonaws.com"),
+		SSM:            aws.String("http://localstack:4566"),
+	}, nil)
+
+	tests := map[string]string{
+		"secretsmanager": "https://vpce-1234.secretsmanager.us-east-1.vpce.amazonaws.com",
+		"ssm":            "http://localstack:4566",
+		"sts":            "https://sts.us-east-1.amazonaws.com",
+	}
+	for service, want := range tests {
+		t.Run(service, func(t *testing.T) {
+			ep, err := r.ResolveEndpoint(service, "us-east-1")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ep.URL != want {
+				t.Errorf("expected endpoint %q, got %q", want, ep.URL)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+
+	"github.com/go-logr/logr"
+
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	ctxlog "github.com/itscontained/secret-manager/pkg/log"
+	"github.com/itscontained/secret-manager/pkg/store"
+	"github.com/itscontained/secret-manager/pkg/util/property"
+
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ store.Client = &ParameterStore{}
+var _ store.MetadataClient = &ParameterStore{}
+
+// ParameterStore reads parameters from AWS Systems Manager Parameter Store.
+// SecureString parameters are decrypted with the KMS key they were encrypted
+// with.
+type ParameterStore struct {
+	log    logr.Logger
+	client *ssm.Client
+}
+
+func (p *ParameterStore) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctrlclient.Client, namespace string) (store.Client, error) {
+	log := ctxlog.FromContext(ctx)
+	awsClient := &AWS{
+		kube:      kube,
+		store:     store,
+		log:       log,
+		namespace: namespace,
+	}
+
+	cfg, err := awsClient.newConfig(ctx, store.GetSpec().ParameterStore)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ParameterStore{
+		log:    log,
+		client: ssm.New(*cfg),
+	}, nil
+}
+
+func (p *ParameterStore) GetSecret(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, error) {
+	data, _, err := p.GetSecretWithMetadata(ctx, ref)
+	return data, err
+}
+
+func (p *ParameterStore) GetSecretWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, *store.SecretMetadata, error) {
+	if ref.Find != nil {
+		return nil, nil, fmt.Errorf("find is only supported in dataFrom")
+	}
+	version := ""
+	if ref.Version != nil {
+		version = *ref.Version
+	}
+	value, metadata, err := p.readParameter(ctx, ref.Name, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ref.Property == nil {
+		return value, metadata, nil
+	}
+	propValue, err := property.Get(value, *ref.Property)
+	if err != nil {
+		return nil, nil, err
+	}
+	return propValue, metadata, nil
+}
+
+func (p *ParameterStore) GetSecretMap(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, error) {
+	data, _, err := p.GetSecretMapWithMetadata(ctx, ref)
+	return data, err
+}
+
+// GetSecretMapWithMetadata returns the top level values of the JSON object
+// stored in the parameter, or with Find the parameters below the path given
+// by Name.
+func (p *ParameterStore) GetSecretMapWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, *store.SecretMetadata, error) {
+	if ref.Find != nil {
+		if ref.Version != nil {
+			return nil, nil, fmt.Errorf("version is not supported with find")
+		}
+		data, err := p.findParameters(ctx, ref.Name, ref.Find)
+		return data, nil, err
+	}
+	version := ""
+	if ref.Version != nil {
+		version = *ref.Version
+	}
+	value, metadata, err := p.readParameter(ctx, ref.Name, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := property.Map(value)
+	if errors.Is(err, property.ErrNotJSONObject) {
+		key := smv1alpha1.DefaultSecretKey
+		if ref.Property != nil {
+			key = *ref.Property
+		}
+		return map[string][]byte{key: value}, metadata, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, metadata, nil
+}
+
+// readParameter returns the decrypted value and the version of the
+// parameter. A numeric version selects the parameter version by number, any
+// other version by label.
+func (p *ParameterStore) readParameter(ctx context.Context, name, version string) ([]byte, *store.SecretMetadata, error) {
+	if version != "" {
+		name = name + ":" + version
+	}
+	req := p.client.GetParameterRequest(&ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting parameter %q: %w", name, err)
+	}
+	if resp.Parameter == nil || resp.Parameter.Value == nil {
+		return nil, nil, fmt.Errorf("parameter %q has no value", name)
+	}
+	metadata := &store.SecretMetadata{}
+	if resp.Parameter.Version != nil {
+		metadata.Version = strconv.FormatInt(*resp.Parameter.Version, 10)
+	}
+	return []byte(*resp.Parameter.Value), metadata, nil
+}
+
+// findParameters returns the decrypted values of the parameters below
+// basePath, keyed by their path relative to basePath with the path
+// separators replaced by the find separator.
+func (p *ParameterStore) findParameters(ctx context.Context, basePath string, find *smv1alpha1.FindReference) (map[string][]byte, error) {
+	separator := smv1alpha1.DefaultFindSeparator
+	if find.Separator != nil {
+		separator = *find.Separator
+	}
+	maxDepth := 0
+	if find.Recursive && find.MaxDepth != nil {
+		maxDepth = int(*find.MaxDepth)
+	}
+
+	basePath = "/" + strings.Trim(basePath, "/")
+	var tagged map[string]bool
+	if len(find.Tags) > 0 {
+		var err error
+		tagged, err = p.taggedParameters(ctx, basePath, find.Recursive, find.Tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+	req := p.client.GetParametersByPathRequest(&ssm.GetParametersByPathInput{
+		Path:           aws.String(basePath),
+		Recursive:      aws.Bool(find.Recursive),
+		WithDecryption: aws.Bool(true),
+	})
+	pager := ssm.NewGetParametersByPathPaginator(req)
+	var parameters []ssm.Parameter
+	for pager.Next(ctx) {
+		parameters = append(parameters, pager.CurrentPage().Parameters...)
+	}
+	if err := pager.Err(); err != nil {
+		return nil, fmt.Errorf("error getting parameters by path %q: %w", basePath, err)
+	}
+	sort.Slice(parameters, func(i, j int) bool {
+		return aws.StringValue(parameters[i].Name) < aws.StringValue(parameters[j].Name)
+	})
+
+	parameterMap := make(map[string][]byte, len(parameters))
+	for _, parameter := range parameters {
+		if tagged != nil && !tagged[aws.StringValue(parameter.Name)] {
+			continue
+		}
+		relPath := strings.Trim(strings.TrimPrefix(aws.StringValue(parameter.Name), basePath), "/")
+		if maxDepth > 0 && strings.Count(relPath, "/") >= maxDepth {
+			continue
+		}
+		key := strings.ReplaceAll(relPath, "/", separator)
+		if _, exists := parameterMap[key]; exists {
+			return nil, fmt.Errorf("parameter %q conflicts with another parameter for key %q", aws.StringValue(parameter.Name), key)
+		}
+		parameterMap[key] = []byte(aws.StringValue(parameter.Value))
+	}
+	return parameterMap, nil
+}
+
+// taggedParameters returns the names of the parameters below basePath having
+// all of the given tags. GetParametersByPath cannot filter by tags, so the
+// parameters are described with tag filters instead.
+func (p *ParameterStore) taggedParameters(ctx context.Context, basePath string, recursive bool, tags map[string]string) (map[string]bool, error) {
+	option := "OneLevel"
+	if recursive {
+		option = "Recursive"
+	}
+	filters := []ssm.ParameterStringFilter{
+		{
+			Key:    aws.String("Path"),
+			Option: aws.String(option),
+			Values: []string{basePath},
+		},
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		filters = append(filters, ssm.ParameterStringFilter{
+			Key:    aws.String("tag:" + k),
+			Option: aws.String("Equals"),
+			Values: []string{tags[k]},
+		})
+	}
+
+	req := p.client.DescribeParametersRequest(&ssm.DescribeParametersInput{
+		ParameterFilters: filters,
+	})
+	pager := ssm.NewDescribeParametersPaginator(req)
+	names := make(map[string]bool)
+	for pager.Next(ctx) {
+		for _, parameter := range pager.CurrentPage().Parameters {
+			names[aws.StringValue(parameter.Name)] = true
+		}
+	}
+	if err := pager.Err(); err != nil {
+		return nil, fmt.Errorf("error describing parameters tagged below path %q: %w", basePath, err)
+	}
+	return names, nil
+}
@@ -0,0 +1,376 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/defaults"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+)
+
+// fakeParameter is a version of a parameter served by newTestParameterStore.
+type fakeParameter struct {
+	Name    string
+	Value   string
+	Version int64
+	Labels  []string
+	// Tags are only returned by DescribeParameters.
+	Tags map[string]string `json:"-"`
+}
+
+// pageSize is the number of parameters returned per GetParametersByPath page.
+const pageSize = 2
+
+// newTestParameterStore returns a ParameterStore client sending requests to
+// a stand-in for the SSM API serving the given parameter versions, the last
+// version of a parameter being the latest.
+func newTestParameterStore(t *testing.T, parameters []fakeParameter) *ParameterStore {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch target := r.Header.Get("X-Amz-Target"); target {
+		case "AmazonSSM.GetParameter":
+			input := ssm.GetParameterInput{}
+			if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+				t.Errorf("error decoding request: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if !aws.BoolValue(input.WithDecryption) {
+				t.Errorf("expected parameter to be decrypted")
+			}
+			parameter, ok := selectParameter(parameters, aws.StringValue(input.Name))
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"__type": "ParameterNotFound"}`))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"Parameter": parameter,
+			})
+		case "AmazonSSM.GetParametersByPath":
+			input := ssm.GetParametersByPathInput{}
+			if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+				t.Errorf("error decoding request: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			var found []fakeParameter
+			latest := make(map[string]int)
+			prefix := strings.TrimSuffix(aws.StringValue(input.Path), "/") + "/"
+			for _, parameter := range parameters {
+				if !strings.HasPrefix(parameter.Name, prefix) {
+					continue
+				}
+				if !aws.BoolValue(input.Recursive) && strings.Contains(strings.TrimPrefix(parameter.Name, prefix), "/") {
+					continue
+				}
+				if i, ok := latest[parameter.Name]; ok {
+					found[i] = parameter
+					continue
+				}
+				latest[parameter.Name] = len(found)
+				found = append(found, parameter)
+			}
+			start, _ := strconv.Atoi(aws.StringValue(input.NextToken))
+			resp := map[string]interface{}{}
+			end := start + pageSize
+			if end < len(found) {
+				resp["NextToken"] = strconv.Itoa(end)
+			} else {
+				end = len(found)
+			}
+			resp["Parameters"] = found[start:end]
+			_ = json.NewEncoder(w).Encode(resp)
+		case "AmazonSSM.DescribeParameters":
+			input := ssm.DescribeParametersInput{}
+			if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+				t.Errorf("error decoding request: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			latest := make(map[string]fakeParameter)
+			var names []string
+			for _, parameter := range parameters {
+				if _, ok := latest[parameter.Name]; !ok {
+					names = append(names, parameter.Name)
+				}
+				latest[parameter.Name] = parameter
+			}
+			var found []map[string]string
+			for _, name := range names {
+				if describeMatches(latest[name], input.ParameterFilters) {
+					found = append(found, map[string]string{"Name": name})
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"Parameters": found,
+			})
+		default:
+			t.Errorf("unexpected operation %q", target)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := defaults.Config()
+	cfg.Region = "us-east-1"
+	cfg.Credentials = aws.NewStaticCredentialsProvider("AKID", "SECRET", "")
+	cfg.EndpointResolver = aws.ResolveWithEndpointURL(srv.URL)
+	cfg.Retryer = aws.NoOpRetryer{}
+
+	return &ParameterStore{
+		client: ssm.New(cfg),
+	}
+}
+
+// describeMatches returns whether the parameter matches all of the Path and
+// tag filters of a DescribeParameters request.
+func describeMatches(parameter fakeParameter, filters []ssm.ParameterStringFilter) bool {
+	for _, filter := range filters {
+		key := aws.StringValue(filter.Key)
+		switch {
+		case key == "Path":
+			prefix := strings.TrimSuffix(filter.Values[0], "/") + "/"
+			if !strings.HasPrefix(parameter.Name, prefix) {
+				return false
+			}
+			if aws.StringValue(filter.Option) == "OneLevel" && strings.Contains(strings.TrimPrefix(parameter.Name, prefix), "/") {
+				return false
+			}
+		case strings.HasPrefix(key, "tag:"):
+			if v, ok := parameter.Tags[strings.TrimPrefix(key, "tag:")]; !ok || v != filter.Values[0] {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// selectParameter returns the parameter version selected by name, which may
+// be suffixed by a version number or label.
+func selectParameter(parameters []fakeParameter, name string) (fakeParameter, bool) {
+	selector := ""
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name, selector = name[:i], name[i+1:]
+	}
+	var selected fakeParameter
+	ok := false
+	for _, parameter := range parameters {
+		if parameter.Name != name {
+			continue
+		}
+		if selector == "" || selector == strconv.FormatInt(parameter.Version, 10) {
+			selected, ok = parameter, true
+			continue
+		}
+		for _, label := range parameter.Labels {
+			if label == selector {
+				selected, ok = parameter, true
+			}
+		}
+	}
+	return selected, ok
+}
+
+func TestParameterStoreGetSecret(t *testing.T) {
+	p := newTestParameterStore(t, []fakeParameter{
+		{Name: "/app/db/password", Value: "old", Version: 1, Labels: []string{"previous"}},
+		{Name: "/app/db/password", Value: "abc123", Version: 2},
+		{Name: "/app/config", Value: `{"db": {"host": "db.local", "port": 5432}}`, Version: 1},
+	})
+
+	tests := map[string]struct {
+		ref         smv1alpha1.RemoteReference
+		want        []byte
+		wantVersion string
+		wantErr     bool
+	}{
+		"latest": {
+			ref:         smv1alpha1.RemoteReference{Name: "/app/db/password"},
+			want:        []byte("abc123"),
+			wantVersion: "2",
+		},
+		"version number": {
+			ref:         smv1alpha1.RemoteReference{Name: "/app/db/password", Version: smmeta.String("1")},
+			want:        []byte("old"),
+			wantVersion: "1",
+		},
+		"version label": {
+			ref:         smv1alpha1.RemoteReference{Name: "/app/db/password", Version: smmeta.String("previous")},
+			want:        []byte("old"),
+			wantVersion: "1",
+		},
+		"json property": {
+			ref:         smv1alpha1.RemoteReference{Name: "/app/config", Property: smmeta.String("db.port")},
+			want:        []byte("5432"),
+			wantVersion: "1",
+		},
+		"unknown label": {
+			ref:     smv1alpha1.RemoteReference{Name: "/app/db/password", Version: smmeta.String("missing")},
+			wantErr: true,
+		},
+		"not found": {
+			ref:     smv1alpha1.RemoteReference{Name: "/app/missing"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, meta, err := p.GetSecretWithMetadata(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+			if meta.Version != tc.wantVersion {
+				t.Errorf("expected version %q, got %q", tc.wantVersion, meta.Version)
+			}
+		})
+	}
+}
+
+func TestParameterStoreGetSecretMap(t *testing.T) {
+	p := newTestParameterStore(t, []fakeParameter{
+		{Name: "/app/config", Value: `{"host": "db.local", "port": 5432}`, Version: 1},
+		{Name: "/app/db/username", Value: "bob", Version: 1},
+		{Name: "/app/db/password", Value: "old", Version: 1},
+		{Name: "/app/db/password", Value: "abc123", Version: 2, Tags: map[string]string{"team": "a", "env": "prod"}},
+		{Name: "/app/db/replica/password", Value: "def456", Version: 1, Tags: map[string]string{"team": "a", "env": "prod"}},
+		{Name: "/app/token", Value: "xyz789", Version: 1, Tags: map[string]string{"team": "a", "env": "dev"}},
+	})
+
+	tests := map[string]struct {
+		ref  smv1alpha1.RemoteReference
+		want map[string][]byte
+	}{
+		"json parameter": {
+			ref: smv1alpha1.RemoteReference{Name: "/app/config"},
+			want: map[string][]byte{
+				"host": []byte("db.local"),
+				"port": []byte("5432"),
+			},
+		},
+		"plain parameter": {
+			ref: smv1alpha1.RemoteReference{Name: "/app/token"},
+			want: map[string][]byte{
+				smv1alpha1.DefaultSecretKey: []byte("xyz789"),
+			},
+		},
+		"find": {
+			ref: smv1alpha1.RemoteReference{
+				Name: "/app/db",
+				Find: &smv1alpha1.FindReference{},
+			},
+			want: map[string][]byte{
+				"username": []byte("bob"),
+				"password": []byte("abc123"),
+			},
+		},
+		"find recursive": {
+			ref: smv1alpha1.RemoteReference{
+				Name: "/app",
+				Find: &smv1alpha1.FindReference{
+					Recursive: true,
+					Separator: smmeta.String("."),
+				},
+			},
+			want: map[string][]byte{
+				"config":              []byte(`{"host": "db.local", "port": 5432}`),
+				"db.username":         []byte("bob"),
+				"db.password":         []byte("abc123"),
+				"db.replica.password": []byte("def456"),
+				"token":               []byte("xyz789"),
+			},
+		},
+		"find recursive with max depth": {
+			ref: smv1alpha1.RemoteReference{
+				Name: "/app/",
+				Find: &smv1alpha1.FindReference{
+					Recursive: true,
+					MaxDepth:  int32Ptr(2),
+				},
+			},
+			want: map[string][]byte{
+				"config":      []byte(`{"host": "db.local", "port": 5432}`),
+				"db_username": []byte("bob"),
+				"db_password": []byte("abc123"),
+				"token":       []byte("xyz789"),
+			},
+		},
+		"find with tags": {
+			ref: smv1alpha1.RemoteReference{
+				Name: "/app/db",
+				Find: &smv1alpha1.FindReference{
+					Tags: map[string]string{"team": "a"},
+				},
+			},
+			want: map[string][]byte{
+				"password": []byte("abc123"),
+			},
+		},
+		"find recursive with tags": {
+			ref: smv1alpha1.RemoteReference{
+				Name: "/app",
+				Find: &smv1alpha1.FindReference{
+					Recursive: true,
+					Tags:      map[string]string{"team": "a", "env": "prod"},
+				},
+			},
+			want: map[string][]byte{
+				"db_password":         []byte("abc123"),
+				"db_replica_password": []byte("def456"),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := p.GetSecretMap(context.Background(), tc.ref)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
@@ -0,0 +1,196 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurekv
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	"github.com/itscontained/secret-manager/pkg/util/serviceaccount"
+	"github.com/itscontained/secret-manager/pkg/util/storeref"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// ClientIDAnnotation is the annotation of a Kubernetes ServiceAccount
+	// giving the application id of the Azure identity it is federated with.
+	ClientIDAnnotation = "azure.workload.identity/client-id"
+	// TenantIDAnnotation is the annotation of a Kubernetes ServiceAccount
+	// giving the tenant of the Azure identity it is federated with.
+	TenantIDAnnotation = "azure.workload.identity/tenant-id"
+	// DefaultJWTAudience is the audience of the ServiceAccount tokens
+	// exchanged for Azure AD access tokens.
+	DefaultJWTAudience = "api://AzureADTokenExchange"
+
+	defaultAuthorityHost = "https://login.microsoftonline.com/"
+	jwtBearerType        = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+)
+
+// keyVaultScope returns the scope of access tokens for the Key Vault at
+// vaultURL, which is the host of the vault without the vault name, e.g.
+// https://vault.azure.net/.default for https://my-vault.vault.azure.net.
+func keyVaultScope(vaultURL string) (string, error) {
+	u, err := url.Parse(vaultURL)
+	if err != nil {
+		return "", fmt.Errorf("error parsing vaultURL: %w", err)
+	}
+	i := strings.Index(u.Hostname(), ".")
+	if u.Scheme == "" || i < 0 {
+		return "", fmt.Errorf("vaultURL %q is not the URL of a Key Vault", vaultURL)
+	}
+	return fmt.Sprintf("%s://%s/.default", u.Scheme, u.Hostname()[i+1:]), nil
+}
+
+// tokenSource returns a source of Key Vault access tokens authenticated as
+// configured in the store.
+func (a *AzureKV) tokenSource(ctx context.Context, spec *smv1alpha1.AzureKVStore) (oauth2.TokenSource, error) {
+	// TODO: Validating Webhook Candidate
+	if spec.AuthSecretRef != nil && spec.WorkloadIdentity != nil {
+		return nil, fmt.Errorf("multiple authentication methods configured")
+	}
+	scope, err := keyVaultScope(a.vaultURL)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case spec.AuthSecretRef != nil:
+		a.log.V(1).Info("service principal authentication defined")
+		return a.servicePrincipalTokenSource(ctx, spec, scope)
+	case spec.WorkloadIdentity != nil:
+		a.log.V(1).Info("workload identity authentication defined")
+		return a.workloadIdentityTokenSource(ctx, spec, scope)
+	default:
+		return nil, fmt.Errorf("missing authSecretRef or workloadIdentity in store config")
+	}
+}
+
+func (a *AzureKV) servicePrincipalTokenSource(ctx context.Context, spec *smv1alpha1.AzureKVStore, scope string) (oauth2.TokenSource, error) {
+	if spec.TenantID == nil {
+		return nil, fmt.Errorf("missing tenantID in store config")
+	}
+	clientID, err := storeref.SecretKey(ctx, a.kube, a.store, "clientID", spec.AuthSecretRef.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	clientSecret, err := storeref.SecretKey(ctx, a.kube, a.store, "clientSecret", spec.AuthSecretRef.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     tokenURL(spec, *spec.TenantID),
+		Scopes:       []string{scope},
+		AuthStyle:    oauth2.AuthStyleInParams,
+	}
+	return cfg.TokenSource(oauth2Context()), nil
+}
+
+func (a *AzureKV) workloadIdentityTokenSource(ctx context.Context, spec *smv1alpha1.AzureKVStore, scope string) (oauth2.TokenSource, error) {
+	wi := spec.WorkloadIdentity
+	namespace, err := storeref.Namespace(a.store, "serviceAccountRef", wi.ServiceAccountRef.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	ref := types.NamespacedName{
+		Namespace: namespace,
+		Name:      wi.ServiceAccountRef.Name,
+	}
+	sa := &corev1.ServiceAccount{}
+	if err := a.kube.Get(ctx, ref, sa); err != nil {
+		return nil, err
+	}
+
+	clientID := sa.Annotations[ClientIDAnnotation]
+	if wi.ClientID != nil {
+		clientID = *wi.ClientID
+	}
+	if clientID == "" {
+		return nil, fmt.Errorf("missing clientID in store config or %s annotation of service account %s", ClientIDAnnotation, ref)
+	}
+	tenantID := sa.Annotations[TenantIDAnnotation]
+	if spec.TenantID != nil {
+		tenantID = *spec.TenantID
+	}
+	if tenantID == "" {
+		return nil, fmt.Errorf("missing tenantID in store config or %s annotation of service account %s", TenantIDAnnotation, ref)
+	}
+	if a.serviceAccounts == nil {
+		client, err := serviceaccount.Client()
+		if err != nil {
+			return nil, err
+		}
+		a.serviceAccounts = client
+	}
+
+	audiences := wi.ServiceAccountRef.Audiences
+	if len(audiences) == 0 {
+		audiences = []string{DefaultJWTAudience}
+	}
+	return oauth2.ReuseTokenSource(nil, &workloadIdentityTokenSource{
+		serviceAccounts: a.serviceAccounts,
+		ref:             ref,
+		audiences:       audiences,
+		config: clientcredentials.Config{
+			ClientID:  clientID,
+			TokenURL:  tokenURL(spec, tenantID),
+			Scopes:    []string{scope},
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}), nil
+}
+
+// workloadIdentityTokenSource requests access tokens with a fresh
+// ServiceAccount token as client assertion.
+type workloadIdentityTokenSource struct {
+	serviceAccounts typedcorev1.ServiceAccountsGetter
+	ref             types.NamespacedName
+	audiences       []string
+	config          clientcredentials.Config
+}
+
+func (w *workloadIdentityTokenSource) Token() (*oauth2.Token, error) {
+	ctx := oauth2Context()
+	jwt, err := serviceaccount.Token(ctx, w.serviceAccounts, w.ref.Namespace, w.ref.Name, w.audiences)
+	if err != nil {
+		return nil, err
+	}
+	cfg := w.config
+	cfg.EndpointParams = url.Values{
+		"client_assertion_type": {jwtBearerType},
+		"client_assertion":      {jwt},
+	}
+	return cfg.Token(ctx)
+}
+
+// tokenURL returns the token endpoint of the tenant.
+func tokenURL(spec *smv1alpha1.AzureKVStore, tenantID string) string {
+	authorityHost := defaultAuthorityHost
+	if spec.AuthorityHost != nil {
+		authorityHost = *spec.AuthorityHost
+	}
+	return strings.TrimSuffix(authorityHost, "/") + "/" + tenantID + "/oauth2/v2.0/token"
+}
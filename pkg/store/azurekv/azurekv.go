@@ -0,0 +1,350 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurekv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	ctxlog "github.com/itscontained/secret-manager/pkg/log"
+	"github.com/itscontained/secret-manager/pkg/store"
+	"github.com/itscontained/secret-manager/pkg/store/schema"
+	"github.com/itscontained/secret-manager/pkg/util/property"
+
+	"golang.org/x/oauth2"
+
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ store.Client = &AzureKV{}
+var _ store.MetadataClient = &AzureKV{}
+
+const (
+	objectTypeSecret = "secret"
+	objectTypeKey    = "key"
+	objectTypeCert   = "cert"
+
+	// propertyJWK exports a key as JSON Web Key.
+	propertyJWK = "jwk"
+	// propertyPEM and propertyPFX export a certificate as PEM or PKCS#12.
+	propertyPEM = "pem"
+	propertyPFX = "pfx"
+
+	apiVersion = "7.1"
+
+	// requestTimeout bounds requests to Key Vault and Azure AD.
+	requestTimeout = 30 * time.Second
+)
+
+// AzureKV reads secrets, keys and certificates from Azure Key Vault.
+type AzureKV struct {
+	kube     ctrlclient.Client
+	store    smv1alpha1.GenericStore
+	log      logr.Logger
+	vaultURL string
+	client   *http.Client
+
+	serviceAccounts typedcorev1.ServiceAccountsGetter
+}
+
+func init() {
+	schema.Register(&AzureKV{}, &smv1alpha1.SecretStoreSpec{
+		AzureKV: &smv1alpha1.AzureKVStore{},
+	})
+}
+
+func (a *AzureKV) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctrlclient.Client, namespace string) (store.Client, error) {
+	log := ctxlog.FromContext(ctx)
+	spec := store.GetSpec().AzureKV
+	azClient := &AzureKV{
+		kube:     kube,
+		store:    store,
+		log:      log,
+		vaultURL: strings.TrimSuffix(spec.VaultURL, "/"),
+	}
+	ts, err := azClient.tokenSource(ctx, spec)
+	if err != nil {
+		log.Error(err, "could not create new azure key vault client")
+		return nil, err
+	}
+	azClient.client = oauth2.NewClient(oauth2Context(), ts)
+	azClient.client.Timeout = requestTimeout
+	return azClient, nil
+}
+
+// oauth2Context returns the context of token requests, which are sent
+// outside of any reconcile.
+func oauth2Context() context.Context {
+	return context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Timeout: requestTimeout})
+}
+
+func (a *AzureKV) GetSecret(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, error) {
+	data, _, err := a.GetSecretWithMetadata(ctx, ref)
+	return data, err
+}
+
+func (a *AzureKV) GetSecretWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, *store.SecretMetadata, error) {
+	if ref.Find != nil {
+		return nil, nil, fmt.Errorf("find is not supported by azure key vault")
+	}
+	objectType, name, err := parseObjectName(ref.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	version := ""
+	if ref.Version != nil {
+		version = *ref.Version
+	}
+	prop := ""
+	if ref.Property != nil {
+		prop = *ref.Property
+	}
+
+	switch objectType {
+	case objectTypeKey:
+		return a.readKey(ctx, name, version, prop)
+	case objectTypeCert:
+		return a.readCertificate(ctx, name, version, prop)
+	default:
+		return a.readSecret(ctx, name, version, ref.Property)
+	}
+}
+
+func (a *AzureKV) GetSecretMap(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, error) {
+	data, _, err := a.GetSecretMapWithMetadata(ctx, ref)
+	return data, err
+}
+
+// GetSecretMapWithMetadata returns the top level values of the JSON object
+// stored in a secret, or the certificate chain and private key of a
+// certificate under the keys of a kubernetes.io/tls Secret.
+func (a *AzureKV) GetSecretMapWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, *store.SecretMetadata, error) {
+	if ref.Find != nil {
+		return nil, nil, fmt.Errorf("find is not supported by azure key vault")
+	}
+	objectType, name, err := parseObjectName(ref.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	version := ""
+	if ref.Version != nil {
+		version = *ref.Version
+	}
+
+	switch objectType {
+	case objectTypeKey:
+		return nil, nil, fmt.Errorf("keys are not supported in dataFrom")
+	case objectTypeCert:
+		bundle, metadata, err := a.getCertificateBundle(ctx, name, version)
+		if err != nil {
+			return nil, nil, err
+		}
+		certs, key, err := bundle.pem()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error converting certificate %q: %w", name, err)
+		}
+		return map[string][]byte{
+			"tls.crt": certs,
+			"tls.key": key,
+		}, metadata, nil
+	}
+
+	value, metadata, err := a.readSecret(ctx, name, version, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := property.Map(value)
+	if errors.Is(err, property.ErrNotJSONObject) {
+		key := smv1alpha1.DefaultSecretKey
+		if ref.Property != nil {
+			key = *ref.Property
+		}
+		return map[string][]byte{key: value}, metadata, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, metadata, nil
+}
+
+// secretBundle is a secret as returned by the Key Vault API.
+type secretBundle struct {
+	ID          string `json:"id"`
+	Value       string `json:"value"`
+	ContentType string `json:"contentType"`
+}
+
+// keyBundle is a key as returned by the Key Vault API.
+type keyBundle struct {
+	Key jsonWebKey `json:"key"`
+}
+
+// certificateBundle is a certificate as returned by the Key Vault API.
+type certificateBundle struct {
+	ID string `json:"id"`
+}
+
+func (a *AzureKV) readSecret(ctx context.Context, name, version string, prop *string) ([]byte, *store.SecretMetadata, error) {
+	secret := &secretBundle{}
+	if err := a.get(ctx, objectPath("secrets", name, version), secret); err != nil {
+		return nil, nil, fmt.Errorf("error getting secret %q: %w", name, err)
+	}
+	metadata := &store.SecretMetadata{Version: objectVersion(secret.ID)}
+	if prop == nil {
+		return []byte(secret.Value), metadata, nil
+	}
+	value, err := property.Get([]byte(secret.Value), *prop)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, metadata, nil
+}
+
+func (a *AzureKV) readKey(ctx context.Context, name, version, prop string) ([]byte, *store.SecretMetadata, error) {
+	key := &keyBundle{}
+	if err := a.get(ctx, objectPath("keys", name, version), key); err != nil {
+		return nil, nil, fmt.Errorf("error getting key %q: %w", name, err)
+	}
+	metadata := &store.SecretMetadata{Version: objectVersion(key.Key.KeyID)}
+	switch prop {
+	case "":
+		data, err := key.Key.publicKeyPEM()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error exporting key %q: %w", name, err)
+		}
+		return data, metadata, nil
+	case propertyJWK:
+		data, err := json.Marshal(key.Key)
+		if err != nil {
+			return nil, nil, err
+		}
+		return data, metadata, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported property %q of key %q", prop, name)
+	}
+}
+
+func (a *AzureKV) readCertificate(ctx context.Context, name, version, prop string) ([]byte, *store.SecretMetadata, error) {
+	bundle, metadata, err := a.getCertificateBundle(ctx, name, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch prop {
+	case "", propertyPEM:
+		certs, key, err := bundle.pem()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error converting certificate %q: %w", name, err)
+		}
+		return append(certs, key...), metadata, nil
+	case propertyPFX:
+		data, err := bundle.pfx()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error converting certificate %q: %w", name, err)
+		}
+		return data, metadata, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported property %q of certificate %q", prop, name)
+	}
+}
+
+// getCertificateBundle returns the secret backing the certificate, which
+// holds the certificate chain along with the private key.
+func (a *AzureKV) getCertificateBundle(ctx context.Context, name, version string) (*secretBundle, *store.SecretMetadata, error) {
+	cert := &certificateBundle{}
+	if err := a.get(ctx, objectPath("certificates", name, version), cert); err != nil {
+		return nil, nil, fmt.Errorf("error getting certificate %q: %w", name, err)
+	}
+	// the backing secret shares the name and version of the certificate
+	version = objectVersion(cert.ID)
+	secret := &secretBundle{}
+	if err := a.get(ctx, objectPath("secrets", name, version), secret); err != nil {
+		return nil, nil, fmt.Errorf("error getting secret of certificate %q: %w", name, err)
+	}
+	return secret, &store.SecretMetadata{Version: version}, nil
+}
+
+// get decodes the object at path of the vault into out.
+func (a *AzureKV) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.vaultURL+"/"+path+"?api-version="+apiVersion, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		kvErr := struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}{}
+		if err := json.Unmarshal(body, &kvErr); err == nil && kvErr.Error.Code != "" {
+			return fmt.Errorf("%s: %s: %s", resp.Status, kvErr.Error.Code, kvErr.Error.Message)
+		}
+		return fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// parseObjectName splits a reference name into the object type and the name
+// of the object. Names without type refer to secrets.
+func parseObjectName(ref string) (string, string, error) {
+	objectType, name := objectTypeSecret, ref
+	if i := strings.Index(ref, "/"); i >= 0 {
+		objectType, name = ref[:i], ref[i+1:]
+	}
+	switch objectType {
+	case objectTypeSecret, objectTypeKey, objectTypeCert:
+	default:
+		return "", "", fmt.Errorf("unknown object type %q of %q", objectType, ref)
+	}
+	if name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("invalid object name %q", ref)
+	}
+	return objectType, name, nil
+}
+
+// objectPath returns the path of an object version in the vault, or of the
+// current version if version is empty.
+func objectPath(collection, name, version string) string {
+	if version == "" {
+		return collection + "/" + name
+	}
+	return collection + "/" + name + "/" + version
+}
+
+// objectVersion returns the version of the object identified by id, e.g.
+// "https://my-vault.vault.azure.net/secrets/my-secret/<version>".
+func objectVersion(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
+}
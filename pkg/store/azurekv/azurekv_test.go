@@ -0,0 +1,613 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurekv
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+
+	"golang.org/x/oauth2"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// newTestAzureKV returns an AzureKV client sending requests to a stand-in for
+// the Key Vault API serving the given objects by path, e.g.
+// "secrets/my-secret" for the current version of a secret. The ids of
+// objects are set to their path.
+func newTestAzureKV(t *testing.T, objects map[string]map[string]interface{}) *AzureKV {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("api-version") != apiVersion {
+			t.Errorf("expected api version %q, got %q", apiVersion, r.URL.Query().Get("api-version"))
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer kv-token" {
+			t.Errorf("unexpected authorization %q", auth)
+		}
+		object, ok := objects[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": {"code": "SecretNotFound", "message": "not found"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(object)
+	}))
+	t.Cleanup(srv.Close)
+
+	return &AzureKV{
+		log:      ctrl.Log,
+		vaultURL: srv.URL,
+		client:   oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "kv-token"})),
+	}
+}
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("error reading test data: %v", err)
+	}
+	return data
+}
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func TestGetSecret(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyPEM := func(pub interface{}) []byte {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+	certPEM := readTestdata(t, "cert.pem")
+	keyPEM := readTestdata(t, "key.pem")
+	pfx := readTestdata(t, "cert.pfx")
+
+	ecJWK := map[string]interface{}{
+		"kid": "keys/ec/3",
+		"kty": "EC-HSM",
+		"crv": "P-256",
+		"x":   encodeBigInt(ecKey.X),
+		"y":   encodeBigInt(ecKey.Y),
+	}
+	a := newTestAzureKV(t, map[string]map[string]interface{}{
+		"secrets/plain":    {"id": "secrets/plain/2", "value": "abc123"},
+		"secrets/plain/1":  {"id": "secrets/plain/1", "value": "old"},
+		"secrets/json":     {"id": "secrets/json/1", "value": `{"db": {"password": "abc123"}}`},
+		"keys/rsa":         {"key": map[string]interface{}{"kid": "keys/rsa/1", "kty": "RSA", "n": encodeBigInt(rsaKey.N), "e": "AQAB"}},
+		"keys/ec":          {"key": ecJWK},
+		"keys/oct":         {"key": map[string]interface{}{"kid": "keys/oct/1", "kty": "oct"}},
+		"certificates/pfx": {"id": "certificates/pfx/5"},
+		"secrets/pfx/5": {
+			"id":          "secrets/pfx/5",
+			"value":       base64.StdEncoding.EncodeToString(pfx),
+			"contentType": contentTypePKCS12,
+		},
+		"certificates/pem": {"id": "certificates/pem/7"},
+		"secrets/pem/7": {
+			"id":          "secrets/pem/7",
+			"value":       string(certPEM) + string(keyPEM),
+			"contentType": contentTypePEM,
+		},
+	})
+
+	tests := map[string]struct {
+		ref         smv1alpha1.RemoteReference
+		want        []byte
+		wantVersion string
+		wantErr     bool
+	}{
+		"secret": {
+			ref:         smv1alpha1.RemoteReference{Name: "plain"},
+			want:        []byte("abc123"),
+			wantVersion: "2",
+		},
+		"secret with type": {
+			ref:         smv1alpha1.RemoteReference{Name: "secret/plain"},
+			want:        []byte("abc123"),
+			wantVersion: "2",
+		},
+		"secret version": {
+			ref:         smv1alpha1.RemoteReference{Name: "plain", Version: smmeta.String("1")},
+			want:        []byte("old"),
+			wantVersion: "1",
+		},
+		"secret property": {
+			ref:         smv1alpha1.RemoteReference{Name: "json", Property: smmeta.String("db.password")},
+			want:        []byte("abc123"),
+			wantVersion: "1",
+		},
+		"rsa key": {
+			ref:         smv1alpha1.RemoteReference{Name: "key/rsa"},
+			want:        publicKeyPEM(&rsaKey.PublicKey),
+			wantVersion: "1",
+		},
+		"ec key": {
+			ref:         smv1alpha1.RemoteReference{Name: "key/ec"},
+			want:        publicKeyPEM(&ecKey.PublicKey),
+			wantVersion: "3",
+		},
+		"ec key jwk": {
+			ref: smv1alpha1.RemoteReference{Name: "key/ec", Property: smmeta.String("jwk")},
+			want: mustMarshal(t, &jsonWebKey{
+				KeyID:   "keys/ec/3",
+				KeyType: "EC-HSM",
+				Curve:   "P-256",
+				X:       encodeBigInt(ecKey.X),
+				Y:       encodeBigInt(ecKey.Y),
+			}),
+			wantVersion: "3",
+		},
+		"symmetric key": {
+			ref:     smv1alpha1.RemoteReference{Name: "key/oct"},
+			wantErr: true,
+		},
+		"pfx certificate as pfx": {
+			ref:         smv1alpha1.RemoteReference{Name: "cert/pfx", Property: smmeta.String("pfx")},
+			want:        pfx,
+			wantVersion: "5",
+		},
+		"unknown property": {
+			ref:     smv1alpha1.RemoteReference{Name: "cert/pem", Property: smmeta.String("der")},
+			wantErr: true,
+		},
+		"unknown type": {
+			ref:     smv1alpha1.RemoteReference{Name: "blob/plain"},
+			wantErr: true,
+		},
+		"not found": {
+			ref:     smv1alpha1.RemoteReference{Name: "missing"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, meta, err := a.GetSecretWithMetadata(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+			if meta.Version != tc.wantVersion {
+				t.Errorf("expected version %q, got %q", tc.wantVersion, meta.Version)
+			}
+		})
+	}
+
+	// certificates are converted to the certificate chain followed by the
+	// PKCS#8 encoded private key
+	wantKey, err := x509.ParseECPrivateKey(mustDecodePEM(t, keyPEM).Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"cert/pfx", "cert/pem"} {
+		got, err := a.GetSecret(context.Background(), smv1alpha1.RemoteReference{Name: name})
+		if err != nil {
+			t.Fatalf("unexpected error getting %s: %v", name, err)
+		}
+		if !bytes.HasPrefix(got, certPEM) {
+			t.Errorf("expected %s to start with certificate, got %q", name, got)
+		}
+		block := mustDecodePEM(t, bytes.TrimPrefix(got, certPEM))
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			t.Fatalf("expected pkcs8 private key of %s: %v", name, err)
+		}
+		if !reflect.DeepEqual(key, wantKey) {
+			t.Errorf("unexpected private key of %s", name)
+		}
+	}
+
+	// certificates stored as PEM are encoded to PKCS#12 archives
+	got, err := a.GetSecret(context.Background(), smv1alpha1.RemoteReference{Name: "cert/pem", Property: smmeta.String("pfx")})
+	if err != nil {
+		t.Fatalf("unexpected error getting pem certificate as pfx: %v", err)
+	}
+	key, cert, err := pkcs12.Decode(got, "")
+	if err != nil {
+		t.Fatalf("expected pkcs12 archive: %v", err)
+	}
+	if !bytes.Equal(cert.Raw, mustDecodePEM(t, certPEM).Bytes) {
+		t.Errorf("unexpected certificate in pkcs12 archive")
+	}
+	if !reflect.DeepEqual(key, wantKey) {
+		t.Errorf("unexpected private key in pkcs12 archive")
+	}
+}
+
+func TestGetSecretMap(t *testing.T) {
+	certPEM := readTestdata(t, "cert.pem")
+	a := newTestAzureKV(t, map[string]map[string]interface{}{
+		"secrets/plain":    {"id": "secrets/plain/2", "value": "abc123"},
+		"secrets/json":     {"id": "secrets/json/1", "value": `{"username": "bob", "db": {"password": "abc123"}}`},
+		"certificates/pfx": {"id": "certificates/pfx/5"},
+		"secrets/pfx/5": {
+			"id":          "secrets/pfx/5",
+			"value":       base64.StdEncoding.EncodeToString(readTestdata(t, "cert.pfx")),
+			"contentType": contentTypePKCS12,
+		},
+	})
+
+	tests := map[string]struct {
+		ref     smv1alpha1.RemoteReference
+		want    map[string][]byte
+		wantErr bool
+	}{
+		"json": {
+			ref: smv1alpha1.RemoteReference{Name: "json"},
+			want: map[string][]byte{
+				"username": []byte("bob"),
+				"db":       []byte(`{"password":"abc123"}`),
+			},
+		},
+		"plain": {
+			ref: smv1alpha1.RemoteReference{Name: "plain"},
+			want: map[string][]byte{
+				smv1alpha1.DefaultSecretKey: []byte("abc123"),
+			},
+		},
+		"plain with key from property": {
+			ref: smv1alpha1.RemoteReference{Name: "plain", Property: smmeta.String("token")},
+			want: map[string][]byte{
+				"token": []byte("abc123"),
+			},
+		},
+		"key": {
+			ref:     smv1alpha1.RemoteReference{Name: "key/rsa"},
+			wantErr: true,
+		},
+		"find": {
+			ref:     smv1alpha1.RemoteReference{Name: "plain", Find: &smv1alpha1.FindReference{}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := a.GetSecretMap(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	got, err := a.GetSecretMap(context.Background(), smv1alpha1.RemoteReference{Name: "cert/pfx"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got["tls.crt"], certPEM) {
+		t.Errorf("expected tls.crt %q, got %q", certPEM, got["tls.crt"])
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(mustDecodePEM(t, got["tls.key"]).Bytes); err != nil {
+		t.Errorf("expected pkcs8 private key in tls.key: %v", err)
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+	var tokenRequests []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/my-tenant/oauth2/v2.0/token" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("error parsing token request: %v", err)
+		}
+		form := make(map[string]string)
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		tokenRequests = append(tokenRequests, form)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "kv-token", "token_type": "Bearer", "expires_in": 3600}`))
+	}))
+	defer srv.Close()
+
+	objects := []runtime.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "sp", Namespace: "default"},
+			Data: map[string][]byte{
+				"id":     []byte("sp-client"),
+				"secret": []byte("sp-secret\n"),
+			},
+		},
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "annotated",
+				Namespace: "default",
+				Annotations: map[string]string{
+					ClientIDAnnotation: "wi-client",
+					TenantIDAnnotation: "my-tenant",
+				},
+			},
+		},
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "plain", Namespace: "default"},
+		},
+	}
+	servicePrincipal := &smv1alpha1.AzureKVAuth{
+		ClientID:     smmeta.SecretKeySelector{LocalObjectReference: smmeta.LocalObjectReference{Name: "sp"}, Key: "id"},
+		ClientSecret: smmeta.SecretKeySelector{LocalObjectReference: smmeta.LocalObjectReference{Name: "sp"}, Key: "secret"},
+	}
+
+	tests := map[string]struct {
+		clusterScoped bool
+		spec          smv1alpha1.AzureKVStore
+		wantRequest   map[string]string
+		wantAudiences []string
+		wantErr       bool
+	}{
+		"service principal": {
+			spec: smv1alpha1.AzureKVStore{
+				TenantID:      smmeta.String("my-tenant"),
+				AuthSecretRef: servicePrincipal,
+			},
+			wantRequest: map[string]string{
+				"grant_type":    "client_credentials",
+				"client_id":     "sp-client",
+				"client_secret": "sp-secret",
+				"scope":         "https://vault.azure.net/.default",
+			},
+		},
+		"service principal without tenant": {
+			spec:    smv1alpha1.AzureKVStore{AuthSecretRef: servicePrincipal},
+			wantErr: true,
+		},
+		"service principal of cluster store without namespace": {
+			clusterScoped: true,
+			spec: smv1alpha1.AzureKVStore{
+				TenantID:      smmeta.String("my-tenant"),
+				AuthSecretRef: servicePrincipal,
+			},
+			wantErr: true,
+		},
+		"workload identity from annotations": {
+			spec: smv1alpha1.AzureKVStore{
+				WorkloadIdentity: &smv1alpha1.AzureWorkloadIdentity{
+					ServiceAccountRef: smmeta.ServiceAccountSelector{Name: "annotated"},
+				},
+			},
+			wantRequest: map[string]string{
+				"grant_type":            "client_credentials",
+				"client_id":             "wi-client",
+				"client_assertion_type": jwtBearerType,
+				"client_assertion":      "k8s-jwt",
+				"scope":                 "https://vault.azure.net/.default",
+			},
+			wantAudiences: []string{DefaultJWTAudience},
+		},
+		"workload identity with client id": {
+			spec: smv1alpha1.AzureKVStore{
+				TenantID: smmeta.String("my-tenant"),
+				WorkloadIdentity: &smv1alpha1.AzureWorkloadIdentity{
+					ServiceAccountRef: smmeta.ServiceAccountSelector{
+						Name:      "plain",
+						Audiences: []string{"custom"},
+					},
+					ClientID: smmeta.String("custom-client"),
+				},
+			},
+			wantRequest: map[string]string{
+				"grant_type":            "client_credentials",
+				"client_id":             "custom-client",
+				"client_assertion_type": jwtBearerType,
+				"client_assertion":      "k8s-jwt",
+				"scope":                 "https://vault.azure.net/.default",
+			},
+			wantAudiences: []string{"custom"},
+		},
+		"workload identity without client id": {
+			spec: smv1alpha1.AzureKVStore{
+				TenantID: smmeta.String("my-tenant"),
+				WorkloadIdentity: &smv1alpha1.AzureWorkloadIdentity{
+					ServiceAccountRef: smmeta.ServiceAccountSelector{Name: "plain"},
+				},
+			},
+			wantErr: true,
+		},
+		"multiple methods": {
+			spec: smv1alpha1.AzureKVStore{
+				TenantID:      smmeta.String("my-tenant"),
+				AuthSecretRef: servicePrincipal,
+				WorkloadIdentity: &smv1alpha1.AzureWorkloadIdentity{
+					ServiceAccountRef: smmeta.ServiceAccountSelector{Name: "annotated"},
+				},
+			},
+			wantErr: true,
+		},
+		"no method": {
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tokenRequests = nil
+			var tokenAudiences []string
+			clientset := fakekube.NewSimpleClientset()
+			clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				tokenAudiences = action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest).Spec.Audiences
+				return true, &authenticationv1.TokenRequest{
+					Status: authenticationv1.TokenRequestStatus{Token: "k8s-jwt"},
+				}, nil
+			})
+			spec := tc.spec
+			spec.AuthorityHost = smmeta.String(srv.URL)
+			var store smv1alpha1.GenericStore = &smv1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "azure", Namespace: "default"},
+				Spec:       smv1alpha1.SecretStoreSpec{AzureKV: &spec},
+			}
+			if tc.clusterScoped {
+				store = &smv1alpha1.ClusterSecretStore{
+					ObjectMeta: metav1.ObjectMeta{Name: "azure"},
+					Spec:       smv1alpha1.SecretStoreSpec{AzureKV: &spec},
+				}
+			}
+			a := &AzureKV{
+				kube:            fakeclient.NewFakeClientWithScheme(scheme.Scheme, objects...),
+				store:           store,
+				log:             ctrl.Log,
+				vaultURL:        "https://my-vault.vault.azure.net",
+				serviceAccounts: clientset.CoreV1(),
+			}
+
+			ts, err := a.tokenSource(context.Background(), &spec)
+			if err == nil {
+				_, err = ts.Token()
+			}
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(tokenRequests) != 1 || !reflect.DeepEqual(tokenRequests[0], tc.wantRequest) {
+				t.Errorf("expected token request %v, got %v", tc.wantRequest, tokenRequests)
+			}
+			if !reflect.DeepEqual(tokenAudiences, tc.wantAudiences) {
+				t.Errorf("expected token audiences %v, got %v", tc.wantAudiences, tokenAudiences)
+			}
+		})
+	}
+}
+
+func TestKeyVaultScope(t *testing.T) {
+	tests := map[string]struct {
+		vaultURL string
+		want     string
+		wantErr  bool
+	}{
+		"public cloud": {
+			vaultURL: "https://my-vault.vault.azure.net",
+			want:     "https://vault.azure.net/.default",
+		},
+		"china cloud with port": {
+			vaultURL: "https://my-vault.vault.azure.cn:443",
+			want:     "https://vault.azure.cn/.default",
+		},
+		"managed hsm": {
+			vaultURL: "https://my-hsm.managedhsm.azure.net",
+			want:     "https://managedhsm.azure.net/.default",
+		},
+		"no vault name": {
+			vaultURL: "https://localhost",
+			wantErr:  true,
+		},
+		"no scheme": {
+			vaultURL: "my-vault.vault.azure.net",
+			wantErr:  true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := keyVaultScope(tc.vaultURL)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected scope %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func mustDecodePEM(t *testing.T, data []byte) *pem.Block {
+	t.Helper()
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("expected pem block, got %q", data)
+	}
+	return block
+}
@@ -0,0 +1,239 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurekv
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	contentTypePKCS12 = "application/x-pkcs12"
+	contentTypePEM    = "application/x-pem-file"
+)
+
+// jsonWebKey is the public part of a key as returned by the Key Vault API.
+// Coordinates and moduli are base64url encoded.
+type jsonWebKey struct {
+	KeyID   string   `json:"kid,omitempty"`
+	KeyType string   `json:"kty"`
+	KeyOps  []string `json:"key_ops,omitempty"`
+	N       string   `json:"n,omitempty"`
+	E       string   `json:"e,omitempty"`
+	Curve   string   `json:"crv,omitempty"`
+	X       string   `json:"x,omitempty"`
+	Y       string   `json:"y,omitempty"`
+}
+
+// publicKeyPEM returns the PEM encoded PKIX public key.
+func (k *jsonWebKey) publicKeyPEM() ([]byte, error) {
+	var pub interface{}
+	switch strings.TrimSuffix(k.KeyType, "-HSM") {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		pub = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		pub = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// pem returns the PEM encoded certificate chain and PKCS#8 private key of a
+// certificate stored in the secret.
+func (s *secretBundle) pem() ([]byte, []byte, error) {
+	var blocks []*pem.Block
+	switch s.ContentType {
+	case contentTypePKCS12:
+		pfx, err := base64.StdEncoding.DecodeString(s.Value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error decoding pkcs12 archive: %w", err)
+		}
+		blocks, err = pkcs12.ToPEM(pfx, "")
+		if err != nil {
+			return nil, nil, fmt.Errorf("error decoding pkcs12 archive: %w", err)
+		}
+	case contentTypePEM:
+		rest := []byte(s.Value)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			blocks = append(blocks, block)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported content type %q", s.ContentType)
+	}
+
+	var certs, key bytes.Buffer
+	for _, block := range blocks {
+		if block.Type == "CERTIFICATE" {
+			_ = pem.Encode(&certs, &pem.Block{Type: block.Type, Bytes: block.Bytes})
+			continue
+		}
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			continue
+		}
+		der, err := pkcs8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		_ = pem.Encode(&key, &pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+	if certs.Len() == 0 {
+		return nil, nil, fmt.Errorf("no certificate found")
+	}
+	if key.Len() == 0 {
+		return nil, nil, fmt.Errorf("no private key found")
+	}
+	return certs.Bytes(), key.Bytes(), nil
+}
+
+// pfx returns the PKCS#12 archive of a certificate stored in the secret.
+// Certificates stored as PEM are encoded to an archive without password.
+func (s *secretBundle) pfx() ([]byte, error) {
+	switch s.ContentType {
+	case contentTypePKCS12:
+		pfx, err := base64.StdEncoding.DecodeString(s.Value)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding pkcs12 archive: %w", err)
+		}
+		return pfx, nil
+	case contentTypePEM:
+		certsPEM, keyPEM, err := s.pem()
+		if err != nil {
+			return nil, err
+		}
+		keyBlock, _ := pem.Decode(keyPEM)
+		key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing private key: %w", err)
+		}
+		var certs []*x509.Certificate
+		for rest := certsPEM; ; {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+		leaf, caCerts, err := splitChain(certs, key)
+		if err != nil {
+			return nil, err
+		}
+		pfx, err := pkcs12.Encode(rand.Reader, key, leaf, caCerts, "")
+		if err != nil {
+			return nil, fmt.Errorf("error encoding pkcs12 archive: %w", err)
+		}
+		return pfx, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", s.ContentType)
+	}
+}
+
+// splitChain returns the certificate of the private key and the remaining
+// certificates of the chain.
+func splitChain(certs []*x509.Certificate, key crypto.PrivateKey) (*x509.Certificate, []*x509.Certificate, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, cert := range certs {
+		if !bytes.Equal(cert.RawSubjectPublicKeyInfo, pub) {
+			continue
+		}
+		caCerts := append(append([]*x509.Certificate{}, certs[:i]...), certs[i+1:]...)
+		return cert, caCerts, nil
+	}
+	return nil, nil, fmt.Errorf("no certificate matches the private key")
+}
+
+// pkcs8PrivateKey returns the PKCS#8 encoding of a PKCS#1, SEC 1 or PKCS#8
+// encoded private key. PEM blocks converted from PKCS#12 archives are
+// labeled "PRIVATE KEY" regardless of the encoding.
+func pkcs8PrivateKey(der []byte) ([]byte, error) {
+	if _, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return der, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return x509.MarshalPKCS8PrivateKey(key)
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return x509.MarshalPKCS8PrivateKey(key)
+	}
+	return nil, fmt.Errorf("unsupported private key encoding")
+}
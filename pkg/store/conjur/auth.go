@@ -0,0 +1,179 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conjur
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	"github.com/itscontained/secret-manager/pkg/util/serviceaccount"
+	"github.com/itscontained/secret-manager/pkg/util/storeref"
+)
+
+// tokenRefreshAfter is the age after which cached access tokens are renewed.
+// Conjur access tokens expire after 8 minutes.
+const tokenRefreshAfter = 5 * time.Minute
+
+// tokenCacheEntry is the access token of a store at a given generation of
+// the store spec.
+type tokenCacheEntry struct {
+	generation int64
+	token      string
+	issued     time.Time
+}
+
+var (
+	tokenCache     = make(map[string]tokenCacheEntry)
+	tokenCacheLock sync.RWMutex
+)
+
+// authenticator exchanges the credentials of the store for an access token,
+// returned as the raw JSON token.
+type authenticator func(ctx context.Context) ([]byte, error)
+
+// setAuthenticator configures how access tokens are requested. Credentials
+// stored in Secrets are read immediately, while ServiceAccount tokens are
+// only requested when a new access token is needed.
+func (c *Conjur) setAuthenticator(ctx context.Context, spec *smv1alpha1.ConjurStore) error {
+	// TODO: Validating Webhook Candidate
+	if spec.AuthSecretRef != nil && spec.JWT != nil {
+		return fmt.Errorf("multiple authentication methods configured")
+	}
+	switch {
+	case spec.AuthSecretRef != nil:
+		c.log.V(1).Info("api key authentication defined")
+		login, err := storeref.SecretKey(ctx, c.kube, c.store, "login", spec.AuthSecretRef.Login)
+		if err != nil {
+			return err
+		}
+		apiKey, err := storeref.SecretKey(ctx, c.kube, c.store, "apiKey", spec.AuthSecretRef.APIKey)
+		if err != nil {
+			return err
+		}
+		path := fmt.Sprintf("/authn/%s/%s/authenticate", url.PathEscape(c.account), url.PathEscape(login))
+		c.authenticate = func(ctx context.Context) ([]byte, error) {
+			return c.requestToken(ctx, path, "text/plain", apiKey)
+		}
+	case spec.JWT != nil:
+		c.log.V(1).Info("jwt authentication defined")
+		jwt := spec.JWT
+		if jwt.ServiceID == "" {
+			return fmt.Errorf("missing serviceID in jwt config")
+		}
+		namespace, err := storeref.Namespace(c.store, "serviceAccountRef", jwt.ServiceAccountRef.Namespace)
+		if err != nil {
+			return err
+		}
+		if c.serviceAccounts == nil {
+			client, err := serviceaccount.Client()
+			if err != nil {
+				return err
+			}
+			c.serviceAccounts = client
+		}
+		path := fmt.Sprintf("/authn-jwt/%s/%s", url.PathEscape(jwt.ServiceID), url.PathEscape(c.account))
+		if jwt.HostID != nil {
+			path += "/" + url.PathEscape(*jwt.HostID)
+		}
+		path += "/authenticate"
+		c.authenticate = func(ctx context.Context) ([]byte, error) {
+			token, err := serviceaccount.Token(ctx, c.serviceAccounts, namespace, jwt.ServiceAccountRef.Name, jwt.ServiceAccountRef.Audiences)
+			if err != nil {
+				return nil, err
+			}
+			return c.requestToken(ctx, path, "application/x-www-form-urlencoded", url.Values{"jwt": {token}}.Encode())
+		}
+	default:
+		return fmt.Errorf("missing authSecretRef or jwt in store config")
+	}
+	return nil
+}
+
+// accessToken returns the value of the Authorization header of requests.
+// Access tokens are cached per store until they are due for renewal or the
+// store spec changes. Tokens due for renewal are evicted whenever a new token
+// is cached, so that tokens of deleted stores do not pile up.
+func (c *Conjur) accessToken(ctx context.Context) (string, error) {
+	cacheKey := c.cacheKey()
+	tokenCacheLock.RLock()
+	entry, ok := tokenCache[cacheKey]
+	tokenCacheLock.RUnlock()
+	if ok && entry.generation == c.store.GetGeneration() && time.Since(entry.issued) < tokenRefreshAfter {
+		return entry.token, nil
+	}
+
+	issued := time.Now()
+	raw, err := c.authenticate(ctx)
+	if err != nil {
+		return "", err
+	}
+	token := fmt.Sprintf("Token token=%q", base64.StdEncoding.EncodeToString(raw))
+
+	tokenCacheLock.Lock()
+	for key, entry := range tokenCache {
+		if issued.Sub(entry.issued) >= tokenRefreshAfter {
+			delete(tokenCache, key)
+		}
+	}
+	tokenCache[cacheKey] = tokenCacheEntry{
+		generation: c.store.GetGeneration(),
+		token:      token,
+		issued:     issued,
+	}
+	tokenCacheLock.Unlock()
+
+	return token, nil
+}
+
+// invalidateToken removes the cached access token of the store, e.g. after
+// it was rejected.
+func (c *Conjur) invalidateToken() {
+	tokenCacheLock.Lock()
+	delete(tokenCache, c.cacheKey())
+	tokenCacheLock.Unlock()
+}
+
+func (c *Conjur) cacheKey() string {
+	return fmt.Sprintf("%s/%s/%s", c.store.GetTypeMeta().Kind, c.store.GetNamespace(), c.store.GetName())
+}
+
+func (c *Conjur) requestToken(ctx context.Context, path, contentType, body string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+path, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error authenticating with conjur: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error authenticating with conjur: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error authenticating with conjur: %s", resp.Status)
+	}
+	return respBody, nil
+}
@@ -0,0 +1,324 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conjur
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	ctxlog "github.com/itscontained/secret-manager/pkg/log"
+	"github.com/itscontained/secret-manager/pkg/store"
+	"github.com/itscontained/secret-manager/pkg/store/schema"
+	"github.com/itscontained/secret-manager/pkg/util/property"
+
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ store.Client = &Conjur{}
+
+const (
+	// listPageSize is the number of variables listed per request.
+	listPageSize = 1000
+	// batchSize is the number of variables retrieved per request, keeping
+	// request URLs reasonably short.
+	batchSize = 50
+	// requestTimeout bounds requests to the Conjur server.
+	requestTimeout = 30 * time.Second
+)
+
+// Conjur reads variables from CyberArk Conjur.
+type Conjur struct {
+	kube    ctrlclient.Client
+	store   smv1alpha1.GenericStore
+	log     logr.Logger
+	client  *http.Client
+	url     string
+	account string
+
+	authenticate    authenticator
+	serviceAccounts typedcorev1.ServiceAccountsGetter
+}
+
+func init() {
+	schema.Register(&Conjur{}, &smv1alpha1.SecretStoreSpec{
+		Conjur: &smv1alpha1.ConjurStore{},
+	})
+}
+
+func (c *Conjur) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctrlclient.Client, namespace string) (store.Client, error) {
+	log := ctxlog.FromContext(ctx)
+	spec := store.GetSpec().Conjur
+	conjurClient := &Conjur{
+		kube:    kube,
+		store:   store,
+		log:     log,
+		url:     strings.TrimSuffix(spec.URL, "/"),
+		account: spec.Account,
+	}
+	if err := conjurClient.setClient(ctx, spec); err != nil {
+		log.Error(err, "could not create new conjur client")
+		return nil, err
+	}
+	return conjurClient, nil
+}
+
+func (c *Conjur) GetSecret(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, error) {
+	if ref.Find != nil {
+		return nil, fmt.Errorf("find is only supported in dataFrom")
+	}
+	value, err := c.readVariable(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if ref.Property == nil {
+		return value, nil
+	}
+	return property.Get(value, *ref.Property)
+}
+
+// GetSecretMap returns the fields of the JSON value of the variable given by
+// Name, or with Find the variables whose id starts with Name, keyed by their
+// id relative to Name. Values which are not JSON objects are returned with
+// the key Property, defaulting to "secret".
+func (c *Conjur) GetSecretMap(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, error) {
+	if ref.Find == nil {
+		value, err := c.readVariable(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		data, err := property.Map(value)
+		if errors.Is(err, property.ErrNotJSONObject) {
+			key := smv1alpha1.DefaultSecretKey
+			if ref.Property != nil {
+				key = *ref.Property
+			}
+			return map[string][]byte{key: value}, nil
+		}
+		return data, err
+	}
+	if ref.Version != nil {
+		return nil, fmt.Errorf("version is not supported with find")
+	}
+	find := ref.Find
+	separator := smv1alpha1.DefaultFindSeparator
+	if find.Separator != nil {
+		separator = *find.Separator
+	}
+	maxDepth := 1
+	if find.Recursive {
+		maxDepth = 0
+		if find.MaxDepth != nil {
+			maxDepth = int(*find.MaxDepth)
+		}
+	}
+
+	prefix := strings.Trim(ref.Name, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	ids, err := c.listVariables(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]string)
+	for _, id := range ids {
+		if !strings.HasPrefix(id, prefix) {
+			continue
+		}
+		relPath := strings.TrimPrefix(id, prefix)
+		if maxDepth > 0 && strings.Count(relPath, "/") >= maxDepth {
+			continue
+		}
+		key := strings.ReplaceAll(relPath, "/", separator)
+		if other, exists := keys[key]; exists {
+			return nil, fmt.Errorf("variable %q conflicts with variable %q for %q", id, other, key)
+		}
+		keys[key] = id
+	}
+
+	selected := make([]string, 0, len(keys))
+	for _, id := range keys {
+		selected = append(selected, id)
+	}
+	sort.Strings(selected)
+	values, err := c.readVariables(ctx, selected)
+	if err != nil {
+		return nil, err
+	}
+	secretMap := make(map[string][]byte, len(keys))
+	for key, id := range keys {
+		secretMap[key] = values[id]
+	}
+	return secretMap, nil
+}
+
+// readVariable returns the value of the variable given by Name, at Version
+// if set.
+func (c *Conjur) readVariable(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, error) {
+	path := fmt.Sprintf("/secrets/%s/variable/%s", url.PathEscape(c.account), url.PathEscape(ref.Name))
+	if ref.Version != nil {
+		if _, err := strconv.Atoi(*ref.Version); err != nil {
+			return nil, fmt.Errorf("invalid version %q: versions of conjur variables are numbers", *ref.Version)
+		}
+		path += "?version=" + url.QueryEscape(*ref.Version)
+	}
+	value, err := c.get(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading variable %q: %w", ref.Name, err)
+	}
+	return value, nil
+}
+
+// readVariables returns the values of the variables by id, retrieved in
+// batches.
+func (c *Conjur) readVariables(ctx context.Context, ids []string) (map[string][]byte, error) {
+	prefix := c.account + ":variable:"
+	values := make(map[string][]byte, len(ids))
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		fullIDs := make([]string, 0, end-start)
+		for _, id := range ids[start:end] {
+			fullIDs = append(fullIDs, url.QueryEscape(prefix+id))
+		}
+		body, err := c.get(ctx, "/secrets?variable_ids="+strings.Join(fullIDs, ","))
+		if err != nil {
+			return nil, fmt.Errorf("error reading variables: %w", err)
+		}
+		var batch map[string]string
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, fmt.Errorf("error decoding variables: %w", err)
+		}
+		for fullID, value := range batch {
+			values[strings.TrimPrefix(fullID, prefix)] = []byte(value)
+		}
+	}
+	return values, nil
+}
+
+// listVariables returns the ids of the variables visible to secret-manager,
+// narrowed down by a search for the words of prefix. Conjur searches the
+// words of resource ids, so the ids still need to be matched against prefix.
+func (c *Conjur) listVariables(ctx context.Context, prefix string) ([]string, error) {
+	idPrefix := c.account + ":variable:"
+	var ids []string
+	for offset := 0; ; offset += listPageSize {
+		query := url.Values{
+			"limit":  {strconv.Itoa(listPageSize)},
+			"offset": {strconv.Itoa(offset)},
+		}
+		if search := strings.Trim(prefix, "/"); search != "" {
+			query.Set("search", search)
+		}
+		path := fmt.Sprintf("/resources/%s/variable?%s", url.PathEscape(c.account), query.Encode())
+		body, err := c.get(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("error listing variables: %w", err)
+		}
+		var resources []struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(body, &resources); err != nil {
+			return nil, fmt.Errorf("error decoding variables: %w", err)
+		}
+		for _, resource := range resources {
+			ids = append(ids, strings.TrimPrefix(resource.ID, idPrefix))
+		}
+		if len(resources) < listPageSize {
+			return ids, nil
+		}
+	}
+}
+
+// get sends an authenticated GET request. Requests rejected with a cached
+// access token are retried once with a new token.
+func (c *Conjur) get(ctx context.Context, path string) ([]byte, error) {
+	body, status, err := c.do(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusUnauthorized {
+		c.log.V(1).Info("access token rejected, authenticating again")
+		c.invalidateToken()
+		body, status, err = c.do(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch status {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("not found")
+	default:
+		return nil, fmt.Errorf("%d %s", status, http.StatusText(status))
+	}
+}
+
+func (c *Conjur) do(ctx context.Context, path string) ([]byte, int, error) {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Authorization", token)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, resp.StatusCode, nil
+}
+
+func (c *Conjur) setClient(ctx context.Context, spec *smv1alpha1.ConjurStore) error {
+	if spec.Account == "" {
+		return fmt.Errorf("missing account in store config")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(spec.CABundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(spec.CABundle) {
+			return fmt.Errorf("no certificates found in caBundle")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	c.client = &http.Client{Transport: transport, Timeout: requestTimeout}
+	return c.setAuthenticator(ctx, spec)
+}
@@ -0,0 +1,582 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conjur
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	ctxlog "github.com/itscontained/secret-manager/pkg/log"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeConjur is a stand-in for the Conjur API of the account "myorg". It
+// issues a new access token on each authentication and accepts any token it
+// issued until revoked.
+type fakeConjur struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	variables map[string][]string
+	tokens    map[string]bool
+	// authRequests records the path and body of authentication requests.
+	authRequests []string
+	// batches records the variable ids of batch requests.
+	batches [][]string
+	// searches records the search parameter of list requests.
+	searches []string
+}
+
+func newFakeConjur(t *testing.T, variables map[string][]string) *fakeConjur {
+	t.Helper()
+	f := &fakeConjur{variables: variables, tokens: map[string]bool{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeConjur) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := r.URL.EscapedPath()
+	if r.Method == http.MethodPost && strings.HasSuffix(path, "/authenticate") {
+		body, _ := ioutil.ReadAll(r.Body)
+		f.authRequests = append(f.authRequests, path+" "+string(body))
+		if string(body) != "api-key" && string(body) != "jwt=k8s-jwt" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		token := fmt.Sprintf(`{"protected":"e30=","payload":"%d","signature":"c2ln"}`, len(f.authRequests))
+		f.tokens[token] = true
+		_, _ = w.Write([]byte(token))
+		return
+	}
+
+	auth := strings.TrimSuffix(strings.TrimPrefix(r.Header.Get("Authorization"), `Token token="`), `"`)
+	token, _ := base64.StdEncoding.DecodeString(auth)
+	if !f.tokens[string(token)] {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(path, "/secrets/myorg/variable/"):
+		id, _ := url.PathUnescape(strings.TrimPrefix(path, "/secrets/myorg/variable/"))
+		versions, ok := f.variables[id]
+		version := len(versions)
+		if v := r.URL.Query().Get("version"); v != "" {
+			_, _ = fmt.Sscan(v, &version)
+		}
+		if !ok || version < 1 || version > len(versions) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(versions[version-1]))
+	case path == "/secrets":
+		var ids []string
+		values := map[string]string{}
+		for _, fullID := range strings.Split(r.URL.Query().Get("variable_ids"), ",") {
+			id := strings.TrimPrefix(fullID, "myorg:variable:")
+			versions, ok := f.variables[id]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			ids = append(ids, id)
+			values[fullID] = versions[len(versions)-1]
+		}
+		f.batches = append(f.batches, ids)
+		_ = json.NewEncoder(w).Encode(values)
+	case path == "/resources/myorg/variable":
+		query := r.URL.Query()
+		search := query.Get("search")
+		f.searches = append(f.searches, search)
+		var ids []string
+		for id := range f.variables {
+			if searchMatches(id, search) {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		offset, _ := strconv.Atoi(query.Get("offset"))
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		resources := []map[string]string{}
+		for i := offset; i < len(ids) && i < offset+limit; i++ {
+			resources = append(resources, map[string]string{"id": "myorg:variable:" + ids[i]})
+		}
+		_ = json.NewEncoder(w).Encode(resources)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// searchMatches returns whether the id contains all of the words of search,
+// words being separated by slashes, dots and dashes as in Conjur.
+func searchMatches(id, search string) bool {
+	words := func(s string) []string {
+		return strings.FieldsFunc(s, func(r rune) bool {
+			return r == '/' || r == '.' || r == '-'
+		})
+	}
+	idWords := map[string]bool{}
+	for _, word := range words(id) {
+		idWords[word] = true
+	}
+	for _, word := range words(search) {
+		if !idWords[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// revokeTokens rejects all access tokens issued so far.
+func (f *fakeConjur) revokeTokens() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens = map[string]bool{}
+}
+
+func (f *fakeConjur) authCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.authRequests)
+}
+
+func apiKeyObjects() []runtime.Object {
+	return []runtime.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "conjur", Namespace: "default"},
+			Data: map[string][]byte{
+				"login":  []byte("host/secret-manager"),
+				"apiKey": []byte("api-key\n"),
+			},
+		},
+	}
+}
+
+func apiKeyAuth() *smv1alpha1.ConjurAuth {
+	return &smv1alpha1.ConjurAuth{
+		Login: smmeta.SecretKeySelector{
+			LocalObjectReference: smmeta.LocalObjectReference{Name: "conjur"},
+			Key:                  "login",
+		},
+		APIKey: smmeta.SecretKeySelector{
+			LocalObjectReference: smmeta.LocalObjectReference{Name: "conjur"},
+			Key:                  "apiKey",
+		},
+	}
+}
+
+// newTestConjur returns a client of the store with the given name,
+// authenticating with an API key. Access tokens are cached by store name.
+func newTestConjur(t *testing.T, srv *fakeConjur, name string, generation int64) *Conjur {
+	t.Helper()
+	spec := &smv1alpha1.ConjurStore{
+		URL:           srv.URL + "/",
+		Account:       "myorg",
+		AuthSecretRef: apiKeyAuth(),
+	}
+	store := &smv1alpha1.SecretStore{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Generation: generation},
+		Spec:       smv1alpha1.SecretStoreSpec{Conjur: spec},
+	}
+	ctx := ctxlog.IntoContext(context.Background(), ctrl.Log)
+	c, err := (&Conjur{}).New(ctx, store, fakeclient.NewFakeClientWithScheme(scheme.Scheme, apiKeyObjects()...), "default")
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	return c.(*Conjur)
+}
+
+func testVariables() map[string][]string {
+	return map[string][]string{
+		"prod/db/password":  {"old-secret", "s3cr3t"},
+		"prod/db/username":  {"admin"},
+		"prod/db/tls/ca":    {"ca-cert"},
+		"prod/app/config":   {`{"user":"app","limits":{"max":3}}`},
+		"prod/app/override": {"override"},
+		"staging/db/user":   {"staging"},
+	}
+}
+
+func TestGetSecret(t *testing.T) {
+	srv := newFakeConjur(t, testVariables())
+	c := newTestConjur(t, srv, "get-secret", 1)
+
+	tests := map[string]struct {
+		ref     smv1alpha1.RemoteReference
+		want    []byte
+		wantErr bool
+	}{
+		"latest version": {
+			ref:  smv1alpha1.RemoteReference{Name: "prod/db/password"},
+			want: []byte("s3cr3t"),
+		},
+		"version": {
+			ref:  smv1alpha1.RemoteReference{Name: "prod/db/password", Version: smmeta.String("1")},
+			want: []byte("old-secret"),
+		},
+		"property": {
+			ref:  smv1alpha1.RemoteReference{Name: "prod/app/config", Property: smmeta.String("limits.max")},
+			want: []byte("3"),
+		},
+		"invalid version": {
+			ref:     smv1alpha1.RemoteReference{Name: "prod/db/password", Version: smmeta.String("latest")},
+			wantErr: true,
+		},
+		"not found": {
+			ref:     smv1alpha1.RemoteReference{Name: "prod/db/missing"},
+			wantErr: true,
+		},
+		"find": {
+			ref:     smv1alpha1.RemoteReference{Name: "prod", Find: &smv1alpha1.FindReference{}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := c.GetSecret(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetSecretMap(t *testing.T) {
+	srv := newFakeConjur(t, testVariables())
+	c := newTestConjur(t, srv, "get-secret-map", 1)
+
+	tests := map[string]struct {
+		ref     smv1alpha1.RemoteReference
+		want    map[string][]byte
+		wantErr bool
+	}{
+		"json value": {
+			ref: smv1alpha1.RemoteReference{Name: "prod/app/config"},
+			want: map[string][]byte{
+				"user":   []byte("app"),
+				"limits": []byte(`{"max":3}`),
+			},
+		},
+		"find": {
+			ref: smv1alpha1.RemoteReference{Name: "prod/db", Find: &smv1alpha1.FindReference{}},
+			want: map[string][]byte{
+				"password": []byte("s3cr3t"),
+				"username": []byte("admin"),
+			},
+		},
+		"find recursive": {
+			ref: smv1alpha1.RemoteReference{Name: "prod/db/", Find: &smv1alpha1.FindReference{Recursive: true}},
+			want: map[string][]byte{
+				"password": []byte("s3cr3t"),
+				"username": []byte("admin"),
+				"tls_ca":   []byte("ca-cert"),
+			},
+		},
+		"find recursive with max depth and separator": {
+			ref: smv1alpha1.RemoteReference{Name: "prod", Find: &smv1alpha1.FindReference{
+				Recursive: true,
+				MaxDepth:  int32Ptr(2),
+				Separator: smmeta.String("."),
+			}},
+			want: map[string][]byte{
+				"db.password":  []byte("s3cr3t"),
+				"db.username":  []byte("admin"),
+				"app.config":   []byte(`{"user":"app","limits":{"max":3}}`),
+				"app.override": []byte("override"),
+			},
+		},
+		"find without matches": {
+			ref:  smv1alpha1.RemoteReference{Name: "dev", Find: &smv1alpha1.FindReference{}},
+			want: map[string][]byte{},
+		},
+		"find with version": {
+			ref:     smv1alpha1.RemoteReference{Name: "prod/db", Version: smmeta.String("1"), Find: &smv1alpha1.FindReference{}},
+			wantErr: true,
+		},
+		"value not json": {
+			ref:  smv1alpha1.RemoteReference{Name: "prod/db/password"},
+			want: map[string][]byte{smv1alpha1.DefaultSecretKey: []byte("s3cr3t")},
+		},
+		"value not json with property": {
+			ref:  smv1alpha1.RemoteReference{Name: "prod/db/password", Property: smmeta.String("db-password")},
+			want: map[string][]byte{"db-password": []byte("s3cr3t")},
+		},
+		"variable not found": {
+			ref:     smv1alpha1.RemoteReference{Name: "prod/db/missing"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := c.GetSecretMap(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetSecretMapBatches(t *testing.T) {
+	variables := map[string][]string{}
+	want := map[string][]byte{}
+	for i := 0; i < batchSize+10; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		variables["bulk/"+key] = []string{"value " + key}
+		want[key] = []byte("value " + key)
+	}
+	srv := newFakeConjur(t, variables)
+	c := newTestConjur(t, srv, "batches", 1)
+
+	got, err := c.GetSecretMap(context.Background(), smv1alpha1.RemoteReference{
+		Name: "bulk",
+		Find: &smv1alpha1.FindReference{},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %d values, got %d: %q", len(want), len(got), got)
+	}
+	if len(srv.batches) != 2 || len(srv.batches[0]) != batchSize || len(srv.batches[1]) != 10 {
+		t.Errorf("expected batches of %d and 10 variables, got %d batches", batchSize, len(srv.batches))
+	}
+	if !reflect.DeepEqual(srv.searches, []string{"bulk"}) {
+		t.Errorf("expected variables to be searched by path, got searches %q", srv.searches)
+	}
+}
+
+func TestTokenCache(t *testing.T) {
+	srv := newFakeConjur(t, testVariables())
+	ref := smv1alpha1.RemoteReference{Name: "prod/db/username"}
+	read := func(c *Conjur) {
+		t.Helper()
+		got, err := c.GetSecret(context.Background(), ref)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(got) != "admin" {
+			t.Fatalf("expected %q, got %q", "admin", got)
+		}
+	}
+
+	read(newTestConjur(t, srv, "cache", 1))
+	read(newTestConjur(t, srv, "cache", 1))
+	if n := srv.authCount(); n != 1 {
+		t.Errorf("expected token to be reused by clients of the store, got %d authentications", n)
+	}
+
+	read(newTestConjur(t, srv, "cache-other", 1))
+	if n := srv.authCount(); n != 2 {
+		t.Errorf("expected token of other store to be requested, got %d authentications", n)
+	}
+
+	read(newTestConjur(t, srv, "cache", 2))
+	if n := srv.authCount(); n != 3 {
+		t.Errorf("expected token to be renewed after store changed, got %d authentications", n)
+	}
+
+	srv.revokeTokens()
+	read(newTestConjur(t, srv, "cache", 2))
+	if n := srv.authCount(); n != 4 {
+		t.Errorf("expected rejected token to be renewed, got %d authentications", n)
+	}
+
+	tokenCacheLock.Lock()
+	entry := tokenCache["SecretStore/default/cache-other"]
+	entry.issued = time.Now().Add(-tokenRefreshAfter)
+	tokenCache["SecretStore/default/cache-other"] = entry
+	tokenCacheLock.Unlock()
+	read(newTestConjur(t, srv, "cache-evict", 1))
+	tokenCacheLock.RLock()
+	_, ok := tokenCache["SecretStore/default/cache-other"]
+	tokenCacheLock.RUnlock()
+	if ok {
+		t.Error("expected token due for renewal to be evicted")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	srv := newFakeConjur(t, testVariables())
+
+	tests := map[string]struct {
+		spec          smv1alpha1.ConjurStore
+		clusterScoped bool
+		wantRequest   string
+		wantAudiences []string
+		wantErr       bool
+	}{
+		"api key": {
+			spec:        smv1alpha1.ConjurStore{AuthSecretRef: apiKeyAuth()},
+			wantRequest: "/authn/myorg/host%2Fsecret-manager/authenticate api-key",
+		},
+		"api key cluster-scoped without namespace": {
+			spec:          smv1alpha1.ConjurStore{AuthSecretRef: apiKeyAuth()},
+			clusterScoped: true,
+			wantErr:       true,
+		},
+		"jwt": {
+			spec: smv1alpha1.ConjurStore{JWT: &smv1alpha1.ConjurJWT{
+				ServiceID: "k8s-cluster",
+				ServiceAccountRef: smmeta.ServiceAccountSelector{
+					Name:      "secret-manager",
+					Audiences: []string{"conjur"},
+				},
+			}},
+			wantRequest:   "/authn-jwt/k8s-cluster/myorg/authenticate jwt=k8s-jwt",
+			wantAudiences: []string{"conjur"},
+		},
+		"jwt with host id": {
+			spec: smv1alpha1.ConjurStore{JWT: &smv1alpha1.ConjurJWT{
+				ServiceID:         "k8s-cluster",
+				HostID:            smmeta.String("host/apps/secret-manager"),
+				ServiceAccountRef: smmeta.ServiceAccountSelector{Name: "secret-manager"},
+			}},
+			wantRequest: "/authn-jwt/k8s-cluster/myorg/host%2Fapps%2Fsecret-manager/authenticate jwt=k8s-jwt",
+		},
+		"jwt cluster-scoped": {
+			spec: smv1alpha1.ConjurStore{JWT: &smv1alpha1.ConjurJWT{
+				ServiceID: "k8s-cluster",
+				ServiceAccountRef: smmeta.ServiceAccountSelector{
+					Name:      "secret-manager",
+					Namespace: smmeta.String("default"),
+				},
+			}},
+			clusterScoped: true,
+			wantRequest:   "/authn-jwt/k8s-cluster/myorg/authenticate jwt=k8s-jwt",
+		},
+		"multiple methods": {
+			spec: smv1alpha1.ConjurStore{
+				AuthSecretRef: apiKeyAuth(),
+				JWT: &smv1alpha1.ConjurJWT{
+					ServiceID:         "k8s-cluster",
+					ServiceAccountRef: smmeta.ServiceAccountSelector{Name: "secret-manager"},
+				},
+			},
+			wantErr: true,
+		},
+		"no method": {
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var tokenAudiences []string
+			clientset := fakekube.NewSimpleClientset()
+			clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				tokenAudiences = action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest).Spec.Audiences
+				return true, &authenticationv1.TokenRequest{
+					Status: authenticationv1.TokenRequestStatus{Token: "k8s-jwt"},
+				}, nil
+			})
+			spec := tc.spec
+			spec.URL = srv.URL
+			spec.Account = "myorg"
+			var store smv1alpha1.GenericStore = &smv1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "auth " + name, Namespace: "default"},
+				Spec:       smv1alpha1.SecretStoreSpec{Conjur: &spec},
+			}
+			if tc.clusterScoped {
+				store = &smv1alpha1.ClusterSecretStore{
+					ObjectMeta: metav1.ObjectMeta{Name: "auth " + name},
+					Spec:       smv1alpha1.SecretStoreSpec{Conjur: &spec},
+				}
+			}
+			c := &Conjur{
+				kube:            fakeclient.NewFakeClientWithScheme(scheme.Scheme, apiKeyObjects()...),
+				store:           store,
+				log:             ctrl.Log,
+				url:             srv.URL,
+				account:         "myorg",
+				serviceAccounts: clientset.CoreV1(),
+			}
+
+			start := srv.authCount()
+			err := c.setClient(context.Background(), &spec)
+			if err == nil {
+				_, err = c.GetSecret(context.Background(), smv1alpha1.RemoteReference{Name: "prod/db/username"})
+			}
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := srv.authRequests[start:]; len(got) != 1 || got[0] != tc.wantRequest {
+				t.Errorf("expected authentication %q, got %q", tc.wantRequest, got)
+			}
+			if !reflect.DeepEqual(tokenAudiences, tc.wantAudiences) {
+				t.Errorf("expected token audiences %v, got %v", tc.wantAudiences, tokenAudiences)
+			}
+		})
+	}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
@@ -0,0 +1,238 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consul
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	ctxlog "github.com/itscontained/secret-manager/pkg/log"
+	"github.com/itscontained/secret-manager/pkg/store"
+	"github.com/itscontained/secret-manager/pkg/store/schema"
+	"github.com/itscontained/secret-manager/pkg/util/property"
+	"github.com/itscontained/secret-manager/pkg/util/storeref"
+
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ store.Client = &Consul{}
+
+// requestTimeout bounds requests to the Consul agent.
+const requestTimeout = 30 * time.Second
+
+// Consul reads keys from the HashiCorp Consul KV store.
+type Consul struct {
+	kube  ctrlclient.Client
+	store smv1alpha1.GenericStore
+	log   logr.Logger
+
+	client *http.Client
+	server string
+	token  string
+	// query holds the datacenter and namespace parameters of requests.
+	query url.Values
+}
+
+func init() {
+	schema.Register(&Consul{}, &smv1alpha1.SecretStoreSpec{
+		Consul: &smv1alpha1.ConsulStore{},
+	})
+}
+
+func (c *Consul) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctrlclient.Client, namespace string) (store.Client, error) {
+	log := ctxlog.FromContext(ctx)
+	consulClient := &Consul{
+		kube:  kube,
+		store: store,
+		log:   log,
+	}
+	if err := consulClient.setClient(ctx); err != nil {
+		log.Error(err, "could not create new consul client")
+		return nil, err
+	}
+	return consulClient, nil
+}
+
+func (c *Consul) GetSecret(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, error) {
+	if ref.Version != nil {
+		return nil, fmt.Errorf("version is not supported by consul stores")
+	}
+	if ref.Find != nil {
+		return nil, fmt.Errorf("find is only supported in dataFrom")
+	}
+	key := strings.Trim(ref.Name, "/")
+	pairs, err := c.readKeys(ctx, key, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("key %q not found", key)
+	}
+	value := pairs[0].Value
+	if ref.Property == nil {
+		return value, nil
+	}
+	return property.Get(value, *ref.Property)
+}
+
+// GetSecretMap returns the keys below the prefix given by Name, keyed by
+// their path relative to the prefix. All nested keys are returned unless
+// limited by Find.
+func (c *Consul) GetSecretMap(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, error) {
+	if ref.Version != nil {
+		return nil, fmt.Errorf("version is not supported by consul stores")
+	}
+	find := ref.Find
+	if find == nil {
+		find = &smv1alpha1.FindReference{Recursive: true}
+	}
+	separator := smv1alpha1.DefaultFindSeparator
+	if find.Separator != nil {
+		separator = *find.Separator
+	}
+	maxDepth := 1
+	if find.Recursive {
+		maxDepth = 0
+		if find.MaxDepth != nil {
+			maxDepth = int(*find.MaxDepth)
+		}
+	}
+
+	prefix := strings.Trim(ref.Name, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	pairs, err := c.readKeys(ctx, prefix, true)
+	if err != nil {
+		return nil, err
+	}
+	secretMap := make(map[string][]byte, len(pairs))
+	for _, pair := range pairs {
+		relPath := strings.TrimPrefix(pair.Key, prefix)
+		// folders are keys ending with a slash and hold no value
+		if relPath == "" || strings.HasSuffix(relPath, "/") {
+			continue
+		}
+		if maxDepth > 0 && strings.Count(relPath, "/") >= maxDepth {
+			continue
+		}
+		key := strings.ReplaceAll(relPath, "/", separator)
+		if _, exists := secretMap[key]; exists {
+			return nil, fmt.Errorf("key %q conflicts with another key for %q", pair.Key, key)
+		}
+		secretMap[key] = pair.Value
+	}
+	return secretMap, nil
+}
+
+// kvPair is a key as returned by the Consul KV API. The value is decoded
+// from base64 when unmarshaled.
+type kvPair struct {
+	Key   string `json:"Key"`
+	Value []byte `json:"Value"`
+}
+
+// readKeys returns the key, or with recurse the keys starting with key,
+// sorted by key. No keys are returned if none are found.
+func (c *Consul) readKeys(ctx context.Context, key string, recurse bool) ([]kvPair, error) {
+	query := url.Values{}
+	for k, v := range c.query {
+		query[k] = v
+	}
+	if recurse {
+		query.Set("recurse", "true")
+	}
+	u := c.server + "/v1/kv/" + (&url.URL{Path: key}).EscapedPath()
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key %q: %w", key, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key %q: %w", key, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("error reading key %q: %s: %s", key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	var pairs []kvPair
+	if err := json.Unmarshal(body, &pairs); err != nil {
+		return nil, fmt.Errorf("error decoding key %q: %w", key, err)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key < pairs[j].Key
+	})
+	return pairs, nil
+}
+
+func (c *Consul) setClient(ctx context.Context) error {
+	spec := c.store.GetSpec().Consul
+	c.server = strings.TrimSuffix(spec.Server, "/")
+	c.query = url.Values{}
+	if spec.Datacenter != nil {
+		c.query.Set("dc", *spec.Datacenter)
+	}
+	if spec.Namespace != nil {
+		c.query.Set("ns", *spec.Namespace)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(spec.CABundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(spec.CABundle) {
+			return fmt.Errorf("no certificates found in caBundle")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	c.client = &http.Client{Transport: transport, Timeout: requestTimeout}
+
+	if spec.AuthSecretRef == nil {
+		c.log.V(1).Info("no authentication defined. using anonymous token")
+		return nil
+	}
+	c.log.V(1).Info("token authentication defined")
+	token, err := storeref.SecretKey(ctx, c.kube, c.store, "token", spec.AuthSecretRef.Token)
+	if err != nil {
+		return err
+	}
+	c.token = token
+	return nil
+}
@@ -0,0 +1,255 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	ctxlog "github.com/itscontained/secret-manager/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/kubernetes/scheme"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestConsul returns a Consul client for the datacenter "dc2" and the
+// namespace "team" sending requests to a stand-in for the Consul KV API
+// serving the given keys. A nil value is a folder.
+func newTestConsul(t *testing.T, kv map[string]*string) *Consul {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.Header.Get("X-Consul-Token"); token != "acl-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("ACL not found"))
+			return
+		}
+		query := r.URL.Query()
+		if query.Get("dc") != "dc2" || query.Get("ns") != "team" {
+			t.Errorf("unexpected datacenter %q or namespace %q", query.Get("dc"), query.Get("ns"))
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		var pairs []map[string]interface{}
+		for k, v := range kv {
+			if k == key || (query.Get("recurse") == "true" && strings.HasPrefix(k, key)) {
+				pair := map[string]interface{}{"Key": k, "Flags": 0, "ModifyIndex": 42}
+				if v != nil {
+					pair["Value"] = []byte(*v)
+				}
+				pairs = append(pairs, pair)
+			}
+		}
+		if len(pairs) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(pairs)
+	}))
+	t.Cleanup(srv.Close)
+
+	kube := fakeclient.NewFakeClientWithScheme(scheme.Scheme, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("acl-token\n")},
+	})
+	store := &smv1alpha1.SecretStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul", Namespace: "default"},
+		Spec: smv1alpha1.SecretStoreSpec{
+			Consul: &smv1alpha1.ConsulStore{
+				Server:     srv.URL + "/",
+				Datacenter: smmeta.String("dc2"),
+				Namespace:  smmeta.String("team"),
+				AuthSecretRef: &smv1alpha1.ConsulAuth{
+					Token: smmeta.SecretKeySelector{
+						LocalObjectReference: smmeta.LocalObjectReference{Name: "consul"},
+						Key:                  "token",
+					},
+				},
+			},
+		},
+	}
+	ctx := ctxlog.IntoContext(context.Background(), ctrl.Log)
+	client, err := (&Consul{}).New(ctx, store, kube, "default")
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	return client.(*Consul)
+}
+
+func TestGetSecret(t *testing.T) {
+	c := newTestConsul(t, map[string]*string{
+		"app/db/password": smmeta.String("abc123"),
+		"app/config":      smmeta.String(`{"db": {"host": "db.local", "port": 5432}}`),
+		"app/space key":   smmeta.String("escaped"),
+	})
+
+	tests := map[string]struct {
+		ref     smv1alpha1.RemoteReference
+		want    []byte
+		wantErr bool
+	}{
+		"key": {
+			ref:  smv1alpha1.RemoteReference{Name: "app/db/password"},
+			want: []byte("abc123"),
+		},
+		"key with slashes": {
+			ref:  smv1alpha1.RemoteReference{Name: "/app/db/password"},
+			want: []byte("abc123"),
+		},
+		"escaped key": {
+			ref:  smv1alpha1.RemoteReference{Name: "app/space key"},
+			want: []byte("escaped"),
+		},
+		"json property": {
+			ref:  smv1alpha1.RemoteReference{Name: "app/config", Property: smmeta.String("db.port")},
+			want: []byte("5432"),
+		},
+		"property of plain value": {
+			ref:     smv1alpha1.RemoteReference{Name: "app/db/password", Property: smmeta.String("db")},
+			wantErr: true,
+		},
+		"not found": {
+			ref:     smv1alpha1.RemoteReference{Name: "app/missing"},
+			wantErr: true,
+		},
+		"version": {
+			ref:     smv1alpha1.RemoteReference{Name: "app/db/password", Version: smmeta.String("1")},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := c.GetSecret(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetSecretMap(t *testing.T) {
+	c := newTestConsul(t, map[string]*string{
+		"app/":                    nil,
+		"app/db/":                 nil,
+		"app/db/username":         smmeta.String("bob"),
+		"app/db/password":         smmeta.String("abc123"),
+		"app/db/replica/password": smmeta.String("def456"),
+		"app/token":               smmeta.String("xyz789"),
+		"application/token":       smmeta.String("other"),
+	})
+
+	tests := map[string]struct {
+		ref     smv1alpha1.RemoteReference
+		want    map[string][]byte
+		wantErr bool
+	}{
+		"recursive": {
+			ref: smv1alpha1.RemoteReference{Name: "app"},
+			want: map[string][]byte{
+				"db_username":         []byte("bob"),
+				"db_password":         []byte("abc123"),
+				"db_replica_password": []byte("def456"),
+				"token":               []byte("xyz789"),
+			},
+		},
+		"nested prefix": {
+			ref: smv1alpha1.RemoteReference{Name: "app/db/"},
+			want: map[string][]byte{
+				"username":         []byte("bob"),
+				"password":         []byte("abc123"),
+				"replica_password": []byte("def456"),
+			},
+		},
+		"find without recursion": {
+			ref: smv1alpha1.RemoteReference{Name: "app/db", Find: &smv1alpha1.FindReference{}},
+			want: map[string][]byte{
+				"username": []byte("bob"),
+				"password": []byte("abc123"),
+			},
+		},
+		"find with separator and max depth": {
+			ref: smv1alpha1.RemoteReference{Name: "app", Find: &smv1alpha1.FindReference{
+				Recursive: true,
+				MaxDepth:  int32Ptr(2),
+				Separator: smmeta.String("."),
+			}},
+			want: map[string][]byte{
+				"db.username": []byte("bob"),
+				"db.password": []byte("abc123"),
+				"token":       []byte("xyz789"),
+			},
+		},
+		"no keys": {
+			ref:  smv1alpha1.RemoteReference{Name: "missing"},
+			want: map[string][]byte{},
+		},
+		"version": {
+			ref:     smv1alpha1.RemoteReference{Name: "app", Version: smmeta.String("1")},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := c.GetSecretMap(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestACLDenied(t *testing.T) {
+	c := newTestConsul(t, map[string]*string{"app/token": smmeta.String("xyz789")})
+	c.token = "revoked"
+	if _, err := c.GetSecret(context.Background(), smv1alpha1.RemoteReference{Name: "app/token"}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
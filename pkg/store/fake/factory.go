@@ -25,12 +25,14 @@ import (
 )
 
 var _ store.Client = &Client{}
+var _ store.MetadataClient = &Client{}
 
 type Client struct {
 	NewFn func(context.Context, smv1alpha1.GenericStore, client.Client,
 		string) (store.Client, error)
 	GetSecretFn    func(context.Context, smv1alpha1.RemoteReference) ([]byte, error)
 	GetSecretMapFn func(context.Context, smv1alpha1.RemoteReference) (map[string][]byte, error)
+	MetadataFn     func(context.Context, smv1alpha1.RemoteReference) *store.SecretMetadata
 }
 
 func New() *Client {
@@ -41,6 +43,9 @@ func New() *Client {
 		GetSecretMapFn: func(context.Context, smv1alpha1.RemoteReference) (map[string][]byte, error) {
 			return nil, nil
 		},
+		MetadataFn: func(context.Context, smv1alpha1.RemoteReference) *store.SecretMetadata {
+			return nil
+		},
 	}
 
 	v.NewFn = func(context.Context, smv1alpha1.GenericStore, client.Client, string) (store.Client, error) {
@@ -76,6 +81,29 @@ func (v *Client) WithGetSecretMap(secData map[string][]byte, err error) *Client
 	return v
 }
 
+func (v *Client) GetSecretWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, *store.SecretMetadata, error) {
+	secData, err := v.GetSecretFn(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	return secData, v.MetadataFn(ctx, ref), nil
+}
+
+func (v *Client) GetSecretMapWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, *store.SecretMetadata, error) {
+	secData, err := v.GetSecretMapFn(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	return secData, v.MetadataFn(ctx, ref), nil
+}
+
+func (v *Client) WithMetadata(metadata *store.SecretMetadata) *Client {
+	v.MetadataFn = func(context.Context, smv1alpha1.RemoteReference) *store.SecretMetadata {
+		return metadata
+	}
+	return v
+}
+
 func (v *Client) WithNew(f func(context.Context, smv1alpha1.GenericStore, client.Client,
 	string) (store.Client, error)) *Client {
 	v.NewFn = f
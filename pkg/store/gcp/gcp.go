@@ -17,34 +17,64 @@ package gcp
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/crc32"
+	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 
-	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
 	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
 	ctxlog "github.com/itscontained/secret-manager/pkg/log"
 	"github.com/itscontained/secret-manager/pkg/store"
 	"github.com/itscontained/secret-manager/pkg/store/schema"
+	"github.com/itscontained/secret-manager/pkg/util/property"
+	"github.com/itscontained/secret-manager/pkg/util/storeref"
 
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 	"google.golang.org/api/secretmanager/v1"
+	htransport "google.golang.org/api/transport/http"
 
-	corev1 "k8s.io/api/core/v1"
-
-	"k8s.io/apimachinery/pkg/types"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ store.Client = &GCP{}
+var _ store.MetadataClient = &GCP{}
+
+// ErrChecksumMismatch is returned when the data of an accessed secret
+// version does not match the CRC32C checksum of its payload.
+var ErrChecksumMismatch = errors.New("checksum mismatch of secret payload")
+
+// ErrChecksumMissing is returned when an accessed secret version has no
+// CRC32C checksum to verify its data against.
+var ErrChecksumMissing = errors.New("secret payload has no checksum")
+
+// crc32cTable is the Castagnoli table used for payload checksums.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// defaultEndpoint is the endpoint of the global Secret Manager API.
+const defaultEndpoint = "https://secretmanager.googleapis.com/"
+
+// requestTimeout bounds requests to the Secret Manager API.
+const requestTimeout = 30 * time.Second
 
 type GCP struct {
 	kube   ctrlclient.Client
 	store  smv1alpha1.GenericStore
 	log    logr.Logger
 	client *secretmanager.Service
+	// httpClient is the authenticated http client of client.
+	httpClient *http.Client
+
+	serviceAccounts typedcorev1.ServiceAccountsGetter
 }
 
 func init() {
@@ -69,105 +99,420 @@ func (g *GCP) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctrlc
 }
 
 func (g *GCP) GetSecret(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, error) {
-	version := "latest"
+	data, _, err := g.GetSecretWithMetadata(ctx, ref)
+	return data, err
+}
+
+func (g *GCP) GetSecretWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, *store.SecretMetadata, error) {
+	if ref.Find != nil {
+		return nil, nil, fmt.Errorf("find is only supported in dataFrom")
+	}
+	version := ""
 	if ref.Version != nil {
 		version = *ref.Version
 	}
-	data, err := g.readSecret(ctx, ref.Name, version)
+	data, metadata, err := g.readSecret(ctx, ref.Name, version)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if ref.Property == nil {
+		return data, metadata, nil
+	}
+	propValue, err := property.Get(data, *ref.Property)
+	if err != nil {
+		return nil, nil, err
 	}
-	return data[ref.Name], nil
+	return propValue, metadata, nil
 }
 
 func (g *GCP) GetSecretMap(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, error) {
-	version := "latest"
+	data, _, err := g.GetSecretMapWithMetadata(ctx, ref)
+	return data, err
+}
+
+// GetSecretMapWithMetadata returns the top level values of the JSON object
+// stored in the secret, or with Find the latest enabled versions of the
+// secrets whose id starts with Name, keyed by secret id.
+func (g *GCP) GetSecretMapWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, *store.SecretMetadata, error) {
+	if ref.Find != nil {
+		if ref.Version != nil {
+			return nil, nil, fmt.Errorf("version is not supported with find")
+		}
+		data, err := g.findSecrets(ctx, ref.Name, ref.Find)
+		return data, nil, err
+	}
+	version := ""
 	if ref.Version != nil {
 		version = *ref.Version
 	}
-	return g.readSecret(ctx, ref.Name, version)
+	data, metadata, err := g.readSecret(ctx, ref.Name, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	secretMap, err := property.Map(data)
+	if errors.Is(err, property.ErrNotJSONObject) {
+		key := secretID(ref.Name)
+		if ref.Property != nil {
+			key = *ref.Property
+		}
+		return map[string][]byte{key: data}, metadata, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return secretMap, metadata, nil
 }
 
-func (g *GCP) readSecret(ctx context.Context, id, version string) (map[string][]byte, error) {
-	projectID := g.store.GetSpec().GCP.ProjectID
-	name := id
-	if !strings.HasPrefix(id, "projects/") && projectID != nil {
-		name = fmt.Sprintf("projects/%s/secrets/%s/versions/%s", *projectID, id, version)
+// findSecrets returns the data of the latest enabled version of each secret
+// whose id starts with idPrefix and which matches the labels and name regex
+// of find, keyed by secret id. Secrets without enabled versions are skipped.
+func (g *GCP) findSecrets(ctx context.Context, idPrefix string, find *smv1alpha1.FindReference) (map[string][]byte, error) {
+	var nameRegex *regexp.Regexp
+	if find.NameRegex != nil {
+		var err error
+		nameRegex, err = regexp.Compile(*find.NameRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name regex %q: %w", *find.NameRegex, err)
+		}
 	}
-	resp, err := g.client.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+	parent, err := g.parent()
 	if err != nil {
 		return nil, err
 	}
-	data, err := base64.URLEncoding.DecodeString(resp.Payload.Data)
+
+	var names []string
+	err = g.client.Projects.Secrets.List(parent).Pages(ctx, func(resp *secretmanager.ListSecretsResponse) error {
+		for _, secret := range resp.Secrets {
+			id := secretID(secret.Name)
+			if !strings.HasPrefix(id, idPrefix) || !hasLabels(secret.Labels, find.Labels) {
+				continue
+			}
+			if nameRegex != nil && !nameRegex.MatchString(id) {
+				continue
+			}
+			names = append(names, secret.Name)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error listing secrets of %q: %w", parent, err)
 	}
-	return map[string][]byte{id: data}, nil
-}
 
-func (g *GCP) newClient(ctx context.Context) error {
-	g.log.V(1).Info("creating new gcp api client")
-	var err error
-	var clientOption option.ClientOption
-	spec := g.store.GetSpec().GCP
-	if spec.AuthSecretRef == nil {
-		g.log.V(1).Info("no authentication defined. using environment variables")
-		g.client, err = secretmanager.NewService(ctx)
+	secretMap := make(map[string][]byte, len(names))
+	for _, name := range names {
+		version, err := g.latestEnabledVersion(ctx, name)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		return nil
+		if version == "" {
+			g.log.V(1).Info("skipping secret without enabled versions", "name", name)
+			continue
+		}
+		data, _, err := g.readSecret(ctx, name, version)
+		if err != nil {
+			return nil, fmt.Errorf("error reading secret %q: %w", name, err)
+		}
+		secretMap[secretID(name)] = data
 	}
-	// TODO: Validating Webhook Candidate
-	if spec.AuthSecretRef.JSON != nil && spec.AuthSecretRef.FilePath != nil {
-		return fmt.Errorf("multiple authentication methods configured")
-	}
-	if spec.AuthSecretRef.FilePath != nil {
-		g.log.V(1).Info("file authentication defined. using %s", *spec.AuthSecretRef.FilePath)
-		clientOption = option.WithCredentialsFile(*spec.AuthSecretRef.FilePath)
-	}
-	scoped := true
-	if g.store.GetTypeMeta().Kind == smv1alpha1.ClusterSecretStoreKind {
-		g.log.V(1).Info("removing namespace scope restriction")
-		scoped = false
-	}
-	if spec.AuthSecretRef.JSON != nil {
-		g.log.V(1).Info("JSON authentication defined")
-		namespace := g.store.GetNamespace()
-		if !scoped {
-			if spec.AuthSecretRef.JSON.Namespace == nil {
-				return fmt.Errorf("authsecretref namespace required when cluster-scoped")
+	return secretMap, nil
+}
+
+// latestEnabledVersion returns the highest number of the enabled versions of
+// the secret, or an empty string if no version is enabled.
+func (g *GCP) latestEnabledVersion(ctx context.Context, name string) (string, error) {
+	latest := int64(0)
+	err := g.client.Projects.Secrets.Versions.List(name).Pages(ctx, func(resp *secretmanager.ListSecretVersionsResponse) error {
+		for _, version := range resp.Versions {
+			if version.State != "ENABLED" {
+				continue
+			}
+			n, err := parseSecretName(version.Name)
+			if err != nil {
+				return err
+			}
+			number, err := strconv.ParseInt(n.version, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid number of secret version %q: %w", version.Name, err)
+			}
+			if number > latest {
+				latest = number
 			}
-			namespace = *spec.AuthSecretRef.JSON.Namespace
 		}
-		data, e := g.secretKeyRef(ctx, namespace, *spec.AuthSecretRef.JSON)
-		if e != nil {
-			return err
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error listing versions of secret %q: %w", name, err)
+	}
+	if latest == 0 {
+		return "", nil
+	}
+	return strconv.FormatInt(latest, 10), nil
+}
+
+// hasLabels returns whether labels include all of the wanted labels.
+func hasLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
 		}
-		clientOption = option.WithCredentialsJSON([]byte(data))
 	}
-	g.client, err = secretmanager.NewService(ctx, clientOption)
+	return true
+}
+
+// parent returns the resource name of the project and location of the
+// store, which holds the secrets referenced by id.
+func (g *GCP) parent() (string, error) {
+	spec := g.store.GetSpec().GCP
+	if spec.ProjectID == nil {
+		return "", fmt.Errorf("projectID required in store config to find secrets")
+	}
+	parent := "projects/" + *spec.ProjectID
+	if spec.Location != nil {
+		parent += "/locations/" + *spec.Location
+	}
+	return parent, nil
+}
+
+// accessResponse is the response of accessing a secret version. The
+// generated client drops the checksum of the payload, so secret versions are
+// accessed with the http client of the service.
+type accessResponse struct {
+	Name    string `json:"name"`
+	Payload struct {
+		Data string `json:"data"`
+		// DataCrc32c is the CRC32C checksum of the data, encoded as a
+		// decimal string.
+		DataCrc32c string `json:"dataCrc32c"`
+	} `json:"payload"`
+}
+
+// readSecret returns the data of the secret version and its number, which
+// differs from the requested version if an alias or "latest" was requested.
+// The data is verified against the checksum of the payload.
+func (g *GCP) readSecret(ctx context.Context, id, version string) ([]byte, *store.SecretMetadata, error) {
+	name, err := g.versionName(id, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.client.BasePath+"v1/"+name+":access", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error accessing secret version %q: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return nil, nil, fmt.Errorf("error accessing secret version %q: %w", name, err)
+	}
+	access := &accessResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(access); err != nil {
+		return nil, nil, fmt.Errorf("error decoding secret version %q: %w", name, err)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(access.Payload.Data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decoding payload of secret version %q: %w", name, err)
+	}
+	if access.Payload.DataCrc32c == "" {
+		return nil, nil, fmt.Errorf("secret version %q: %w", name, ErrChecksumMissing)
+	}
+	checksum, err := strconv.ParseInt(access.Payload.DataCrc32c, 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing checksum of secret version %q: %w", name, err)
+	}
+	if int64(crc32.Checksum(data, crc32cTable)) != checksum {
+		return nil, nil, fmt.Errorf("secret version %q: %w", name, ErrChecksumMismatch)
+	}
+
+	metadata := &store.SecretMetadata{}
+	if resolved, err := parseSecretName(access.Name); err == nil {
+		metadata.Version = resolved.version
+	}
+	return data, metadata, nil
+}
+
+// secretVersionName is the resource name of a secret version, e.g.
+// "projects/my-project/locations/europe-west1/secrets/my-secret/versions/1".
+// The location is empty for global secrets.
+type secretVersionName struct {
+	project  string
+	location string
+	secret   string
+	version  string
+}
+
+func (n secretVersionName) String() string {
+	name := "projects/" + n.project
+	if n.location != "" {
+		name += "/locations/" + n.location
+	}
+	return name + "/secrets/" + n.secret + "/versions/" + n.version
+}
+
+// parseSecretName parses the resource name of a global or regional secret
+// or secret version. The version is empty for the name of a secret.
+func parseSecretName(name string) (secretVersionName, error) {
+	segments := strings.Split(name, "/")
+	n := secretVersionName{}
+	if len(segments) >= 2 && segments[0] == "projects" {
+		n.project, segments = segments[1], segments[2:]
+	}
+	if len(segments) >= 2 && segments[0] == "locations" {
+		n.location, segments = segments[1], segments[2:]
+	}
+	if len(segments) >= 2 && segments[0] == "secrets" {
+		n.secret, segments = segments[1], segments[2:]
+	}
+	if len(segments) == 2 && segments[0] == "versions" {
+		n.version, segments = segments[1], nil
+	}
+	if n.project == "" || n.secret == "" || len(segments) != 0 {
+		return secretVersionName{}, fmt.Errorf("invalid secret name %q", name)
+	}
+	return n, nil
+}
+
+// versionName resolves a reference to the resource name of a secret version.
+// The name is either a secret id in the project and location of the store,
+// or the full resource name of a secret in any project, or of one of its
+// versions. The version defaults to "latest".
+func (g *GCP) versionName(name, version string) (string, error) {
+	spec := g.store.GetSpec().GCP
+	location := ""
+	if spec.Location != nil {
+		location = *spec.Location
+	}
+	var n secretVersionName
+	if strings.HasPrefix(name, "projects/") {
+		var err error
+		n, err = parseSecretName(name)
+		if err != nil {
+			return "", err
+		}
+		if n.location != location {
+			return "", fmt.Errorf("secret %q is not in the location of the store %q", name, location)
+		}
+	} else {
+		if strings.Contains(name, "/") {
+			return "", fmt.Errorf("invalid secret id %q", name)
+		}
+		if spec.ProjectID == nil {
+			return "", fmt.Errorf("projectID required in store config to reference secret %q by id", name)
+		}
+		n = secretVersionName{
+			project:  *spec.ProjectID,
+			location: location,
+			secret:   name,
+		}
+	}
+	switch {
+	case n.version != "" && version != "" && n.version != version:
+		return "", fmt.Errorf("version %q conflicts with secret version name %q", version, name)
+	case n.version == "" && version != "":
+		n.version = version
+	case n.version == "":
+		n.version = "latest"
+	}
+	return n.String(), nil
+}
+
+// secretID returns the id of the secret given by name, which is either the
+// id or the resource name of the secret or one of its versions.
+func secretID(name string) string {
+	const secretsSegment = "/secrets/"
+	i := strings.Index(name, secretsSegment)
+	if i < 0 {
+		return name
+	}
+	return strings.SplitN(name[i+len(secretsSegment):], "/", 2)[0]
+}
+
+func (g *GCP) newClient(ctx context.Context) error {
+	g.log.V(1).Info("creating new gcp api client")
+	clientOptions, err := g.credentialOptions(ctx)
 	if err != nil {
 		return err
 	}
+	if location := g.store.GetSpec().GCP.Location; location != nil {
+		// regional secrets are only served by the endpoint of their location
+		clientOptions = append(clientOptions, option.WithEndpoint(fmt.Sprintf("https://secretmanager.%s.rep.googleapis.com/", *location)))
+	}
+	clientOptions = append([]option.ClientOption{
+		option.WithScopes(cloudPlatformScope),
+		option.WithEndpoint(defaultEndpoint),
+	}, clientOptions...)
+	httpClient, endpoint, err := htransport.NewClient(ctx, clientOptions...)
+	if err != nil {
+		return err
+	}
+	httpClient.Timeout = requestTimeout
+	g.client, err = secretmanager.NewService(ctx, option.WithHTTPClient(httpClient), option.WithEndpoint(endpoint))
+	if err != nil {
+		return err
+	}
+	g.httpClient = httpClient
 	return nil
 }
 
-func (g *GCP) secretKeyRef(ctx context.Context, namespace string, secretRef smmeta.SecretKeySelector) (string, error) {
-	g.log.V(1).Info("retrieving kubernetes secret", "name", secretRef.Name)
-	var secret corev1.Secret
-	ref := types.NamespacedName{
-		Namespace: namespace,
-		Name:      secretRef.Name,
+// credentialOptions returns the client options authenticating with GCP as
+// configured in the store.
+func (g *GCP) credentialOptions(ctx context.Context) ([]option.ClientOption, error) {
+	spec := g.store.GetSpec().GCP
+	// TODO: Validating Webhook Candidate
+	if spec.AuthSecretRef != nil && spec.WorkloadIdentity != nil {
+		return nil, fmt.Errorf("multiple authentication methods configured")
 	}
-	err := g.kube.Get(ctx, ref, &secret)
-	if err != nil {
-		return "", err
+	var clientOptions []option.ClientOption
+	impersonate := spec.Impersonate
+	switch {
+	case spec.AuthSecretRef != nil:
+		clientOption, err := g.authSecretRefOption(ctx, spec.AuthSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions = append(clientOptions, clientOption)
+	case spec.WorkloadIdentity != nil:
+		g.log.V(1).Info("workload identity authentication defined")
+		ts, gcpServiceAccount, err := g.workloadIdentityTokenSource(ctx, spec)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions = append(clientOptions, option.WithTokenSource(ts))
+		if impersonate == nil && gcpServiceAccount != "" {
+			impersonate = &smv1alpha1.GCPImpersonation{ServiceAccount: gcpServiceAccount}
+		}
+	default:
+		g.log.V(1).Info("no authentication defined. using environment variables")
+	}
+	if impersonate != nil {
+		g.log.V(1).Info("impersonating service account", "serviceAccount", impersonate.ServiceAccount)
+		ts, err := newImpersonatedTokenSource(ctx, impersonate, clientOptions)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions = []option.ClientOption{option.WithTokenSource(ts)}
 	}
-	keyBytes, ok := secret.Data[secretRef.Key]
-	if !ok {
-		return "", fmt.Errorf("no data for %q in secret '%s/%s'", secretRef.Key, secretRef.Name, namespace)
+	return clientOptions, nil
+}
+
+func (g *GCP) authSecretRefOption(ctx context.Context, auth *smv1alpha1.GCPAuth) (option.ClientOption, error) {
+	// TODO: Validating Webhook Candidate
+	if auth.JSON != nil && auth.FilePath != nil {
+		return nil, fmt.Errorf("multiple authentication methods configured")
+	}
+	if auth.FilePath != nil {
+		g.log.V(1).Info("file authentication defined. using %s", *auth.FilePath)
+		return option.WithCredentialsFile(*auth.FilePath), nil
+	}
+	if auth.JSON == nil {
+		return nil, fmt.Errorf("missing json or filePath in store config")
+	}
+	g.log.V(1).Info("JSON authentication defined")
+	data, err := storeref.SecretKey(ctx, g.kube, g.store, "json", *auth.JSON)
+	if err != nil {
+		return nil, err
 	}
-	value := strings.TrimSpace(string(keyBytes))
-	return value, nil
+	return option.WithCredentialsJSON([]byte(data)), nil
 }
@@ -0,0 +1,788 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+
+	"google.golang.org/api/iamcredentials/v1"
+	"google.golang.org/api/option"
+	"google.golang.org/api/secretmanager/v1"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestGCP returns a GCP store client for the project "my-project" sending
+// requests to a stand-in for the Secret Manager API serving the given
+// payloads by secret version name. Aliases map requested version names to
+// the names of the versions they resolve to.
+func newTestGCP(t *testing.T, payloads, aliases map[string]string) *GCP {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/v1/")
+		if r.Method != http.MethodGet || !strings.HasSuffix(name, ":access") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		name = strings.TrimSuffix(name, ":access")
+		if resolved, ok := aliases[name]; ok {
+			name = resolved
+		}
+		payload, ok := payloads[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": {"code": 404, "message": "Secret Version not found", "status": "NOT_FOUND"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"name": name,
+			"payload": map[string]string{
+				"data":       base64.StdEncoding.EncodeToString([]byte(payload)),
+				"dataCrc32c": strconv.FormatUint(uint64(crc32.Checksum([]byte(payload), crc32cTable)), 10),
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return newTestGCPClient(t, srv.URL)
+}
+
+// newTestGCPClient returns a GCP store client for the project "my-project"
+// sending requests to the Secret Manager API at url.
+func newTestGCPClient(t *testing.T, url string) *GCP {
+	t.Helper()
+
+	client, err := secretmanager.NewService(context.Background(),
+		option.WithEndpoint(url+"/"),
+		option.WithoutAuthentication(),
+	)
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+
+	return &GCP{
+		store: &smv1alpha1.SecretStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "gcp",
+				Namespace: "default",
+			},
+			Spec: smv1alpha1.SecretStoreSpec{
+				GCP: &smv1alpha1.GCPStore{
+					ProjectID: smmeta.String("my-project"),
+				},
+			},
+		},
+		log:        ctrl.Log,
+		client:     client,
+		httpClient: http.DefaultClient,
+	}
+}
+
+func TestGetSecret(t *testing.T) {
+	g := newTestGCP(t, map[string]string{
+		"projects/my-project/secrets/json/versions/1":   `{"username": "bob", "db": {"password": "abc123", "port": 5432}}`,
+		"projects/my-project/secrets/plain/versions/1":  "old",
+		"projects/my-project/secrets/plain/versions/2":  "abc123",
+		"projects/my-project/secrets/binary/versions/1": "\xde\xad\xbe\xef",
+	}, map[string]string{
+		"projects/my-project/secrets/json/versions/latest":   "projects/my-project/secrets/json/versions/1",
+		"projects/my-project/secrets/plain/versions/latest":  "projects/my-project/secrets/plain/versions/2",
+		"projects/my-project/secrets/plain/versions/stable":  "projects/my-project/secrets/plain/versions/1",
+		"projects/my-project/secrets/binary/versions/latest": "projects/my-project/secrets/binary/versions/1",
+	})
+
+	tests := map[string]struct {
+		ref         smv1alpha1.RemoteReference
+		want        []byte
+		wantVersion string
+		wantErr     bool
+	}{
+		"json without property": {
+			ref:         smv1alpha1.RemoteReference{Name: "json"},
+			want:        []byte(`{"username": "bob", "db": {"password": "abc123", "port": 5432}}`),
+			wantVersion: "1",
+		},
+		"json property": {
+			ref:         smv1alpha1.RemoteReference{Name: "json", Property: smmeta.String("username")},
+			want:        []byte("bob"),
+			wantVersion: "1",
+		},
+		"json nested property": {
+			ref:         smv1alpha1.RemoteReference{Name: "json", Property: smmeta.String("db.port")},
+			want:        []byte("5432"),
+			wantVersion: "1",
+		},
+		"json missing property": {
+			ref:     smv1alpha1.RemoteReference{Name: "json", Property: smmeta.String("missing")},
+			wantErr: true,
+		},
+		"plain": {
+			ref:         smv1alpha1.RemoteReference{Name: "plain"},
+			want:        []byte("abc123"),
+			wantVersion: "2",
+		},
+		"plain version": {
+			ref:         smv1alpha1.RemoteReference{Name: "plain", Version: smmeta.String("1")},
+			want:        []byte("old"),
+			wantVersion: "1",
+		},
+		"plain version alias": {
+			ref:         smv1alpha1.RemoteReference{Name: "plain", Version: smmeta.String("stable")},
+			want:        []byte("old"),
+			wantVersion: "1",
+		},
+		"plain property": {
+			ref:     smv1alpha1.RemoteReference{Name: "plain", Property: smmeta.String("username")},
+			wantErr: true,
+		},
+		"binary": {
+			ref:         smv1alpha1.RemoteReference{Name: "binary"},
+			want:        []byte{0xde, 0xad, 0xbe, 0xef},
+			wantVersion: "1",
+		},
+		"not found": {
+			ref:     smv1alpha1.RemoteReference{Name: "missing"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, meta, err := g.GetSecretWithMetadata(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+			if meta.Version != tc.wantVersion {
+				t.Errorf("expected version %q, got %q", tc.wantVersion, meta.Version)
+			}
+		})
+	}
+}
+
+func TestGetSecretChecksum(t *testing.T) {
+	tests := map[string]struct {
+		payload map[string]string
+		want    []byte
+		wantErr bool
+		errIs   error
+	}{
+		"matching checksum": {
+			payload: map[string]string{"data": "YWJjMTIz", "dataCrc32c": "26154185"},
+			want:    []byte("abc123"),
+		},
+		"mismatching checksum": {
+			payload: map[string]string{"data": "YWJjMTIz", "dataCrc32c": "1"},
+			wantErr: true,
+			errIs:   ErrChecksumMismatch,
+		},
+		"no checksum": {
+			payload: map[string]string{"data": "YWJjMTIz"},
+			wantErr: true,
+			errIs:   ErrChecksumMissing,
+		},
+		"url-safe encoding": {
+			payload: map[string]string{"data": "3q2-7w==", "dataCrc32c": "0"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{
+					"name":    "projects/my-project/secrets/plain/versions/1",
+					"payload": tc.payload,
+				})
+			}))
+			defer srv.Close()
+			g := newTestGCPClient(t, srv.URL)
+
+			got, err := g.GetSecret(context.Background(), smv1alpha1.RemoteReference{Name: "plain"})
+			if tc.wantErr {
+				if err == nil || (tc.errIs != nil && !errors.Is(err, tc.errIs)) {
+					t.Fatalf("expected error %v, got %v", tc.errIs, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetSecretMap(t *testing.T) {
+	g := newTestGCP(t, map[string]string{
+		"projects/my-project/secrets/json/versions/latest":  `{"username": "bob", "db": {"password": "abc123"}}`,
+		"projects/my-project/secrets/plain/versions/latest": "abc123",
+	}, nil)
+
+	tests := map[string]struct {
+		ref  smv1alpha1.RemoteReference
+		want map[string][]byte
+	}{
+		"json": {
+			ref: smv1alpha1.RemoteReference{Name: "json"},
+			want: map[string][]byte{
+				"username": []byte("bob"),
+				"db":       []byte(`{"password":"abc123"}`),
+			},
+		},
+		"plain with secret id key": {
+			ref: smv1alpha1.RemoteReference{Name: "projects/my-project/secrets/plain/versions/latest"},
+			want: map[string][]byte{
+				"plain": []byte("abc123"),
+			},
+		},
+		"plain with key from property": {
+			ref: smv1alpha1.RemoteReference{Name: "plain", Property: smmeta.String("token")},
+			want: map[string][]byte{
+				"token": []byte("abc123"),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := g.GetSecretMap(context.Background(), tc.ref)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// fakeSecret is a secret served by newTestGCPFind.
+type fakeSecret struct {
+	labels map[string]string
+	// versions maps the version numbers of the secret to their state.
+	versions map[string]string
+}
+
+// newTestGCPFind returns a GCP store client for the project "my-project"
+// sending requests to a stand-in for the Secret Manager API listing the given
+// secrets by id, one per page. The payload of a version is "<id>/<number>".
+func newTestGCPFind(t *testing.T, secrets map[string]fakeSecret) *GCP {
+	t.Helper()
+	ids := make([]string, 0, len(secrets))
+	for id := range secrets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	const parent = "projects/my-project/secrets"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/v1/")
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case path == parent:
+			page, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+			resp := &secretmanager.ListSecretsResponse{}
+			if page < len(ids) {
+				id := ids[page]
+				resp.Secrets = []*secretmanager.Secret{{
+					Name:   parent + "/" + id,
+					Labels: secrets[id].labels,
+				}}
+			}
+			if page+1 < len(ids) {
+				resp.NextPageToken = strconv.Itoa(page + 1)
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+		case strings.HasSuffix(path, "/versions"):
+			id := strings.TrimSuffix(strings.TrimPrefix(path, parent+"/"), "/versions")
+			resp := &secretmanager.ListSecretVersionsResponse{}
+			for number, state := range secrets[id].versions {
+				resp.Versions = append(resp.Versions, &secretmanager.SecretVersion{
+					Name:  parent + "/" + id + "/versions/" + number,
+					State: state,
+				})
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+		case strings.HasSuffix(path, ":access"):
+			name := strings.TrimSuffix(path, ":access")
+			n, err := parseSecretName(name)
+			if err != nil || secrets[n.secret].versions[n.version] != "ENABLED" {
+				t.Errorf("unexpected access of %q", name)
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			data := []byte(n.secret + "/" + n.version)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"name": name,
+				"payload": map[string]string{
+					"data":       base64.StdEncoding.EncodeToString(data),
+					"dataCrc32c": strconv.FormatUint(uint64(crc32.Checksum(data, crc32cTable)), 10),
+				},
+			})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return newTestGCPClient(t, srv.URL)
+}
+
+func TestGetSecretMapFind(t *testing.T) {
+	g := newTestGCPFind(t, map[string]fakeSecret{
+		"app-db-password": {
+			labels:   map[string]string{"app": "web", "env": "prod"},
+			versions: map[string]string{"1": "ENABLED", "2": "ENABLED", "3": "DISABLED"},
+		},
+		"app-db-username": {
+			labels:   map[string]string{"app": "web", "env": "dev"},
+			versions: map[string]string{"1": "ENABLED"},
+		},
+		"app-token": {
+			labels:   map[string]string{"app": "web", "env": "prod"},
+			versions: map[string]string{"1": "DESTROYED", "2": "DISABLED"},
+		},
+		"other-token": {
+			labels:   map[string]string{"app": "other", "env": "prod"},
+			versions: map[string]string{"10": "ENABLED", "9": "ENABLED"},
+		},
+	})
+
+	tests := map[string]struct {
+		ref     smv1alpha1.RemoteReference
+		want    map[string][]byte
+		wantErr bool
+	}{
+		"all": {
+			ref: smv1alpha1.RemoteReference{Find: &smv1alpha1.FindReference{}},
+			want: map[string][]byte{
+				"app-db-password": []byte("app-db-password/2"),
+				"app-db-username": []byte("app-db-username/1"),
+				"other-token":     []byte("other-token/10"),
+			},
+		},
+		"id prefix": {
+			ref: smv1alpha1.RemoteReference{Name: "app-db-", Find: &smv1alpha1.FindReference{}},
+			want: map[string][]byte{
+				"app-db-password": []byte("app-db-password/2"),
+				"app-db-username": []byte("app-db-username/1"),
+			},
+		},
+		"labels": {
+			ref: smv1alpha1.RemoteReference{Find: &smv1alpha1.FindReference{
+				Labels: map[string]string{"env": "prod"},
+			}},
+			want: map[string][]byte{
+				"app-db-password": []byte("app-db-password/2"),
+				"other-token":     []byte("other-token/10"),
+			},
+		},
+		"name regex": {
+			ref: smv1alpha1.RemoteReference{Find: &smv1alpha1.FindReference{
+				NameRegex: smmeta.String("-(username|token)$"),
+			}},
+			want: map[string][]byte{
+				"app-db-username": []byte("app-db-username/1"),
+				"other-token":     []byte("other-token/10"),
+			},
+		},
+		"no match": {
+			ref: smv1alpha1.RemoteReference{Find: &smv1alpha1.FindReference{
+				Labels: map[string]string{"app": "missing"},
+			}},
+			want: map[string][]byte{},
+		},
+		"invalid name regex": {
+			ref: smv1alpha1.RemoteReference{Find: &smv1alpha1.FindReference{
+				NameRegex: smmeta.String("("),
+			}},
+			wantErr: true,
+		},
+		"version": {
+			ref: smv1alpha1.RemoteReference{
+				Version: smmeta.String("1"),
+				Find:    &smv1alpha1.FindReference{},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := g.GetSecretMap(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestVersionName(t *testing.T) {
+	tests := map[string]struct {
+		spec    smv1alpha1.GCPStore
+		name    string
+		version string
+		want    string
+		wantErr bool
+	}{
+		"secret id": {
+			spec: smv1alpha1.GCPStore{ProjectID: smmeta.String("my-project")},
+			name: "my-secret",
+			want: "projects/my-project/secrets/my-secret/versions/latest",
+		},
+		"secret id with version": {
+			spec:    smv1alpha1.GCPStore{ProjectID: smmeta.String("my-project")},
+			name:    "my-secret",
+			version: "3",
+			want:    "projects/my-project/secrets/my-secret/versions/3",
+		},
+		"secret id without project": {
+			name:    "my-secret",
+			wantErr: true,
+		},
+		"invalid secret id": {
+			spec:    smv1alpha1.GCPStore{ProjectID: smmeta.String("my-project")},
+			name:    "secrets/my-secret",
+			wantErr: true,
+		},
+		"secret name without project": {
+			name:    "projects/other-project/secrets/my-secret",
+			version: "2",
+			want:    "projects/other-project/secrets/my-secret/versions/2",
+		},
+		"secret name in other project": {
+			spec: smv1alpha1.GCPStore{ProjectID: smmeta.String("my-project")},
+			name: "projects/other-project/secrets/my-secret",
+			want: "projects/other-project/secrets/my-secret/versions/latest",
+		},
+		"secret version name": {
+			name: "projects/my-project/secrets/my-secret/versions/1",
+			want: "projects/my-project/secrets/my-secret/versions/1",
+		},
+		"secret version name with same version": {
+			name:    "projects/my-project/secrets/my-secret/versions/1",
+			version: "1",
+			want:    "projects/my-project/secrets/my-secret/versions/1",
+		},
+		"secret version name with conflicting version": {
+			name:    "projects/my-project/secrets/my-secret/versions/1",
+			version: "2",
+			wantErr: true,
+		},
+		"invalid secret name": {
+			name:    "projects/my-project/my-secret",
+			wantErr: true,
+		},
+		"regional secret id": {
+			spec: smv1alpha1.GCPStore{
+				ProjectID: smmeta.String("my-project"),
+				Location:  smmeta.String("europe-west1"),
+			},
+			name: "my-secret",
+			want: "projects/my-project/locations/europe-west1/secrets/my-secret/versions/latest",
+		},
+		"regional secret name": {
+			spec: smv1alpha1.GCPStore{Location: smmeta.String("europe-west1")},
+			name: "projects/my-project/locations/europe-west1/secrets/my-secret/versions/1",
+			want: "projects/my-project/locations/europe-west1/secrets/my-secret/versions/1",
+		},
+		"regional secret name in other location": {
+			spec:    smv1alpha1.GCPStore{Location: smmeta.String("europe-west1")},
+			name:    "projects/my-project/locations/us-east1/secrets/my-secret",
+			wantErr: true,
+		},
+		"regional secret name without location": {
+			name:    "projects/my-project/locations/europe-west1/secrets/my-secret",
+			wantErr: true,
+		},
+		"global secret name with location": {
+			spec:    smv1alpha1.GCPStore{Location: smmeta.String("europe-west1")},
+			name:    "projects/my-project/secrets/my-secret",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			spec := tc.spec
+			g := &GCP{
+				store: &smv1alpha1.SecretStore{
+					Spec: smv1alpha1.SecretStoreSpec{GCP: &spec},
+				},
+			}
+			got, err := g.versionName(tc.name, tc.version)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// authServer is a stand-in for the GCP Security Token Service, the IAM
+// Service Account Credentials API and the Secret Manager API recording the
+// requests made while authenticating.
+type authServer struct {
+	// stsRequest is the body of the last token exchange request.
+	stsRequest map[string]string
+	// iamName and iamRequest are the service account name and body of the
+	// last generateAccessToken request, iamAuth its authorization header.
+	iamName    string
+	iamRequest iamcredentials.GenerateAccessTokenRequest
+	iamAuth    string
+	// secretAuth is the authorization header of the last secret access.
+	secretAuth string
+}
+
+func newTestAuthServer(t *testing.T) (*authServer, string) {
+	t.Helper()
+	a := &authServer{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/token":
+			if err := json.NewDecoder(r.Body).Decode(&a.stsRequest); err != nil {
+				t.Errorf("error decoding token exchange request: %v", err)
+			}
+			_, _ = w.Write([]byte(`{"access_token": "federated", "token_type": "Bearer", "expires_in": 3600}`))
+		case strings.HasSuffix(r.URL.Path, ":generateAccessToken"):
+			a.iamName = strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/"), ":generateAccessToken")
+			a.iamAuth = r.Header.Get("Authorization")
+			if err := json.NewDecoder(r.Body).Decode(&a.iamRequest); err != nil {
+				t.Errorf("error decoding generateAccessToken request: %v", err)
+			}
+			_ = json.NewEncoder(w).Encode(&iamcredentials.GenerateAccessTokenResponse{
+				AccessToken: "impersonated",
+				ExpireTime:  time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+			})
+		case strings.HasSuffix(r.URL.Path, ":access"):
+			a.secretAuth = r.Header.Get("Authorization")
+			_ = json.NewEncoder(w).Encode(&secretmanager.AccessSecretVersionResponse{
+				Payload: &secretmanager.SecretPayload{},
+			})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	oldSTSTokenURL, oldIAMCredentialsEndpoint := stsTokenURL, iamCredentialsEndpoint
+	stsTokenURL, iamCredentialsEndpoint = srv.URL+"/v1/token", srv.URL+"/"
+	t.Cleanup(func() {
+		stsTokenURL, iamCredentialsEndpoint = oldSTSTokenURL, oldIAMCredentialsEndpoint
+	})
+	return a, srv.URL + "/"
+}
+
+func TestCredentialOptionsWorkloadIdentity(t *testing.T) {
+	serviceAccounts := []runtime.Object{
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "plain", Namespace: "default"},
+		},
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "annotated",
+				Namespace: "default",
+				Annotations: map[string]string{
+					GCPServiceAccountAnnotation: "annotated@my-project.iam.gserviceaccount.com",
+				},
+			},
+		},
+	}
+	workloadIdentity := func(name string) *smv1alpha1.GCPWorkloadIdentity {
+		return &smv1alpha1.GCPWorkloadIdentity{
+			ServiceAccountRef: smmeta.ServiceAccountSelector{Name: name},
+			ClusterLocation:   "europe-west1",
+			ClusterName:       "my-cluster",
+		}
+	}
+
+	tests := map[string]struct {
+		store          smv1alpha1.GenericStore
+		wantIAMName    string
+		wantDelegates  []string
+		wantSecretAuth string
+		wantErr        bool
+	}{
+		"workload identity": {
+			store: &smv1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "gcp", Namespace: "default"},
+				Spec: smv1alpha1.SecretStoreSpec{GCP: &smv1alpha1.GCPStore{
+					ProjectID:        smmeta.String("my-project"),
+					WorkloadIdentity: workloadIdentity("plain"),
+				}},
+			},
+			wantSecretAuth: "Bearer federated",
+		},
+		"service account from annotation": {
+			store: &smv1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "gcp", Namespace: "default"},
+				Spec: smv1alpha1.SecretStoreSpec{GCP: &smv1alpha1.GCPStore{
+					ProjectID:        smmeta.String("my-project"),
+					WorkloadIdentity: workloadIdentity("annotated"),
+				}},
+			},
+			wantIAMName:    "projects/-/serviceAccounts/annotated@my-project.iam.gserviceaccount.com",
+			wantSecretAuth: "Bearer impersonated",
+		},
+		"impersonate with delegates": {
+			store: &smv1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "gcp", Namespace: "default"},
+				Spec: smv1alpha1.SecretStoreSpec{GCP: &smv1alpha1.GCPStore{
+					ProjectID:        smmeta.String("my-project"),
+					WorkloadIdentity: workloadIdentity("annotated"),
+					Impersonate: &smv1alpha1.GCPImpersonation{
+						ServiceAccount: "target@other-project.iam.gserviceaccount.com",
+						Delegates:      []string{"delegate@my-project.iam.gserviceaccount.com"},
+					},
+				}},
+			},
+			wantIAMName:    "projects/-/serviceAccounts/target@other-project.iam.gserviceaccount.com",
+			wantDelegates:  []string{"projects/-/serviceAccounts/delegate@my-project.iam.gserviceaccount.com"},
+			wantSecretAuth: "Bearer impersonated",
+		},
+		"cluster store without namespace": {
+			store: &smv1alpha1.ClusterSecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "gcp"},
+				Spec: smv1alpha1.SecretStoreSpec{GCP: &smv1alpha1.GCPStore{
+					ProjectID:        smmeta.String("my-project"),
+					WorkloadIdentity: workloadIdentity("plain"),
+				}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv, endpoint := newTestAuthServer(t)
+			var tokenAudiences []string
+			clientset := fakekube.NewSimpleClientset()
+			clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				tokenAudiences = action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest).Spec.Audiences
+				return true, &authenticationv1.TokenRequest{
+					Status: authenticationv1.TokenRequestStatus{Token: "k8s-jwt"},
+				}, nil
+			})
+			g := &GCP{
+				kube:            fakeclient.NewFakeClientWithScheme(scheme.Scheme, serviceAccounts...),
+				store:           tc.store,
+				log:             ctrl.Log,
+				serviceAccounts: clientset.CoreV1(),
+			}
+
+			clientOptions, err := g.credentialOptions(context.Background())
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			client, err := secretmanager.NewService(context.Background(), append(clientOptions, option.WithEndpoint(endpoint))...)
+			if err != nil {
+				t.Fatalf("error creating client: %v", err)
+			}
+			if _, err := client.Projects.Secrets.Versions.Access("projects/my-project/secrets/s/versions/latest").Do(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(tokenAudiences, []string{"my-project.svc.id.goog"}) {
+				t.Errorf("expected token audience %q, got %v", "my-project.svc.id.goog", tokenAudiences)
+			}
+			wantSTS := map[string]string{
+				"audience":     "identitynamespace:my-project.svc.id.goog:https://container.googleapis.com/v1/projects/my-project/locations/europe-west1/clusters/my-cluster",
+				"subjectToken": "k8s-jwt",
+			}
+			for k, v := range wantSTS {
+				if srv.stsRequest[k] != v {
+					t.Errorf("expected token exchange %s %q, got %q", k, v, srv.stsRequest[k])
+				}
+			}
+			if srv.iamName != tc.wantIAMName {
+				t.Errorf("expected impersonated service account %q, got %q", tc.wantIAMName, srv.iamName)
+			}
+			if tc.wantIAMName != "" {
+				if srv.iamAuth != "Bearer federated" {
+					t.Errorf("expected impersonation with %q, got %q", "Bearer federated", srv.iamAuth)
+				}
+				if !reflect.DeepEqual(srv.iamRequest.Delegates, tc.wantDelegates) {
+					t.Errorf("expected delegates %v, got %v", tc.wantDelegates, srv.iamRequest.Delegates)
+				}
+			}
+			if srv.secretAuth != tc.wantSecretAuth {
+				t.Errorf("expected secret access with %q, got %q", tc.wantSecretAuth, srv.secretAuth)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	"github.com/itscontained/secret-manager/pkg/util/serviceaccount"
+	"github.com/itscontained/secret-manager/pkg/util/storeref"
+
+	"golang.org/x/oauth2"
+
+	"google.golang.org/api/iamcredentials/v1"
+	"google.golang.org/api/option"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// GCPServiceAccountAnnotation is the annotation of a Kubernetes
+	// ServiceAccount giving the GCP service account it acts as.
+	GCPServiceAccountAnnotation = "iam.gke.io/gcp-service-account"
+
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+	// tokenTimeout bounds requesting a ServiceAccount token and exchanging
+	// it with the Security Token Service, and generating impersonated
+	// access tokens.
+	tokenTimeout = 30 * time.Second
+)
+
+var (
+	// stsTokenURL is the endpoint of the GCP Security Token Service
+	// exchanging Kubernetes ServiceAccount tokens for access tokens.
+	stsTokenURL = "https://sts.googleapis.com/v1/token"
+	// iamCredentialsEndpoint overrides the endpoint of the IAM Service
+	// Account Credentials API if set.
+	iamCredentialsEndpoint = ""
+)
+
+// workloadIdentityTokenSource returns a source of access tokens of the
+// Workload Identity of the referenced ServiceAccount, along with the GCP
+// service account given by its annotation.
+func (g *GCP) workloadIdentityTokenSource(ctx context.Context, spec *smv1alpha1.GCPStore) (oauth2.TokenSource, string, error) {
+	wi := spec.WorkloadIdentity
+	namespace, err := storeref.Namespace(g.store, "serviceAccountRef", wi.ServiceAccountRef.Namespace)
+	if err != nil {
+		return nil, "", err
+	}
+	ref := types.NamespacedName{
+		Namespace: namespace,
+		Name:      wi.ServiceAccountRef.Name,
+	}
+	sa := &corev1.ServiceAccount{}
+	if err := g.kube.Get(ctx, ref, sa); err != nil {
+		return nil, "", err
+	}
+
+	projectID := wi.ClusterProjectID
+	if projectID == nil {
+		projectID = spec.ProjectID
+	}
+	if projectID == nil {
+		return nil, "", fmt.Errorf("missing clusterProjectID or projectID in store config")
+	}
+	if g.serviceAccounts == nil {
+		client, err := serviceaccount.Client()
+		if err != nil {
+			return nil, "", err
+		}
+		g.serviceAccounts = client
+	}
+
+	idPool := fmt.Sprintf("%s.svc.id.goog", *projectID)
+	idProvider := fmt.Sprintf("https://container.googleapis.com/v1/projects/%s/locations/%s/clusters/%s",
+		*projectID, wi.ClusterLocation, wi.ClusterName)
+	audiences := wi.ServiceAccountRef.Audiences
+	if len(audiences) == 0 {
+		audiences = []string{idPool}
+	}
+	ts := &workloadIdentityTokenSource{
+		serviceAccounts: g.serviceAccounts,
+		ref:             ref,
+		audiences:       audiences,
+		audience:        fmt.Sprintf("identitynamespace:%s:%s", idPool, idProvider),
+	}
+	return oauth2.ReuseTokenSource(nil, ts), sa.Annotations[GCPServiceAccountAnnotation], nil
+}
+
+// workloadIdentityTokenSource exchanges ServiceAccount tokens for access
+// tokens with the GCP Security Token Service.
+type workloadIdentityTokenSource struct {
+	serviceAccounts typedcorev1.ServiceAccountsGetter
+	ref             types.NamespacedName
+	audiences       []string
+	audience        string
+}
+
+func (w *workloadIdentityTokenSource) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenTimeout)
+	defer cancel()
+	jwt, err := serviceaccount.Token(ctx, w.serviceAccounts, w.ref.Namespace, w.ref.Name, w.audiences)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"grantType":          "urn:ietf:params:oauth:grant-type:token-exchange",
+		"audience":           w.audience,
+		"scope":              cloudPlatformScope,
+		"requestedTokenType": "urn:ietf:params:oauth:token-type:access_token",
+		"subjectToken":       jwt,
+		"subjectTokenType":   "urn:ietf:params:oauth:token-type:jwt",
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, stsTokenURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging service account token: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging service account token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error exchanging service account token: %s: %s", resp.Status, respBody)
+	}
+	token := struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+	if err := json.Unmarshal(respBody, &token); err != nil {
+		return nil, fmt.Errorf("error decoding exchanged token: %w", err)
+	}
+	return &oauth2.Token{
+		AccessToken: token.AccessToken,
+		TokenType:   token.TokenType,
+		Expiry:      time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// newImpersonatedTokenSource returns a source of access tokens of the
+// impersonated service account, generated with the credentials given by
+// clientOptions.
+func newImpersonatedTokenSource(ctx context.Context, impersonate *smv1alpha1.GCPImpersonation, clientOptions []option.ClientOption) (oauth2.TokenSource, error) {
+	if iamCredentialsEndpoint != "" {
+		clientOptions = append(clientOptions, option.WithEndpoint(iamCredentialsEndpoint))
+	}
+	service, err := iamcredentials.NewService(ctx, clientOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating iam credentials client: %w", err)
+	}
+	delegates := make([]string, 0, len(impersonate.Delegates))
+	for _, delegate := range impersonate.Delegates {
+		delegates = append(delegates, serviceAccountName(delegate))
+	}
+	return oauth2.ReuseTokenSource(nil, &impersonatedTokenSource{
+		service:   service,
+		name:      serviceAccountName(impersonate.ServiceAccount),
+		delegates: delegates,
+	}), nil
+}
+
+// impersonatedTokenSource generates access tokens of a service account with
+// the IAM Service Account Credentials API.
+type impersonatedTokenSource struct {
+	service   *iamcredentials.Service
+	name      string
+	delegates []string
+}
+
+func (i *impersonatedTokenSource) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenTimeout)
+	defer cancel()
+	resp, err := i.service.Projects.ServiceAccounts.GenerateAccessToken(i.name, &iamcredentials.GenerateAccessTokenRequest{
+		Delegates: i.delegates,
+		Scope:     []string{cloudPlatformScope},
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error impersonating %s: %w", i.name, err)
+	}
+	expiry, err := time.Parse(time.RFC3339, resp.ExpireTime)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing expiry of impersonated token: %w", err)
+	}
+	return &oauth2.Token{
+		AccessToken: resp.AccessToken,
+		Expiry:      expiry,
+	}, nil
+}
+
+// serviceAccountName returns the resource name of a service account email.
+func serviceAccountName(email string) string {
+	return "projects/-/serviceAccounts/" + email
+}
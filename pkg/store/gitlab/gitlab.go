@@ -0,0 +1,335 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	ctxlog "github.com/itscontained/secret-manager/pkg/log"
+	"github.com/itscontained/secret-manager/pkg/store"
+	"github.com/itscontained/secret-manager/pkg/store/schema"
+	"github.com/itscontained/secret-manager/pkg/util/property"
+	"github.com/itscontained/secret-manager/pkg/util/storeref"
+
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ store.Client = &GitLab{}
+
+const (
+	defaultURL = "https://gitlab.com"
+	// allEnvironments is the environment scope matching every environment.
+	allEnvironments = "*"
+	pageSize        = 100
+	// requestTimeout bounds requests to the GitLab API.
+	requestTimeout = 30 * time.Second
+)
+
+// GitLab reads CI/CD variables of GitLab projects and groups.
+type GitLab struct {
+	kube  ctrlclient.Client
+	store smv1alpha1.GenericStore
+	log   logr.Logger
+
+	client *http.Client
+	url    string
+	token  string
+
+	// variablesCache holds the variables of the store once listed, so that
+	// the references of an ExternalSecret do not list them again.
+	variablesCache map[string][]byte
+	variablesLock  sync.Mutex
+}
+
+func init() {
+	schema.Register(&GitLab{}, &smv1alpha1.SecretStoreSpec{
+		GitLab: &smv1alpha1.GitLabStore{},
+	})
+}
+
+func (g *GitLab) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctrlclient.Client, namespace string) (store.Client, error) {
+	log := ctxlog.FromContext(ctx)
+	gitlabClient := &GitLab{
+		kube:  kube,
+		store: store,
+		log:   log,
+	}
+	if err := gitlabClient.setClient(ctx); err != nil {
+		log.Error(err, "could not create new gitlab client")
+		return nil, err
+	}
+	return gitlabClient, nil
+}
+
+func (g *GitLab) GetSecret(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, error) {
+	if ref.Version != nil {
+		return nil, fmt.Errorf("version is not supported by gitlab stores")
+	}
+	if ref.Find != nil {
+		return nil, fmt.Errorf("find is not supported by gitlab stores")
+	}
+	variables, err := g.variables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := variables[ref.Name]
+	if !ok {
+		return nil, fmt.Errorf("variable %q not found", ref.Name)
+	}
+	if ref.Property == nil {
+		return value, nil
+	}
+	return property.Get(value, *ref.Property)
+}
+
+// GetSecretMap returns the variables whose key starts with Name, keyed by
+// variable key.
+func (g *GitLab) GetSecretMap(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, error) {
+	if ref.Version != nil {
+		return nil, fmt.Errorf("version is not supported by gitlab stores")
+	}
+	if ref.Find != nil {
+		return nil, fmt.Errorf("find is not supported by gitlab stores")
+	}
+	variables, err := g.variables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secretMap := make(map[string][]byte, len(variables))
+	for key, value := range variables {
+		if strings.HasPrefix(key, ref.Name) {
+			secretMap[key] = value
+		}
+	}
+	return secretMap, nil
+}
+
+// variable is a CI/CD variable as returned by the GitLab API.
+type variable struct {
+	Key              string `json:"key"`
+	Value            string `json:"value"`
+	EnvironmentScope string `json:"environment_scope"`
+}
+
+// variables returns the values of the variables of the store by key. Project
+// variables take precedence over those of inherited groups, followed by the
+// configured groups. The variables are listed once per client.
+func (g *GitLab) variables(ctx context.Context) (map[string][]byte, error) {
+	g.variablesLock.Lock()
+	defer g.variablesLock.Unlock()
+	if g.variablesCache != nil {
+		return g.variablesCache, nil
+	}
+	spec := g.store.GetSpec().GitLab
+	var sources []string
+	if spec.ProjectID != nil {
+		sources = append(sources, "projects/"+url.PathEscape(*spec.ProjectID))
+		if !spec.DisableGroupInheritance {
+			groups, err := g.projectGroups(ctx, *spec.ProjectID)
+			if err != nil {
+				return nil, err
+			}
+			for _, group := range groups {
+				sources = append(sources, fmt.Sprintf("groups/%d", group))
+			}
+		}
+	}
+	for _, group := range spec.GroupIDs {
+		sources = append(sources, "groups/"+url.PathEscape(group))
+	}
+
+	environment := allEnvironments
+	if spec.Environment != nil {
+		environment = *spec.Environment
+	}
+	values := make(map[string][]byte)
+	for _, source := range sources {
+		list, err := g.listVariables(ctx, source)
+		if err != nil {
+			return nil, fmt.Errorf("error listing variables of %s: %w", source, err)
+		}
+		for key, v := range selectVariables(list, environment) {
+			if _, exists := values[key]; !exists {
+				values[key] = []byte(v.Value)
+			}
+		}
+	}
+	g.variablesCache = values
+	return values, nil
+}
+
+// selectVariables returns the variables whose environment scope matches the
+// environment by key, picking the most specific scope for each key.
+func selectVariables(list []variable, environment string) map[string]variable {
+	selected := make(map[string]variable, len(list))
+	for _, v := range list {
+		rank := scopeRank(v.EnvironmentScope, environment)
+		if rank == 0 {
+			continue
+		}
+		if current, exists := selected[v.Key]; exists {
+			currentRank := scopeRank(current.EnvironmentScope, environment)
+			if currentRank > rank || (currentRank == rank && len(current.EnvironmentScope) >= len(v.EnvironmentScope)) {
+				continue
+			}
+		}
+		selected[v.Key] = v
+	}
+	return selected
+}
+
+// scopeRank returns how specifically the environment scope matches the
+// environment: 3 for an exact match, 2 for a wildcard scope such as
+// "review/*", 1 for the "*" scope and 0 if it does not match.
+func scopeRank(scope, environment string) int {
+	switch {
+	case scope == allEnvironments:
+		return 1
+	case scope == environment:
+		return 3
+	case strings.Contains(scope, "*") && matchWildcard(scope, environment):
+		return 2
+	}
+	return 0
+}
+
+// matchWildcard reports whether s matches pattern, in which "*" matches any
+// sequence of characters.
+func matchWildcard(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return len(s) >= len(last) && strings.HasSuffix(s, last)
+}
+
+// projectGroups returns the ids of the groups the project belongs to, from
+// its namespace up to the top-level group.
+func (g *GitLab) projectGroups(ctx context.Context, projectID string) ([]int, error) {
+	var project struct {
+		Namespace struct {
+			ID   int    `json:"id"`
+			Kind string `json:"kind"`
+		} `json:"namespace"`
+	}
+	if err := g.get(ctx, "projects/"+url.PathEscape(projectID), &project); err != nil {
+		return nil, fmt.Errorf("error reading project %q: %w", projectID, err)
+	}
+	// projects of users do not belong to groups
+	if project.Namespace.Kind != "group" {
+		return nil, nil
+	}
+	var groups []int
+	for id := project.Namespace.ID; id != 0; {
+		groups = append(groups, id)
+		var group struct {
+			ParentID int `json:"parent_id"`
+		}
+		if err := g.get(ctx, fmt.Sprintf("groups/%d", id), &group); err != nil {
+			return nil, fmt.Errorf("error reading group %d: %w", id, err)
+		}
+		id = group.ParentID
+	}
+	return groups, nil
+}
+
+// listVariables returns the variables of a project or group, following
+// the pages of the listing.
+func (g *GitLab) listVariables(ctx context.Context, source string) ([]variable, error) {
+	var variables []variable
+	for page := "1"; page != ""; {
+		var pageVariables []variable
+		query := url.Values{"page": {page}, "per_page": {fmt.Sprint(pageSize)}}
+		header, err := g.request(ctx, source+"/variables?"+query.Encode(), &pageVariables)
+		if err != nil {
+			return nil, err
+		}
+		variables = append(variables, pageVariables...)
+		page = header.Get("X-Next-Page")
+	}
+	return variables, nil
+}
+
+func (g *GitLab) get(ctx context.Context, path string, into interface{}) error {
+	_, err := g.request(ctx, path, into)
+	return err
+}
+
+// request sends a GET request to the API and decodes the response into the
+// value pointed to by into.
+func (g *GitLab) request(ctx context.Context, path string, into interface{}) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.url+"/api/v4/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", g.token)
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Message interface{} `json:"message"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != nil {
+			return nil, fmt.Errorf("%s: %v", resp.Status, apiErr.Message)
+		}
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	return resp.Header, json.Unmarshal(body, into)
+}
+
+func (g *GitLab) setClient(ctx context.Context) error {
+	spec := g.store.GetSpec().GitLab
+	if spec.ProjectID == nil && len(spec.GroupIDs) == 0 {
+		return fmt.Errorf("missing projectID or groupIDs in store config")
+	}
+	g.url = defaultURL
+	if spec.URL != nil {
+		g.url = strings.TrimSuffix(*spec.URL, "/")
+	}
+	g.client = &http.Client{Timeout: requestTimeout}
+	token, err := storeref.SecretKey(ctx, g.kube, g.store, "accessToken", spec.AuthSecretRef.AccessToken)
+	if err != nil {
+		return err
+	}
+	g.token = token
+	return nil
+}
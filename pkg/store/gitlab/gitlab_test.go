@@ -0,0 +1,369 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	ctxlog "github.com/itscontained/secret-manager/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/kubernetes/scheme"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestServer returns a stand-in for the GitLab API serving the project
+// "my-group/sub/app", which belongs to the subgroup 12 of the group 10, and
+// the unrelated group "other". Listings are served two items per page.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	objects := map[string]interface{}{
+		"projects/my-group%2Fsub%2Fapp": map[string]interface{}{
+			"namespace": map[string]interface{}{"id": 12, "kind": "group"},
+		},
+		"projects/my-user%2Fapp": map[string]interface{}{
+			"namespace": map[string]interface{}{"id": 3, "kind": "user"},
+		},
+		"groups/12": map[string]interface{}{"id": 12, "parent_id": 10},
+		"groups/10": map[string]interface{}{"id": 10, "parent_id": nil},
+	}
+	variables := map[string][]variable{
+		"projects/my-group%2Fsub%2Fapp": {
+			{Key: "DB_PASSWORD", Value: "default-pw", EnvironmentScope: "*"},
+			{Key: "DB_PASSWORD", Value: "review-pw", EnvironmentScope: "review/*"},
+			{Key: "DB_PASSWORD", Value: "prod-pw", EnvironmentScope: "production"},
+			{Key: "DB_USER", Value: "app", EnvironmentScope: "*"},
+			{Key: "CONFIG", Value: `{"replicas":3}`, EnvironmentScope: "*"},
+		},
+		"projects/my-user%2Fapp": {
+			{Key: "DB_USER", Value: "user-app", EnvironmentScope: "*"},
+		},
+		"groups/12": {
+			{Key: "SHARED", Value: "sub-shared", EnvironmentScope: "*"},
+			{Key: "SUB_ONLY", Value: "sub", EnvironmentScope: "production"},
+		},
+		"groups/10": {
+			{Key: "SHARED", Value: "top-shared", EnvironmentScope: "*"},
+			{Key: "TOP_ONLY", Value: "top", EnvironmentScope: "*"},
+			{Key: "DB_PASSWORD", Value: "group-pw", EnvironmentScope: "*"},
+		},
+		"groups/other": {
+			{Key: "OTHER", Value: "other", EnvironmentScope: "*"},
+			{Key: "TOP_ONLY", Value: "other-top", EnvironmentScope: "*"},
+		},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("PRIVATE-TOKEN") != "glpat-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message":"401 Unauthorized"}`))
+			return
+		}
+		path := strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4/")
+		if source := strings.TrimSuffix(path, "/variables"); source != path {
+			list, ok := variables[source]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"message":"404 Not found"}`))
+				return
+			}
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			start, end := (page-1)*2, page*2
+			if end < len(list) {
+				w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+			} else {
+				end = len(list)
+			}
+			_ = json.NewEncoder(w).Encode(list[start:end])
+			return
+		}
+		object, ok := objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"404 Not found"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(object)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestGitLab(t *testing.T, srv *httptest.Server, spec smv1alpha1.GitLabStore) *GitLab {
+	t.Helper()
+	spec.URL = smmeta.String(srv.URL + "/")
+	spec.AuthSecretRef = smv1alpha1.GitLabAuth{
+		AccessToken: smmeta.SecretKeySelector{
+			LocalObjectReference: smmeta.LocalObjectReference{Name: "gitlab"},
+			Key:                  "token",
+		},
+	}
+	store := &smv1alpha1.SecretStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "gitlab", Namespace: "default"},
+		Spec:       smv1alpha1.SecretStoreSpec{GitLab: &spec},
+	}
+	kube := fakeclient.NewFakeClientWithScheme(scheme.Scheme, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "gitlab", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("glpat-token\n")},
+	})
+	ctx := ctxlog.IntoContext(context.Background(), ctrl.Log)
+	client, err := (&GitLab{}).New(ctx, store, kube, "default")
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	return client.(*GitLab)
+}
+
+func TestGetSecret(t *testing.T) {
+	srv := newTestServer(t)
+	inherited := smv1alpha1.GitLabStore{
+		ProjectID:   smmeta.String("my-group/sub/app"),
+		GroupIDs:    []string{"other"},
+		Environment: smmeta.String("production"),
+	}
+
+	tests := map[string]struct {
+		spec    smv1alpha1.GitLabStore
+		ref     smv1alpha1.RemoteReference
+		want    []byte
+		wantErr bool
+	}{
+		"exact environment scope": {
+			spec: inherited,
+			ref:  smv1alpha1.RemoteReference{Name: "DB_PASSWORD"},
+			want: []byte("prod-pw"),
+		},
+		"wildcard environment scope": {
+			spec: smv1alpha1.GitLabStore{
+				ProjectID:   smmeta.String("my-group/sub/app"),
+				Environment: smmeta.String("review/feature-1"),
+			},
+			ref:  smv1alpha1.RemoteReference{Name: "DB_PASSWORD"},
+			want: []byte("review-pw"),
+		},
+		"all environments scope without environment": {
+			spec: smv1alpha1.GitLabStore{ProjectID: smmeta.String("my-group/sub/app")},
+			ref:  smv1alpha1.RemoteReference{Name: "DB_PASSWORD"},
+			want: []byte("default-pw"),
+		},
+		"property": {
+			spec: inherited,
+			ref:  smv1alpha1.RemoteReference{Name: "CONFIG", Property: smmeta.String("replicas")},
+			want: []byte("3"),
+		},
+		"subgroup over parent group": {
+			spec: inherited,
+			ref:  smv1alpha1.RemoteReference{Name: "SHARED"},
+			want: []byte("sub-shared"),
+		},
+		"inherited group over configured group": {
+			spec: inherited,
+			ref:  smv1alpha1.RemoteReference{Name: "TOP_ONLY"},
+			want: []byte("top"),
+		},
+		"configured group": {
+			spec: inherited,
+			ref:  smv1alpha1.RemoteReference{Name: "OTHER"},
+			want: []byte("other"),
+		},
+		"group variable not inherited": {
+			spec: smv1alpha1.GitLabStore{
+				ProjectID:               smmeta.String("my-group/sub/app"),
+				DisableGroupInheritance: true,
+			},
+			ref:     smv1alpha1.RemoteReference{Name: "SHARED"},
+			wantErr: true,
+		},
+		"environment scope not matching": {
+			spec: smv1alpha1.GitLabStore{
+				ProjectID:   smmeta.String("my-group/sub/app"),
+				Environment: smmeta.String("staging"),
+			},
+			ref:     smv1alpha1.RemoteReference{Name: "SUB_ONLY"},
+			wantErr: true,
+		},
+		"user project": {
+			spec: smv1alpha1.GitLabStore{ProjectID: smmeta.String("my-user/app")},
+			ref:  smv1alpha1.RemoteReference{Name: "DB_USER"},
+			want: []byte("user-app"),
+		},
+		"project not found": {
+			spec:    smv1alpha1.GitLabStore{ProjectID: smmeta.String("missing")},
+			ref:     smv1alpha1.RemoteReference{Name: "DB_USER"},
+			wantErr: true,
+		},
+		"version": {
+			spec:    inherited,
+			ref:     smv1alpha1.RemoteReference{Name: "DB_USER", Version: smmeta.String("1")},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			g := newTestGitLab(t, srv, tc.spec)
+			got, err := g.GetSecret(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetSecretMap(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := map[string]struct {
+		spec smv1alpha1.GitLabStore
+		ref  smv1alpha1.RemoteReference
+		want map[string][]byte
+	}{
+		"project with inherited groups": {
+			spec: smv1alpha1.GitLabStore{
+				ProjectID:   smmeta.String("my-group/sub/app"),
+				Environment: smmeta.String("production"),
+			},
+			want: map[string][]byte{
+				"DB_PASSWORD": []byte("prod-pw"),
+				"DB_USER":     []byte("app"),
+				"CONFIG":      []byte(`{"replicas":3}`),
+				"SHARED":      []byte("sub-shared"),
+				"SUB_ONLY":    []byte("sub"),
+				"TOP_ONLY":    []byte("top"),
+			},
+		},
+		"project without inherited groups": {
+			spec: smv1alpha1.GitLabStore{
+				ProjectID:               smmeta.String("my-group/sub/app"),
+				DisableGroupInheritance: true,
+			},
+			want: map[string][]byte{
+				"DB_PASSWORD": []byte("default-pw"),
+				"DB_USER":     []byte("app"),
+				"CONFIG":      []byte(`{"replicas":3}`),
+			},
+		},
+		"key prefix": {
+			spec: smv1alpha1.GitLabStore{ProjectID: smmeta.String("my-group/sub/app")},
+			ref:  smv1alpha1.RemoteReference{Name: "DB_"},
+			want: map[string][]byte{
+				"DB_PASSWORD": []byte("default-pw"),
+				"DB_USER":     []byte("app"),
+			},
+		},
+		"groups only": {
+			spec: smv1alpha1.GitLabStore{GroupIDs: []string{"other", "10"}},
+			want: map[string][]byte{
+				"OTHER":       []byte("other"),
+				"TOP_ONLY":    []byte("other-top"),
+				"SHARED":      []byte("top-shared"),
+				"DB_PASSWORD": []byte("group-pw"),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			g := newTestGitLab(t, srv, tc.spec)
+			got, err := g.GetSecretMap(context.Background(), tc.ref)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestVariablesListedOnce(t *testing.T) {
+	api := newTestServer(t)
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		api.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	g := newTestGitLab(t, srv, smv1alpha1.GitLabStore{ProjectID: smmeta.String("my-group/sub/app")})
+
+	for _, key := range []string{"DB_USER", "SHARED", "TOP_ONLY"} {
+		if _, err := g.GetSecret(context.Background(), smv1alpha1.RemoteReference{Name: key}); err != nil {
+			t.Fatalf("unexpected error reading %s: %v", key, err)
+		}
+	}
+	listed := requests
+	if _, err := g.GetSecretMap(context.Background(), smv1alpha1.RemoteReference{Name: "DB_"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != listed {
+		t.Errorf("expected variables to be listed once, got %d requests after %d", requests, listed)
+	}
+	// the project and its two groups, and three, one and two pages of their
+	// variables
+	if want := 3 + 3 + 1 + 2; listed != want {
+		t.Errorf("expected %d requests listing variables, got %d", want, listed)
+	}
+}
+
+func TestScopeRank(t *testing.T) {
+	tests := map[string]struct {
+		scope       string
+		environment string
+		want        int
+	}{
+		"exact":                    {scope: "production", environment: "production", want: 3},
+		"wildcard":                 {scope: "review/*", environment: "review/feature/1", want: 2},
+		"wildcard in the middle":   {scope: "eu-*-prod", environment: "eu-west-prod", want: 2},
+		"wildcard not matching":    {scope: "review/*", environment: "production", want: 0},
+		"all environments":         {scope: "*", environment: "production", want: 1},
+		"different environment":    {scope: "staging", environment: "production", want: 0},
+		"regexp characters quoted": {scope: "prod.*", environment: "prod-eu", want: 0},
+		"regexp characters":        {scope: "(prod)[*", environment: "(prod)[eu", want: 2},
+		"multiple wildcards":       {scope: "*-eu-*", environment: "prod-eu-west", want: 2},
+		"overlapping wildcards":    {scope: "a*ab", environment: "ab", want: 0},
+		"empty wildcard match":     {scope: "review/*", environment: "review/", want: 2},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := scopeRank(tc.scope, tc.environment); got != tc.want {
+				t.Errorf("expected %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
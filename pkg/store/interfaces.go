@@ -28,3 +28,19 @@ type Client interface {
 	GetSecret(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, error)
 	GetSecretMap(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, error)
 }
+
+// SecretMetadata describes the remote secret read by a store backend.
+type SecretMetadata struct {
+	// Version of the remote secret which was read.
+	Version string
+	// CustomMetadata holds arbitrary key-value pairs stored alongside the
+	// remote secret.
+	CustomMetadata map[string]string
+}
+
+// MetadataClient is implemented by store backends which are able to report
+// the metadata of the remote secret along with the secret data.
+type MetadataClient interface {
+	GetSecretWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, *SecretMetadata, error)
+	GetSecretMapWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, *SecretMetadata, error)
+}
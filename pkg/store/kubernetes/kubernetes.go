@@ -0,0 +1,345 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	ctxlog "github.com/itscontained/secret-manager/pkg/log"
+	"github.com/itscontained/secret-manager/pkg/store"
+	"github.com/itscontained/secret-manager/pkg/store/schema"
+	"github.com/itscontained/secret-manager/pkg/util/storeref"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ store.Client = &Kubernetes{}
+
+const (
+	// requestTimeout bounds requests to remote clusters.
+	requestTimeout = 30 * time.Second
+
+	// clientCacheTTL is how long the client of a remote cluster is cached
+	// without being used, so that clients of deleted stores are evicted.
+	clientCacheTTL = time.Hour
+)
+
+// clientCacheEntry is the client of a remote cluster for a given config.
+type clientCacheEntry struct {
+	fingerprint [sha256.Size]byte
+	lastUsed    time.Time
+	client      ctrlclient.Client
+}
+
+var (
+	clientCache     = make(map[string]*clientCacheEntry)
+	clientCacheLock sync.Mutex
+)
+
+// Kubernetes reads Secrets of the cluster secret-manager runs in, or of a
+// remote cluster.
+type Kubernetes struct {
+	kube  ctrlclient.Client
+	store smv1alpha1.GenericStore
+	log   logr.Logger
+
+	// client reads the Secrets in remoteNamespace.
+	client          ctrlclient.Client
+	remoteNamespace string
+}
+
+func init() {
+	schema.Register(&Kubernetes{}, &smv1alpha1.SecretStoreSpec{
+		Kubernetes: &smv1alpha1.KubernetesStore{},
+	})
+}
+
+func (k *Kubernetes) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctrlclient.Client, namespace string) (store.Client, error) {
+	log := ctxlog.FromContext(ctx)
+	kubeClient := &Kubernetes{
+		kube:  kube,
+		store: store,
+		log:   log,
+	}
+	if err := kubeClient.setClient(ctx, namespace); err != nil {
+		log.Error(err, "could not create new kubernetes client")
+		return nil, err
+	}
+	return kubeClient, nil
+}
+
+func (k *Kubernetes) GetSecret(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, error) {
+	if ref.Property == nil {
+		return nil, fmt.Errorf("property required to select a key of secret %q", ref.Name)
+	}
+	secret, err := k.readSecret(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := secret.Data[*ref.Property]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in secret '%s/%s'", *ref.Property, k.remoteNamespace, ref.Name)
+	}
+	return value, nil
+}
+
+func (k *Kubernetes) GetSecretMap(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, error) {
+	secret, err := k.readSecret(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	secretMap := make(map[string][]byte, len(secret.Data))
+	for key, value := range secret.Data {
+		secretMap[key] = value
+	}
+	return secretMap, nil
+}
+
+func (k *Kubernetes) readSecret(ctx context.Context, ref smv1alpha1.RemoteReference) (*corev1.Secret, error) {
+	if ref.Version != nil {
+		return nil, fmt.Errorf("version is not supported by kubernetes stores")
+	}
+	if ref.Find != nil {
+		return nil, fmt.Errorf("find is not supported by kubernetes stores")
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{
+		Namespace: k.remoteNamespace,
+		Name:      ref.Name,
+	}
+	if err := k.client.Get(ctx, key, secret); err != nil {
+		return nil, fmt.Errorf("error getting secret '%s/%s': %w", k.remoteNamespace, ref.Name, err)
+	}
+	return secret, nil
+}
+
+// setClient sets the client and namespace of the remote Secrets, read for an
+// ExternalSecret in namespace.
+func (k *Kubernetes) setClient(ctx context.Context, namespace string) error {
+	spec := k.store.GetSpec().Kubernetes
+	clusterScoped := k.store.GetTypeMeta().Kind == smv1alpha1.ClusterSecretStoreKind
+
+	k.remoteNamespace = k.store.GetNamespace()
+	if clusterScoped {
+		k.remoteNamespace = namespace
+	}
+	if spec.RemoteNamespace != nil {
+		k.remoteNamespace = *spec.RemoteNamespace
+	}
+
+	if spec.AuthSecretRef == nil {
+		if spec.Server != nil {
+			return fmt.Errorf("missing authSecretRef for server in store config")
+		}
+		// the credentials of secret-manager must not widen the access of a
+		// SecretStore beyond its namespace
+		if !clusterScoped && k.remoteNamespace != k.store.GetNamespace() {
+			return fmt.Errorf("remote namespace %q must be the namespace of the store without authSecretRef", k.remoteNamespace)
+		}
+		k.log.V(1).Info("no authentication defined. using in-cluster client")
+		k.client = k.kube
+		return nil
+	}
+
+	cfg, err := k.restConfig(ctx, spec)
+	if err != nil {
+		return err
+	}
+	k.client, err = k.remoteClient(cfg)
+	return err
+}
+
+// remoteClient returns a client of the remote cluster configured by cfg. The
+// client of a store is reused as long as its config is unchanged, and
+// evicted once it is not used for clientCacheTTL.
+func (k *Kubernetes) remoteClient(cfg *rest.Config) (ctrlclient.Client, error) {
+	cacheKey := fmt.Sprintf("%s/%s/%s", k.store.GetTypeMeta().Kind, k.store.GetNamespace(), k.store.GetName())
+	fingerprint, err := configFingerprint(cfg)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	clientCacheLock.Lock()
+	defer clientCacheLock.Unlock()
+	for key, entry := range clientCache {
+		if now.Sub(entry.lastUsed) > clientCacheTTL {
+			delete(clientCache, key)
+		}
+	}
+	if entry, ok := clientCache[cacheKey]; ok && entry.fingerprint == fingerprint {
+		entry.lastUsed = now
+		return entry.client, nil
+	}
+
+	cfg.Timeout = requestTimeout
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Secret"), meta.RESTScopeNamespace)
+	client, err := ctrlclient.New(cfg, ctrlclient.Options{
+		Scheme: scheme.Scheme,
+		Mapper: mapper,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+	clientCache[cacheKey] = &clientCacheEntry{
+		fingerprint: fingerprint,
+		lastUsed:    now,
+		client:      client,
+	}
+	return client, nil
+}
+
+// configFingerprint returns a hash of the server and credentials of cfg.
+func configFingerprint(cfg *rest.Config) ([sha256.Size]byte, error) {
+	data, err := json.Marshal([]interface{}{
+		cfg.Host,
+		cfg.BearerToken,
+		cfg.TLSClientConfig.ServerName,
+		cfg.TLSClientConfig.CAData,
+		cfg.TLSClientConfig.CertData,
+		cfg.TLSClientConfig.KeyData,
+	})
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// restConfig returns the client config of the remote cluster.
+func (k *Kubernetes) restConfig(ctx context.Context, spec *smv1alpha1.KubernetesStore) (*rest.Config, error) {
+	auth := spec.AuthSecretRef
+	// TODO: Validating Webhook Candidate
+	if auth.Kubeconfig != nil && auth.Token != nil {
+		return nil, fmt.Errorf("multiple authentication methods configured")
+	}
+	if auth.Kubeconfig != nil {
+		if spec.Server != nil {
+			return nil, fmt.Errorf("server must not be set with kubeconfig authentication")
+		}
+		k.log.V(1).Info("kubeconfig authentication defined")
+		kubeconfig, err := storeref.SecretKey(ctx, k.kube, k.store, "kubeconfig", *auth.Kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := restConfigFromKubeconfig([]byte(kubeconfig))
+		if err != nil {
+			return nil, fmt.Errorf("error loading kubeconfig: %w", err)
+		}
+		return cfg, nil
+	}
+	if auth.Token == nil {
+		return nil, fmt.Errorf("missing kubeconfig or token in store config")
+	}
+	if spec.Server == nil {
+		return nil, fmt.Errorf("missing server for token authentication in store config")
+	}
+	k.log.V(1).Info("token authentication defined")
+	token, err := storeref.SecretKey(ctx, k.kube, k.store, "token", *auth.Token)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &rest.Config{
+		Host:        spec.Server.URL,
+		BearerToken: token,
+	}
+	cfg.CAData = spec.Server.CABundle
+	if spec.Server.CARef != nil {
+		ca, err := storeref.SecretKey(ctx, k.kube, k.store, "caRef", *spec.Server.CARef)
+		if err != nil {
+			return nil, err
+		}
+		cfg.CAData = []byte(ca)
+	}
+	return cfg, nil
+}
+
+// restConfigFromKubeconfig returns the client config of the current context
+// of a kubeconfig. Only the server, inline CA data and inline credentials are
+// used: kubeconfigs are supplied by store owners, who must neither read files
+// of nor run commands in the controller, nor borrow its credentials.
+func restConfigFromKubeconfig(data []byte) (*rest.Config, error) {
+	kubeconfig, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, err
+	}
+	kubeContext, ok := kubeconfig.Contexts[kubeconfig.CurrentContext]
+	if !ok {
+		return nil, fmt.Errorf("current context %q not found", kubeconfig.CurrentContext)
+	}
+	cluster, ok := kubeconfig.Clusters[kubeContext.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("cluster %q not found", kubeContext.Cluster)
+	}
+	user, ok := kubeconfig.AuthInfos[kubeContext.AuthInfo]
+	if !ok {
+		return nil, fmt.Errorf("user %q not found", kubeContext.AuthInfo)
+	}
+
+	var unsupported []string
+	for field, set := range map[string]bool{
+		"certificate-authority":    cluster.CertificateAuthority != "",
+		"insecure-skip-tls-verify": cluster.InsecureSkipTLSVerify,
+		"proxy-url":                cluster.ProxyURL != "",
+		"client-certificate":       user.ClientCertificate != "",
+		"client-key":               user.ClientKey != "",
+		"tokenFile":                user.TokenFile != "",
+		"username":                 user.Username != "",
+		"password":                 user.Password != "",
+		"as":                       user.Impersonate != "" || len(user.ImpersonateGroups) != 0 || len(user.ImpersonateUserExtra) != 0,
+		"auth-provider":            user.AuthProvider != nil,
+		"exec":                     user.Exec != nil,
+	} {
+		if set {
+			unsupported = append(unsupported, field)
+		}
+	}
+	if len(unsupported) != 0 {
+		sort.Strings(unsupported)
+		return nil, fmt.Errorf("unsupported kubeconfig fields %s", strings.Join(unsupported, ", "))
+	}
+	if cluster.Server == "" {
+		return nil, fmt.Errorf("missing server of cluster %q", kubeContext.Cluster)
+	}
+
+	return &rest.Config{
+		Host:        cluster.Server,
+		BearerToken: user.Token,
+		TLSClientConfig: rest.TLSClientConfig{
+			ServerName: cluster.TLSServerName,
+			CAData:     cluster.CertificateAuthorityData,
+			CertData:   user.ClientCertificateData,
+			KeyData:    user.ClientKeyData,
+		},
+	}, nil
+}
@@ -0,0 +1,477 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	ctxlog "github.com/itscontained/secret-manager/pkg/log"
+	"github.com/itscontained/secret-manager/pkg/store"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newSecret(namespace, name string, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Data:       make(map[string][]byte, len(data)),
+	}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+func newStore(t *testing.T, store smv1alpha1.GenericStore, namespace string, objects ...runtime.Object) (store.Client, error) {
+	t.Helper()
+	ctx := ctxlog.IntoContext(context.Background(), ctrl.Log)
+	kube := fakeclient.NewFakeClientWithScheme(scheme.Scheme, objects...)
+	return (&Kubernetes{}).New(ctx, store, kube, namespace)
+}
+
+func TestInCluster(t *testing.T) {
+	objects := []runtime.Object{
+		newSecret("app", "db", map[string]string{"username": "bob", "password": "abc123"}),
+		newSecret("central", "db", map[string]string{"username": "alice", "password": "def456"}),
+	}
+	secretStore := func(spec smv1alpha1.KubernetesStore) smv1alpha1.GenericStore {
+		return &smv1alpha1.SecretStore{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: "app"},
+			Spec:       smv1alpha1.SecretStoreSpec{Kubernetes: &spec},
+		}
+	}
+	clusterStore := func(spec smv1alpha1.KubernetesStore) smv1alpha1.GenericStore {
+		return &smv1alpha1.ClusterSecretStore{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubernetes"},
+			Spec:       smv1alpha1.SecretStoreSpec{Kubernetes: &spec},
+		}
+	}
+
+	tests := map[string]struct {
+		store   smv1alpha1.GenericStore
+		want    map[string][]byte
+		wantErr bool
+	}{
+		"store namespace": {
+			store: secretStore(smv1alpha1.KubernetesStore{}),
+			want: map[string][]byte{
+				"username": []byte("bob"),
+				"password": []byte("abc123"),
+			},
+		},
+		"store namespace as remote namespace": {
+			store: secretStore(smv1alpha1.KubernetesStore{RemoteNamespace: smmeta.String("app")}),
+			want: map[string][]byte{
+				"username": []byte("bob"),
+				"password": []byte("abc123"),
+			},
+		},
+		"other namespace": {
+			store:   secretStore(smv1alpha1.KubernetesStore{RemoteNamespace: smmeta.String("central")}),
+			wantErr: true,
+		},
+		"server without auth": {
+			store: secretStore(smv1alpha1.KubernetesStore{
+				Server: &smv1alpha1.KubernetesServer{URL: "https://kubernetes.example.com"},
+			}),
+			wantErr: true,
+		},
+		"cluster store in namespace of external secret": {
+			store: clusterStore(smv1alpha1.KubernetesStore{}),
+			want: map[string][]byte{
+				"username": []byte("bob"),
+				"password": []byte("abc123"),
+			},
+		},
+		"cluster store in remote namespace": {
+			store: clusterStore(smv1alpha1.KubernetesStore{RemoteNamespace: smmeta.String("central")}),
+			want: map[string][]byte{
+				"username": []byte("alice"),
+				"password": []byte("def456"),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := newStore(t, tc.store, "app", objects...)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := client.GetSecretMap(context.Background(), smv1alpha1.RemoteReference{Name: "db"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetSecret(t *testing.T) {
+	client, err := newStore(t, &smv1alpha1.SecretStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: "app"},
+		Spec:       smv1alpha1.SecretStoreSpec{Kubernetes: &smv1alpha1.KubernetesStore{}},
+	}, "app", newSecret("app", "db", map[string]string{"password": "abc123"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := map[string]struct {
+		ref     smv1alpha1.RemoteReference
+		want    []byte
+		wantErr bool
+	}{
+		"key": {
+			ref:  smv1alpha1.RemoteReference{Name: "db", Property: smmeta.String("password")},
+			want: []byte("abc123"),
+		},
+		"missing key": {
+			ref:     smv1alpha1.RemoteReference{Name: "db", Property: smmeta.String("username")},
+			wantErr: true,
+		},
+		"no property": {
+			ref:     smv1alpha1.RemoteReference{Name: "db"},
+			wantErr: true,
+		},
+		"version": {
+			ref:     smv1alpha1.RemoteReference{Name: "db", Property: smmeta.String("password"), Version: smmeta.String("1")},
+			wantErr: true,
+		},
+		"not found": {
+			ref:     smv1alpha1.RemoteReference{Name: "missing", Property: smmeta.String("password")},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := client.GetSecret(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRemoteCluster(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer remote-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/namespaces/central/secrets/db" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		secret := newSecret("central", "db", map[string]string{"password": "remote"})
+		secret.APIVersion, secret.Kind = "v1", "Secret"
+		_ = json.NewEncoder(w).Encode(secret)
+	}))
+	defer srv.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: remote
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+users:
+- name: remote
+  user:
+    token: remote-token
+contexts:
+- name: remote
+  context:
+    cluster: remote
+    user: remote
+current-context: remote
+`, srv.URL, base64.StdEncoding.EncodeToString(ca))
+
+	objects := []runtime.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: "app"},
+			Data: map[string][]byte{
+				"token":      []byte("remote-token\n"),
+				"ca.crt":     ca,
+				"kubeconfig": []byte(kubeconfig),
+			},
+		},
+	}
+	selector := func(key string) *smmeta.SecretKeySelector {
+		return &smmeta.SecretKeySelector{
+			LocalObjectReference: smmeta.LocalObjectReference{Name: "remote"},
+			Key:                  key,
+		}
+	}
+
+	tests := map[string]struct {
+		spec    smv1alpha1.KubernetesStore
+		wantErr bool
+	}{
+		"token with ca bundle": {
+			spec: smv1alpha1.KubernetesStore{
+				Server:        &smv1alpha1.KubernetesServer{URL: srv.URL, CABundle: ca},
+				AuthSecretRef: &smv1alpha1.KubernetesAuth{Token: selector("token")},
+			},
+		},
+		"token with ca ref": {
+			spec: smv1alpha1.KubernetesStore{
+				Server:        &smv1alpha1.KubernetesServer{URL: srv.URL, CARef: selector("ca.crt")},
+				AuthSecretRef: &smv1alpha1.KubernetesAuth{Token: selector("token")},
+			},
+		},
+		"kubeconfig": {
+			spec: smv1alpha1.KubernetesStore{
+				AuthSecretRef: &smv1alpha1.KubernetesAuth{Kubeconfig: selector("kubeconfig")},
+			},
+		},
+		"token without ca": {
+			spec: smv1alpha1.KubernetesStore{
+				Server:        &smv1alpha1.KubernetesServer{URL: srv.URL},
+				AuthSecretRef: &smv1alpha1.KubernetesAuth{Token: selector("token")},
+			},
+			wantErr: true,
+		},
+		"token without server": {
+			spec: smv1alpha1.KubernetesStore{
+				AuthSecretRef: &smv1alpha1.KubernetesAuth{Token: selector("token")},
+			},
+			wantErr: true,
+		},
+		"kubeconfig with server": {
+			spec: smv1alpha1.KubernetesStore{
+				Server:        &smv1alpha1.KubernetesServer{URL: srv.URL},
+				AuthSecretRef: &smv1alpha1.KubernetesAuth{Kubeconfig: selector("kubeconfig")},
+			},
+			wantErr: true,
+		},
+		"multiple methods": {
+			spec: smv1alpha1.KubernetesStore{
+				AuthSecretRef: &smv1alpha1.KubernetesAuth{
+					Kubeconfig: selector("kubeconfig"),
+					Token:      selector("token"),
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			spec := tc.spec
+			spec.RemoteNamespace = smmeta.String("central")
+			client, err := newStore(t, &smv1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: "app"},
+				Spec:       smv1alpha1.SecretStoreSpec{Kubernetes: &spec},
+			}, "app", objects...)
+			if err == nil {
+				var got []byte
+				got, err = client.GetSecret(context.Background(), smv1alpha1.RemoteReference{Name: "db", Property: smmeta.String("password")})
+				if err == nil && string(got) != "remote" {
+					t.Errorf("expected %q, got %q", "remote", got)
+				}
+			}
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoteClientCache(t *testing.T) {
+	k := &Kubernetes{
+		store: &smv1alpha1.SecretStore{
+			ObjectMeta: metav1.ObjectMeta{Name: "cached", Namespace: "app"},
+		},
+	}
+	cfg := func(token string) *rest.Config {
+		return &rest.Config{Host: "https://remote.example.com", BearerToken: token}
+	}
+
+	first, err := k.remoteClient(cfg("token-a"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := k.remoteClient(cfg("token-a"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first != second {
+		t.Error("expected client to be reused for an unchanged config")
+	}
+
+	rotated, err := k.remoteClient(cfg("token-b"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rotated == second {
+		t.Error("expected new client after the token changed")
+	}
+
+	clientCacheLock.Lock()
+	clientCache["SecretStore/app/cached"].lastUsed = time.Now().Add(-clientCacheTTL - time.Minute)
+	clientCacheLock.Unlock()
+	evicted, err := k.remoteClient(cfg("token-b"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if evicted == rotated {
+		t.Error("expected new client after the unused client was evicted")
+	}
+}
+
+func TestRestConfigFromKubeconfig(t *testing.T) {
+	kubeconfig := func(cluster, user string) []byte {
+		return []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: remote
+  cluster:
+    server: https://remote.example.com
+%s
+users:
+- name: remote
+  user:
+%s
+contexts:
+- name: remote
+  context:
+    cluster: remote
+    user: remote
+current-context: remote
+`, cluster, user))
+	}
+
+	tests := map[string]struct {
+		kubeconfig []byte
+		want       *rest.Config
+		wantErr    bool
+	}{
+		"token": {
+			kubeconfig: kubeconfig("    certificate-authority-data: Y2E=", "    token: remote-token"),
+			want: &rest.Config{
+				Host:            "https://remote.example.com",
+				BearerToken:     "remote-token",
+				TLSClientConfig: rest.TLSClientConfig{CAData: []byte("ca")},
+			},
+		},
+		"client certificate": {
+			kubeconfig: kubeconfig("    tls-server-name: api.remote", "    client-certificate-data: Y2VydA==\n    client-key-data: a2V5"),
+			want: &rest.Config{
+				Host: "https://remote.example.com",
+				TLSClientConfig: rest.TLSClientConfig{
+					ServerName: "api.remote",
+					CertData:   []byte("cert"),
+					KeyData:    []byte("key"),
+				},
+			},
+		},
+		"token file": {
+			kubeconfig: kubeconfig("", "    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token"),
+			wantErr:    true,
+		},
+		"client certificate file": {
+			kubeconfig: kubeconfig("", "    client-certificate: /etc/tls/tls.crt\n    client-key: /etc/tls/tls.key"),
+			wantErr:    true,
+		},
+		"certificate authority file": {
+			kubeconfig: kubeconfig("    certificate-authority: /var/run/secrets/kubernetes.io/serviceaccount/ca.crt", "    token: remote-token"),
+			wantErr:    true,
+		},
+		"exec plugin": {
+			kubeconfig: kubeconfig("", "    exec:\n      apiVersion: client.authentication.k8s.io/v1beta1\n      command: sh"),
+			wantErr:    true,
+		},
+		"auth provider": {
+			kubeconfig: kubeconfig("", "    auth-provider:\n      name: gcp"),
+			wantErr:    true,
+		},
+		"impersonation": {
+			kubeconfig: kubeconfig("", "    token: remote-token\n    as: system:admin"),
+			wantErr:    true,
+		},
+		"insecure": {
+			kubeconfig: kubeconfig("    insecure-skip-tls-verify: true", "    token: remote-token"),
+			wantErr:    true,
+		},
+		"missing current context": {
+			kubeconfig: []byte("apiVersion: v1\nkind: Config\n"),
+			wantErr:    true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := restConfigFromKubeconfig(tc.kubeconfig)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package onepassword
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	ctxlog "github.com/itscontained/secret-manager/pkg/log"
+	"github.com/itscontained/secret-manager/pkg/store"
+	"github.com/itscontained/secret-manager/pkg/store/schema"
+	"github.com/itscontained/secret-manager/pkg/util/storeref"
+
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ store.Client = &OnePassword{}
+
+const (
+	// defaultProperty is the label of the field returned if no property is set.
+	defaultProperty = "password"
+	// requestTimeout bounds requests to the Connect server.
+	requestTimeout = 30 * time.Second
+)
+
+// OnePassword reads items from a 1Password Connect server.
+type OnePassword struct {
+	kube  ctrlclient.Client
+	store smv1alpha1.GenericStore
+	log   logr.Logger
+
+	client *http.Client
+	host   string
+	token  string
+	vaults []string
+	// vaultIDs caches the ids of the vaults by name.
+	vaultIDs map[string]string
+}
+
+func init() {
+	schema.Register(&OnePassword{}, &smv1alpha1.SecretStoreSpec{
+		OnePassword: &smv1alpha1.OnePasswordStore{},
+	})
+}
+
+func (o *OnePassword) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctrlclient.Client, namespace string) (store.Client, error) {
+	log := ctxlog.FromContext(ctx)
+	spec := store.GetSpec().OnePassword
+	opClient := &OnePassword{
+		kube:     kube,
+		store:    store,
+		log:      log,
+		client:   &http.Client{Timeout: requestTimeout},
+		host:     strings.TrimSuffix(spec.ConnectHost, "/"),
+		vaults:   spec.Vaults,
+		vaultIDs: make(map[string]string),
+	}
+	if len(spec.Vaults) == 0 {
+		return nil, fmt.Errorf("missing vaults in store config")
+	}
+	token, err := storeref.SecretKey(ctx, kube, store, "connectToken", spec.AuthSecretRef.ConnectToken)
+	if err != nil {
+		log.Error(err, "could not create new 1password client")
+		return nil, err
+	}
+	opClient.token = token
+	return opClient, nil
+}
+
+func (o *OnePassword) GetSecret(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, error) {
+	it, err := o.findItem(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	label := defaultProperty
+	if ref.Property != nil {
+		label = *ref.Property
+	}
+
+	var value *string
+	for i := range it.Fields {
+		if it.Fields[i].Label != label {
+			continue
+		}
+		if value != nil {
+			return nil, fmt.Errorf("multiple fields labeled %q in item %q", label, it.Title)
+		}
+		value = &it.Fields[i].Value
+	}
+	if value != nil {
+		return []byte(*value), nil
+	}
+	for _, f := range it.Files {
+		if f.Name == label {
+			return o.readFile(ctx, it, f)
+		}
+	}
+	return nil, fmt.Errorf("no field or file %q in item %q", label, it.Title)
+}
+
+// GetSecretMap returns the values of all fields of the item keyed by label,
+// and the content of its file attachments keyed by file name.
+func (o *OnePassword) GetSecretMap(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, error) {
+	it, err := o.findItem(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	secretMap := make(map[string][]byte, len(it.Fields)+len(it.Files))
+	for _, f := range it.Fields {
+		if f.Label == "" {
+			continue
+		}
+		if _, exists := secretMap[f.Label]; exists {
+			return nil, fmt.Errorf("multiple fields labeled %q in item %q", f.Label, it.Title)
+		}
+		secretMap[f.Label] = []byte(f.Value)
+	}
+	for _, f := range it.Files {
+		if _, exists := secretMap[f.Name]; exists {
+			return nil, fmt.Errorf("file %q conflicts with a field of item %q", f.Name, it.Title)
+		}
+		content, err := o.readFile(ctx, it, f)
+		if err != nil {
+			return nil, err
+		}
+		secretMap[f.Name] = content
+	}
+	return secretMap, nil
+}
+
+// vault is a vault as returned by the Connect API.
+type vault struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// item is an item as returned by the Connect API.
+type item struct {
+	ID     string  `json:"id"`
+	Title  string  `json:"title"`
+	Vault  vault   `json:"vault"`
+	Fields []field `json:"fields"`
+	Files  []file  `json:"files"`
+}
+
+type field struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+type file struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// findItem returns the item titled with the name of the reference in the
+// vault with the highest priority holding such an item.
+func (o *OnePassword) findItem(ctx context.Context, ref smv1alpha1.RemoteReference) (*item, error) {
+	if ref.Version != nil {
+		return nil, fmt.Errorf("version is not supported by 1password stores")
+	}
+	if ref.Find != nil {
+		return nil, fmt.Errorf("find is not supported by 1password stores")
+	}
+	for _, vaultName := range o.vaults {
+		vaultID, err := o.vaultID(ctx, vaultName)
+		if err != nil {
+			return nil, err
+		}
+		var items []item
+		if err := o.get(ctx, "/v1/vaults/"+vaultID+"/items", filter("title", ref.Name), &items); err != nil {
+			return nil, fmt.Errorf("error listing items of vault %q: %w", vaultName, err)
+		}
+		var ids []string
+		for _, it := range items {
+			if it.Title == ref.Name {
+				ids = append(ids, it.ID)
+			}
+		}
+		switch len(ids) {
+		case 0:
+			continue
+		case 1:
+		default:
+			return nil, fmt.Errorf("multiple items titled %q in vault %q", ref.Name, vaultName)
+		}
+		found := &item{}
+		if err := o.get(ctx, "/v1/vaults/"+vaultID+"/items/"+ids[0], nil, found); err != nil {
+			return nil, fmt.Errorf("error getting item %q of vault %q: %w", ref.Name, vaultName, err)
+		}
+		found.Vault.ID = vaultID
+		return found, nil
+	}
+	return nil, fmt.Errorf("item %q not found in vaults %s", ref.Name, strings.Join(o.vaults, ", "))
+}
+
+// vaultID returns the id of the vault with the given name.
+func (o *OnePassword) vaultID(ctx context.Context, name string) (string, error) {
+	if id, ok := o.vaultIDs[name]; ok {
+		return id, nil
+	}
+	var vaults []vault
+	if err := o.get(ctx, "/v1/vaults", filter("name", name), &vaults); err != nil {
+		return "", fmt.Errorf("error listing vaults: %w", err)
+	}
+	for _, v := range vaults {
+		if v.Name == name {
+			o.vaultIDs[name] = v.ID
+			return v.ID, nil
+		}
+	}
+	return "", fmt.Errorf("vault %q not found", name)
+}
+
+func (o *OnePassword) readFile(ctx context.Context, it *item, f file) ([]byte, error) {
+	content, err := o.do(ctx, "/v1/vaults/"+it.Vault.ID+"/items/"+it.ID+"/files/"+f.ID+"/content", nil)
+	if err != nil {
+		return nil, fmt.Errorf("error getting file %q of item %q: %w", f.Name, it.Title, err)
+	}
+	return content, nil
+}
+
+// get decodes the JSON response of the Connect API at path into out.
+func (o *OnePassword) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	body, err := o.do(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, out)
+}
+
+// do returns the response body of the Connect API at path.
+func (o *OnePassword) do(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	u := o.host + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+o.token)
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := struct {
+			Message string `json:"message"`
+		}{}
+		if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Message != "" {
+			return nil, fmt.Errorf("%s: %s", resp.Status, apiErr.Message)
+		}
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	return body, nil
+}
+
+// filter returns the query filtering resources whose attribute equals value.
+func filter(attribute, value string) url.Values {
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return url.Values{"filter": {fmt.Sprintf(`%s eq "%s"`, attribute, value)}}
+}
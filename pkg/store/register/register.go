@@ -18,6 +18,13 @@ package register
 // packages imported here are registered to the controller schema
 import (
 	_ "github.com/itscontained/secret-manager/pkg/store/aws"
+	_ "github.com/itscontained/secret-manager/pkg/store/azurekv"
+	_ "github.com/itscontained/secret-manager/pkg/store/conjur"
+	_ "github.com/itscontained/secret-manager/pkg/store/consul"
 	_ "github.com/itscontained/secret-manager/pkg/store/gcp"
+	_ "github.com/itscontained/secret-manager/pkg/store/gitlab"
+	_ "github.com/itscontained/secret-manager/pkg/store/kubernetes"
+	_ "github.com/itscontained/secret-manager/pkg/store/onepassword"
+	_ "github.com/itscontained/secret-manager/pkg/store/sops"
 	_ "github.com/itscontained/secret-manager/pkg/store/vault"
 )
@@ -0,0 +1,284 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sops
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	agearmor "filippo.io/age/armor"
+
+	"golang.org/x/crypto/openpgp"
+	pgparmor "golang.org/x/crypto/openpgp/armor"
+
+	"gopkg.in/yaml.v3"
+)
+
+// metadataKey is the key of the SOPS metadata in encrypted documents.
+const metadataKey = "sops"
+
+// encryptedValueRegexp matches values encrypted by SOPS.
+var encryptedValueRegexp = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.+),iv:(.+),tag:(.+),type:(.+)\]`)
+
+// metadata is the SOPS metadata of an encrypted document. Only the age and
+// PGP master keys of a single key group are supported.
+type metadata struct {
+	KeyGroups    []keyGroup `yaml:"key_groups"`
+	Age          []ageKey   `yaml:"age"`
+	PGP          []pgpKey   `yaml:"pgp"`
+	LastModified string     `yaml:"lastmodified"`
+	MAC          string     `yaml:"mac"`
+	// MACOnlyEncrypted is set by sops 3.8 and later when the message
+	// authentication code only covers the encrypted values.
+	MACOnlyEncrypted bool `yaml:"mac_only_encrypted"`
+}
+
+// keyGroup is a set of master keys the data key is encrypted for.
+type keyGroup struct {
+	Age []ageKey `yaml:"age"`
+	PGP []pgpKey `yaml:"pgp"`
+}
+
+type ageKey struct {
+	Recipient    string `yaml:"recipient"`
+	EncryptedKey string `yaml:"enc"`
+}
+
+type pgpKey struct {
+	Fingerprint  string `yaml:"fp"`
+	EncryptedKey string `yaml:"enc"`
+}
+
+// decryptionKeys are the private keys of the store.
+type decryptionKeys struct {
+	age []age.Identity
+	pgp openpgp.EntityList
+}
+
+// decryptDocument decrypts a SOPS encrypted YAML or JSON document and
+// returns its root mapping without the SOPS metadata. The values are
+// authenticated with the message authentication code of the document.
+func decryptDocument(data []byte, keys *decryptionKeys) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing document: %w", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("document is not encrypted by sops")
+	}
+	root := doc.Content[0]
+	var meta *metadata
+	for i := 0; i < len(root.Content); i += 2 {
+		if root.Content[i].Value != metadataKey {
+			continue
+		}
+		meta = &metadata{}
+		if err := root.Content[i+1].Decode(meta); err != nil {
+			return nil, fmt.Errorf("error parsing sops metadata: %w", err)
+		}
+		root.Content = append(root.Content[:i], root.Content[i+2:]...)
+		break
+	}
+	if meta == nil {
+		return nil, fmt.Errorf("document is not encrypted by sops")
+	}
+	if meta.MACOnlyEncrypted {
+		return nil, fmt.Errorf("documents with mac_only_encrypted are not supported")
+	}
+
+	key, err := meta.dataKey(keys)
+	if err != nil {
+		return nil, err
+	}
+	mac := sha512.New()
+	if err := decryptNode(root, nil, key, mac); err != nil {
+		return nil, err
+	}
+	wantMAC, _, err := decryptValue(meta.MAC, key, meta.LastModified)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting message authentication code: %w", err)
+	}
+	if string(wantMAC) != fmt.Sprintf("%X", mac.Sum(nil)) {
+		return nil, fmt.Errorf("message authentication code mismatch, the document was modified")
+	}
+	return root, nil
+}
+
+// dataKey decrypts the data key of the document with any of the keys. The
+// data key of documents with several key groups is split between the groups
+// by shamir secret sharing, so each group only holds a share of it.
+func (m *metadata) dataKey(keys *decryptionKeys) ([]byte, error) {
+	group := keyGroup{Age: m.Age, PGP: m.PGP}
+	switch len(m.KeyGroups) {
+	case 0:
+	case 1:
+		group = m.KeyGroups[0]
+	default:
+		return nil, fmt.Errorf("documents with %d key groups are not supported, only a single key group is", len(m.KeyGroups))
+	}
+	var recipients []string
+	for _, k := range group.Age {
+		recipients = append(recipients, k.Recipient)
+		if len(keys.age) == 0 {
+			continue
+		}
+		r, err := age.Decrypt(agearmor.NewReader(strings.NewReader(k.EncryptedKey)), keys.age...)
+		if err != nil {
+			continue
+		}
+		return ioutil.ReadAll(r)
+	}
+	for _, k := range group.PGP {
+		recipients = append(recipients, k.Fingerprint)
+		if len(keys.pgp) == 0 {
+			continue
+		}
+		block, err := pgparmor.Decode(strings.NewReader(k.EncryptedKey))
+		if err != nil {
+			continue
+		}
+		md, err := openpgp.ReadMessage(block.Body, keys.pgp, nil, nil)
+		if err != nil {
+			continue
+		}
+		return ioutil.ReadAll(md.UnverifiedBody)
+	}
+	return nil, fmt.Errorf("none of the keys can decrypt the data key, encrypted for %s", strings.Join(recipients, ", "))
+}
+
+// decryptNode decrypts the values below node in place and adds them to the
+// message authentication code in document order. The path of a value is
+// the list of mapping keys leading to it, as values are authenticated with
+// their path by SOPS. Comments are removed as they may be encrypted.
+func decryptNode(node *yaml.Node, path []string, key []byte, mac hash.Hash) error {
+	node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			k := node.Content[i]
+			k.HeadComment, k.LineComment, k.FootComment = "", "", ""
+			keyPath := append(path[:len(path):len(path)], k.Value)
+			if err := decryptNode(node.Content[i+1], keyPath, key, mac); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if err := decryptNode(item, path, key, mac); err != nil {
+				return err
+			}
+		}
+	case yaml.AliasNode:
+		return decryptNode(node.Alias, path, key, mac)
+	case yaml.ScalarNode:
+		return decryptScalar(node, path, key, mac)
+	}
+	return nil
+}
+
+// decryptScalar decrypts an encrypted scalar in place, and adds the value to
+// the message authentication code formatted as by SOPS.
+func decryptScalar(node *yaml.Node, path []string, key []byte, mac hash.Hash) error {
+	value, tag := node.Value, node.ShortTag()
+	if tag == "!!str" && encryptedValueRegexp.MatchString(value) {
+		additionalData := strings.Join(path, ":") + ":"
+		plaintext, dataType, err := decryptValue(value, key, additionalData)
+		if err != nil {
+			return fmt.Errorf("error decrypting value of %q: %w", strings.Join(path, "."), err)
+		}
+		value = string(plaintext)
+		switch dataType {
+		case "str", "bytes":
+			tag = "!!str"
+		case "int":
+			tag = "!!int"
+		case "float":
+			tag = "!!float"
+		case "bool":
+			tag = "!!bool"
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("error decrypting value of %q: %w", strings.Join(path, "."), err)
+			}
+			value = strconv.FormatBool(b)
+		default:
+			return fmt.Errorf("error decrypting value of %q: unknown type %q", strings.Join(path, "."), dataType)
+		}
+		node.Value, node.Tag, node.Style = value, tag, 0
+	}
+
+	switch tag {
+	case "!!null":
+		// null values are neither encrypted nor authenticated
+	case "!!int":
+		if i, err := strconv.ParseInt(value, 0, 64); err == nil {
+			value = strconv.FormatInt(i, 10)
+		}
+		mac.Write([]byte(value))
+	case "!!float":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			value = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		mac.Write([]byte(value))
+	case "!!bool":
+		if b, err := strconv.ParseBool(strings.ToLower(value)); err == nil {
+			value = strings.Title(strconv.FormatBool(b))
+		}
+		mac.Write([]byte(value))
+	default:
+		mac.Write([]byte(value))
+	}
+	return nil
+}
+
+// decryptValue decrypts a value encrypted by SOPS with AES-GCM and returns
+// the plaintext along with the type of the value.
+func decryptValue(value string, key []byte, additionalData string) ([]byte, string, error) {
+	match := encryptedValueRegexp.FindStringSubmatch(value)
+	if match == nil {
+		return nil, "", fmt.Errorf("value is not encrypted by sops")
+	}
+	var parts [3][]byte
+	for i := range parts {
+		decoded, err := base64.StdEncoding.DecodeString(match[i+1])
+		if err != nil {
+			return nil, "", fmt.Errorf("error decoding encrypted value: %w", err)
+		}
+		parts[i] = decoded
+	}
+	data, iv, tag := parts[0], parts[1], parts[2]
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, "", fmt.Errorf("error decrypting value: %w", err)
+	}
+	return plaintext, match[4], nil
+}
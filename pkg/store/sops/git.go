@@ -0,0 +1,515 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sops
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/go-logr/logr"
+
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	"github.com/itscontained/secret-manager/pkg/util/storeref"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	remoteName      = "origin"
+	defaultUsername = "git"
+
+	// repositoryCacheTTL is how long the repository of a store is cached
+	// without being read, so that repositories of deleted stores are evicted.
+	repositoryCacheTTL = time.Hour
+
+	// requestTimeout bounds listing the refs of a repository and establishing
+	// SSH connections.
+	requestTimeout = 30 * time.Second
+	// fetchTimeout bounds fetching a repository, which includes the history
+	// of all branches and tags for pinned commits.
+	fetchTimeout = 5 * time.Minute
+)
+
+// commitHashRegexp matches full commit hashes.
+var commitHashRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// repository holds the commit of the pinned ref of a store in memory. It
+// only holds the objects of the last commit read, which is replaced as the
+// pinned ref moves.
+type repository struct {
+	sync.Mutex
+	url  string
+	repo *git.Repository
+}
+
+// repositoryCacheEntry is the repository of a store at a given generation
+// of the store spec.
+type repositoryCacheEntry struct {
+	generation int64
+	lastUsed   time.Time
+	repository *repository
+}
+
+var (
+	repositoryCache     = make(map[string]*repositoryCacheEntry)
+	repositoryCacheLock sync.Mutex
+)
+
+// cachedRepository returns the repository of the store, which is cached
+// until the store spec changes or it is not read for repositoryCacheTTL.
+func (s *Sops) cachedRepository() *repository {
+	cacheKey := fmt.Sprintf("%s/%s/%s", s.store.GetTypeMeta().Kind, s.store.GetNamespace(), s.store.GetName())
+	now := time.Now()
+	repositoryCacheLock.Lock()
+	defer repositoryCacheLock.Unlock()
+	for key, entry := range repositoryCache {
+		if now.Sub(entry.lastUsed) > repositoryCacheTTL {
+			delete(repositoryCache, key)
+		}
+	}
+	entry, ok := repositoryCache[cacheKey]
+	if !ok || entry.generation != s.store.GetGeneration() {
+		entry = &repositoryCacheEntry{
+			generation: s.store.GetGeneration(),
+			repository: &repository{url: s.store.GetSpec().Sops.Git.URL},
+		}
+		repositoryCache[cacheKey] = entry
+	}
+	entry.lastUsed = now
+	return entry.repository
+}
+
+// commit returns the commit the pinned ref of the store points to. The ref
+// is resolved once per client, so that all files are read from the same
+// commit without listing the refs of the repository for each of them.
+func (s *Sops) commit(ctx context.Context) (*object.Commit, error) {
+	s.commitLock.Lock()
+	defer s.commitLock.Unlock()
+	if s.resolvedCommit != nil {
+		return s.resolvedCommit, nil
+	}
+	ref := ""
+	if pinned := s.store.GetSpec().Sops.Git.Ref; pinned != nil {
+		ref = *pinned
+	}
+	commit, err := s.cachedRepository().commit(ctx, ref, s.auth, s.log)
+	if err != nil {
+		return nil, err
+	}
+	s.resolvedCommit = commit
+	return commit, nil
+}
+
+// commit returns the commit of ref, fetching it unless it was read before.
+// Branches and tags are fetched without their history. Commit hashes can
+// only be fetched along with the history of all branches and tags, which is
+// dropped once the commit is found.
+func (r *repository) commit(ctx context.Context, ref string, auth transport.AuthMethod, log logr.Logger) (*object.Commit, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if commitHashRegexp.MatchString(ref) {
+		hash := plumbing.NewHash(ref)
+		if commit, err := r.peel(hash); err == nil {
+			return commit, nil
+		}
+		log.V(1).Info("fetching repository", "hash", hash.String())
+		repo, err := r.fetch(ctx, auth, 0, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+		if err != nil {
+			return nil, err
+		}
+		commit, err := repo.CommitObject(hash)
+		if err != nil {
+			return nil, fmt.Errorf("commit %s not found in repository: %w", hash, err)
+		}
+		if r.repo, err = snapshot(repo, commit); err != nil {
+			return nil, err
+		}
+		return r.peel(hash)
+	}
+
+	hash, refName, err := r.resolve(ctx, ref, auth)
+	if err != nil {
+		return nil, err
+	}
+	if commit, err := r.peel(hash); err == nil {
+		return commit, nil
+	}
+	log.V(1).Info("fetching repository", "ref", refName, "hash", hash.String())
+	repo, err := r.fetch(ctx, auth, 1, config.RefSpec(fmt.Sprintf("+%s:%s", refName, refName)))
+	if err != nil {
+		return nil, err
+	}
+	// the ref may have moved since it was resolved
+	if reference, err := repo.Reference(refName, true); err == nil {
+		hash = reference.Hash()
+	}
+	r.repo = repo
+	commit, err := r.peel(hash)
+	if err != nil {
+		return nil, fmt.Errorf("commit %s not found in repository: %w", hash, err)
+	}
+	return commit, nil
+}
+
+// fetch fetches the refs into a new in-memory repository, truncating their
+// history to depth commits unless depth is 0.
+func (r *repository) fetch(ctx context.Context, auth transport.AuthMethod, depth int, refSpecs ...config.RefSpec) (*git.Repository, error) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: remoteName, URLs: []string{r.url}}); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	err = repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: remoteName,
+		RefSpecs:   refSpecs,
+		Depth:      depth,
+		Auth:       auth,
+		Tags:       git.NoTags,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, fmt.Errorf("error fetching repository: %w", err)
+	}
+	return repo, nil
+}
+
+// snapshot returns a new in-memory repository holding only the commit and
+// the objects of its tree.
+func snapshot(src *git.Repository, commit *object.Commit) (*git.Repository, error) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := copyObject(src.Storer, repo.Storer, plumbing.CommitObject, commit.Hash); err != nil {
+		return nil, err
+	}
+	if err := copyTree(src.Storer, repo.Storer, commit.TreeHash); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// copyTree copies the tree and the objects below it. Submodules are skipped.
+func copyTree(src, dst storer.EncodedObjectStorer, hash plumbing.Hash) error {
+	if err := copyObject(src, dst, plumbing.TreeObject, hash); err != nil {
+		return err
+	}
+	tree, err := object.GetTree(src, hash)
+	if err != nil {
+		return err
+	}
+	for _, entry := range tree.Entries {
+		switch entry.Mode {
+		case filemode.Dir:
+			err = copyTree(src, dst, entry.Hash)
+		case filemode.Submodule:
+			continue
+		default:
+			err = copyObject(src, dst, plumbing.BlobObject, entry.Hash)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyObject(src, dst storer.EncodedObjectStorer, objectType plumbing.ObjectType, hash plumbing.Hash) error {
+	obj, err := src.EncodedObject(objectType, hash)
+	if err != nil {
+		return err
+	}
+	_, err = dst.SetEncodedObject(obj)
+	return err
+}
+
+// resolve returns the hash and name of the remote branch or tag ref, or of
+// the default branch if ref is empty.
+func (r *repository) resolve(ctx context.Context, ref string, auth transport.AuthMethod) (plumbing.Hash, plumbing.ReferenceName, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: remoteName, URLs: []string{r.url}})
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+	if err != nil {
+		return plumbing.ZeroHash, "", fmt.Errorf("error listing refs of repository: %w", err)
+	}
+	byName := make(map[plumbing.ReferenceName]*plumbing.Reference, len(refs))
+	for _, reference := range refs {
+		byName[reference.Name()] = reference
+	}
+
+	var candidates []plumbing.ReferenceName
+	switch {
+	case ref == "":
+		head, ok := byName[plumbing.HEAD]
+		if !ok {
+			return plumbing.ZeroHash, "", fmt.Errorf("repository has no default branch")
+		}
+		if head.Type() == plumbing.SymbolicReference {
+			candidates = []plumbing.ReferenceName{head.Target()}
+		} else {
+			return head.Hash(), plumbing.HEAD, nil
+		}
+	case strings.HasPrefix(ref, "refs/"):
+		candidates = []plumbing.ReferenceName{plumbing.ReferenceName(ref)}
+	default:
+		candidates = []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)}
+	}
+	for _, name := range candidates {
+		if reference, ok := byName[name]; ok && reference.Type() == plumbing.HashReference {
+			return reference.Hash(), name, nil
+		}
+	}
+	return plumbing.ZeroHash, "", fmt.Errorf("ref %q not found in repository", ref)
+}
+
+// peel returns the commit of the hash of a commit or annotated tag.
+func (r *repository) peel(hash plumbing.Hash) (*object.Commit, error) {
+	if r.repo == nil {
+		return nil, plumbing.ErrObjectNotFound
+	}
+	if tag, err := r.repo.TagObject(hash); err == nil {
+		return tag.Commit()
+	}
+	return r.repo.CommitObject(hash)
+}
+
+// setAuth configures the credentials of the repository.
+func (s *Sops) setAuth(ctx context.Context, repo *smv1alpha1.SopsGitRepository) error {
+	if repo.AuthSecretRef == nil {
+		s.log.V(1).Info("no authentication defined. fetching repository anonymously")
+		return nil
+	}
+	auth := repo.AuthSecretRef
+	// TODO: Validating Webhook Candidate
+	if auth.Password != nil && auth.SSHPrivateKey != nil {
+		return fmt.Errorf("multiple authentication methods configured")
+	}
+	username := ""
+	if auth.Username != nil {
+		var err error
+		if username, err = storeref.SecretKey(ctx, s.kube, s.store, "username", *auth.Username); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case auth.Password != nil:
+		s.log.V(1).Info("password authentication defined")
+		password, err := storeref.SecretKey(ctx, s.kube, s.store, "password", *auth.Password)
+		if err != nil {
+			return err
+		}
+		if username == "" {
+			username = defaultUsername
+		}
+		s.auth = &githttp.BasicAuth{Username: username, Password: password}
+	case auth.SSHPrivateKey != nil:
+		s.log.V(1).Info("ssh key authentication defined")
+		if auth.KnownHosts == nil {
+			return fmt.Errorf("missing knownHosts for ssh authentication")
+		}
+		privateKey, err := storeref.SecretKey(ctx, s.kube, s.store, "sshPrivateKey", *auth.SSHPrivateKey)
+		if err != nil {
+			return err
+		}
+		knownHosts, err := storeref.SecretKey(ctx, s.kube, s.store, "knownHosts", *auth.KnownHosts)
+		if err != nil {
+			return err
+		}
+		if username == "" {
+			if endpoint, err := transport.NewEndpoint(repo.URL); err == nil {
+				username = endpoint.User
+			}
+		}
+		if username == "" {
+			username = defaultUsername
+		}
+		keys, err := gitssh.NewPublicKeys(username, []byte(privateKey), "")
+		if err != nil {
+			return fmt.Errorf("error parsing ssh private key: %w", err)
+		}
+		if keys.HostKeyCallback, err = hostKeyCallback(knownHosts); err != nil {
+			return err
+		}
+		s.auth = &sshPublicKeys{keys}
+	default:
+		return fmt.Errorf("missing password or sshPrivateKey in authSecretRef")
+	}
+	return nil
+}
+
+// sshPublicKeys authenticates with a private key, bounding the time to
+// establish SSH connections.
+type sshPublicKeys struct {
+	*gitssh.PublicKeys
+}
+
+func (k *sshPublicKeys) ClientConfig() (*ssh.ClientConfig, error) {
+	config, err := k.PublicKeys.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.Timeout = requestTimeout
+	return config, nil
+}
+
+// knownHost is an entry of a known_hosts file.
+type knownHost struct {
+	// patterns are the host patterns of the entry, either plain, possibly
+	// with wildcards and negated by a leading "!", or hashed.
+	patterns []string
+	key      ssh.PublicKey
+	revoked  bool
+}
+
+// hostKeyCallback verifies SSH host keys against the known hosts. Host
+// patterns are matched as by OpenSSH, against both the host name and the
+// address of the server. Keys marked as revoked are rejected for any host.
+func hostKeyCallback(knownHosts string) (ssh.HostKeyCallback, error) {
+	var hosts []knownHost
+	rest := []byte(knownHosts)
+	for {
+		marker, patterns, key, _, next, err := ssh.ParseKnownHosts(rest)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing known hosts: %w", err)
+		}
+		rest = next
+		switch marker {
+		case "", "revoked":
+			hosts = append(hosts, knownHost{patterns: patterns, key: key, revoked: marker == "revoked"})
+		default:
+			return nil, fmt.Errorf("@%s entries of known hosts are not supported", marker)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no host keys in known hosts")
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		addresses := []string{knownhosts.Normalize(hostname)}
+		if remote != nil {
+			addresses = append(addresses, knownhosts.Normalize(remote.String()))
+		}
+		known := false
+		for _, host := range hosts {
+			if !bytes.Equal(host.key.Marshal(), key.Marshal()) {
+				continue
+			}
+			if host.revoked {
+				return fmt.Errorf("ssh: host key of %s is revoked", hostname)
+			}
+			known = known || host.matches(addresses)
+		}
+		if !known {
+			return fmt.Errorf("ssh: host key of %s is not a known host key", hostname)
+		}
+		return nil
+	}, nil
+}
+
+// matches returns whether any of the addresses matches the host patterns of
+// the entry, and none matches a negated pattern.
+func (h *knownHost) matches(addresses []string) bool {
+	matched := false
+	for _, pattern := range h.patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		for _, address := range addresses {
+			if !matchHostPattern(pattern, address) {
+				continue
+			}
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchHostPattern matches a normalized address against a plain host pattern
+// or against a hashed pattern "|1|salt|hash", where hash is the HMAC-SHA1 of
+// the address keyed by salt.
+func matchHostPattern(pattern, address string) bool {
+	if !strings.HasPrefix(pattern, "|1|") {
+		return matchWildcard(pattern, address)
+	}
+	parts := strings.Split(pattern[len("|1|"):], "|")
+	if len(parts) != 2 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(address))
+	return hmac.Equal(mac.Sum(nil), hash)
+}
+
+// matchWildcard matches s against pattern, where "*" matches any number of
+// characters and "?" a single character.
+func matchWildcard(pattern, s string) bool {
+	if pattern == "" {
+		return s == ""
+	}
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(s); i++ {
+			if matchWildcard(pattern[1:], s[i:]) {
+				return true
+			}
+		}
+		return false
+	case '?':
+		return s != "" && matchWildcard(pattern[1:], s[1:])
+	default:
+		return s != "" && pattern[0] == s[0] && matchWildcard(pattern[1:], s[1:])
+	}
+}
@@ -0,0 +1,285 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"github.com/go-logr/logr"
+
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	ctxlog "github.com/itscontained/secret-manager/pkg/log"
+	"github.com/itscontained/secret-manager/pkg/store"
+	"github.com/itscontained/secret-manager/pkg/store/schema"
+	"github.com/itscontained/secret-manager/pkg/util/property"
+	"github.com/itscontained/secret-manager/pkg/util/storeref"
+
+	"golang.org/x/crypto/openpgp"
+
+	"gopkg.in/yaml.v3"
+
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ store.Client = &Sops{}
+var _ store.MetadataClient = &Sops{}
+
+// binaryDataKey is the key of the value of binary files encrypted by SOPS.
+const binaryDataKey = "data"
+
+// Sops reads SOPS encrypted files from a Git repository.
+type Sops struct {
+	kube  ctrlclient.Client
+	store smv1alpha1.GenericStore
+	log   logr.Logger
+
+	auth transport.AuthMethod
+	keys *decryptionKeys
+
+	commitLock     sync.Mutex
+	resolvedCommit *object.Commit
+}
+
+func init() {
+	schema.Register(&Sops{}, &smv1alpha1.SecretStoreSpec{
+		Sops: &smv1alpha1.SopsStore{},
+	})
+}
+
+func (s *Sops) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctrlclient.Client, namespace string) (store.Client, error) {
+	log := ctxlog.FromContext(ctx)
+	sopsClient := &Sops{
+		kube:  kube,
+		store: store,
+		log:   log,
+	}
+	if err := sopsClient.setClient(ctx); err != nil {
+		log.Error(err, "could not create new sops client")
+		return nil, err
+	}
+	return sopsClient, nil
+}
+
+func (s *Sops) GetSecret(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, error) {
+	data, _, err := s.GetSecretWithMetadata(ctx, ref)
+	return data, err
+}
+
+func (s *Sops) GetSecretWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, *store.SecretMetadata, error) {
+	file, metadata, err := s.readFile(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if file.format == formatBinary {
+		if ref.Property != nil {
+			return nil, nil, fmt.Errorf("property is not supported for binary file %q", ref.Name)
+		}
+		return file.data, metadata, nil
+	}
+	if ref.Property == nil {
+		return file.data, metadata, nil
+	}
+	value, err := property.Get(file.json, *ref.Property)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, metadata, nil
+}
+
+func (s *Sops) GetSecretMap(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, error) {
+	data, _, err := s.GetSecretMapWithMetadata(ctx, ref)
+	return data, err
+}
+
+// GetSecretMapWithMetadata returns the top-level values of the decrypted
+// document. The value of a binary file is keyed by Property, defaulting to
+// "secret".
+func (s *Sops) GetSecretMapWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, *store.SecretMetadata, error) {
+	file, metadata, err := s.readFile(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if file.format == formatBinary {
+		key := smv1alpha1.DefaultSecretKey
+		if ref.Property != nil {
+			key = *ref.Property
+		}
+		return map[string][]byte{key: file.data}, metadata, nil
+	}
+	data, err := property.Map(file.json)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, metadata, nil
+}
+
+type fileFormat string
+
+const (
+	formatYAML   fileFormat = "yaml"
+	formatJSON   fileFormat = "json"
+	formatBinary fileFormat = "binary"
+)
+
+// decryptedFile is a decrypted file of the repository.
+type decryptedFile struct {
+	format fileFormat
+	// data is the decrypted file in its original format, or the value of a
+	// binary file.
+	data []byte
+	// json is the decrypted document encoded as JSON.
+	json []byte
+}
+
+// readFile reads and decrypts the file at Name in the commit of the pinned
+// ref, which is reported as version.
+func (s *Sops) readFile(ctx context.Context, ref smv1alpha1.RemoteReference) (*decryptedFile, *store.SecretMetadata, error) {
+	if ref.Version != nil {
+		return nil, nil, fmt.Errorf("version is not supported by sops stores")
+	}
+	if ref.Find != nil {
+		return nil, nil, fmt.Errorf("find is not supported by sops stores")
+	}
+	filePath := strings.TrimPrefix(path.Clean("/"+ref.Name), "/")
+	var format fileFormat
+	switch path.Ext(filePath) {
+	case ".yaml", ".yml":
+		format = formatYAML
+	case ".json":
+		format = formatJSON
+	case ".env", ".ini":
+		return nil, nil, fmt.Errorf("dotenv and ini files are not supported by sops stores")
+	default:
+		format = formatBinary
+	}
+
+	commit, err := s.commit(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata := &store.SecretMetadata{Version: commit.Hash.String()}
+	encrypted, err := readBlob(commit, filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := decryptDocument(encrypted, s.keys)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decrypting file %q: %w", filePath, err)
+	}
+	var document map[string]interface{}
+	if err := root.Decode(&document); err != nil {
+		return nil, nil, fmt.Errorf("error decoding file %q: %w", filePath, err)
+	}
+	file := &decryptedFile{format: format}
+	if file.json, err = json.Marshal(document); err != nil {
+		return nil, nil, fmt.Errorf("error encoding file %q: %w", filePath, err)
+	}
+
+	switch format {
+	case formatYAML:
+		buf := &bytes.Buffer{}
+		enc := yaml.NewEncoder(buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(root); err != nil {
+			return nil, nil, fmt.Errorf("error encoding file %q: %w", filePath, err)
+		}
+		file.data = buf.Bytes()
+	case formatJSON:
+		file.data = file.json
+	case formatBinary:
+		value, ok := document[binaryDataKey].(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("file %q is not a binary file encrypted by sops", filePath)
+		}
+		file.data = []byte(value)
+	}
+	return file, metadata, nil
+}
+
+// readBlob returns the content of the file at filePath in the commit.
+func readBlob(commit *object.Commit, filePath string) ([]byte, error) {
+	f, err := commit.File(filePath)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, fmt.Errorf("file %q not found in commit %s", filePath, commit.Hash)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %q: %w", filePath, err)
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %q: %w", filePath, err)
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func (s *Sops) setClient(ctx context.Context) error {
+	spec := s.store.GetSpec().Sops
+	if spec.Git.URL == "" {
+		return fmt.Errorf("missing git url in store config")
+	}
+	if err := s.setKeys(ctx, &spec.Keys); err != nil {
+		return err
+	}
+	return s.setAuth(ctx, &spec.Git)
+}
+
+// setKeys loads the private keys decrypting the files.
+func (s *Sops) setKeys(ctx context.Context, keys *smv1alpha1.SopsKeys) error {
+	if keys.Age == nil && keys.PGP == nil {
+		return fmt.Errorf("missing age or pgp keys in store config")
+	}
+	s.keys = &decryptionKeys{}
+	if keys.Age != nil {
+		identities, err := storeref.SecretKey(ctx, s.kube, s.store, "age", *keys.Age)
+		if err != nil {
+			return err
+		}
+		if s.keys.age, err = age.ParseIdentities(strings.NewReader(identities)); err != nil {
+			return fmt.Errorf("error parsing age identities: %w", err)
+		}
+	}
+	if keys.PGP != nil {
+		armored, err := storeref.SecretKey(ctx, s.kube, s.store, "pgp", *keys.PGP)
+		if err != nil {
+			return err
+		}
+		if s.keys.pgp, err = openpgp.ReadArmoredKeyRing(strings.NewReader(armored)); err != nil {
+			return fmt.Errorf("error parsing pgp keys: %w", err)
+		}
+		for _, entity := range s.keys.pgp {
+			if entity.PrivateKey == nil {
+				return fmt.Errorf("pgp key %X is not a private key", entity.PrimaryKey.Fingerprint)
+			}
+			if entity.PrivateKey.Encrypted {
+				return fmt.Errorf("pgp key %X is protected by a passphrase", entity.PrimaryKey.Fingerprint)
+			}
+		}
+	}
+	return nil
+}
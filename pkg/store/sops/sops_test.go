@@ -0,0 +1,857 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sops
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	ctxlog "github.com/itscontained/secret-manager/pkg/log"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"k8s.io/client-go/kubernetes/scheme"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// testRepository is a bare repository served over the file transport, which
+// is updated by pushing commits from a working copy.
+type testRepository struct {
+	t    *testing.T
+	url  string
+	work *git.Repository
+}
+
+func newTestRepository(t *testing.T) *testRepository {
+	t.Helper()
+	// the file transport runs git-upload-pack and git-receive-pack
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "sops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	bareDir := filepath.Join(dir, "bare.git")
+	if _, err := git.PlainInit(bareDir, true); err != nil {
+		t.Fatal(err)
+	}
+	work, err := git.PlainInit(filepath.Join(dir, "work"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := work.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{bareDir}}); err != nil {
+		t.Fatal(err)
+	}
+	return &testRepository{t: t, url: bareDir, work: work}
+}
+
+// commit commits the files to the current branch and pushes all branches
+// and tags.
+func (r *testRepository) commit(files map[string][]byte) plumbing.Hash {
+	r.t.Helper()
+	wt, err := r.work.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	for dst, data := range files {
+		if err := os.MkdirAll(filepath.Join(wt.Filesystem.Root(), filepath.Dir(dst)), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(wt.Filesystem.Root(), dst), data, 0644); err != nil {
+			r.t.Fatal(err)
+		}
+		if _, err := wt.Add(dst); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	hash, err := wt.Commit("update secrets", &git.CommitOptions{Author: signature()})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	r.push()
+	return hash
+}
+
+func (r *testRepository) push() {
+	r.t.Helper()
+	err := r.work.Push(&git.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"},
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		r.t.Fatal(err)
+	}
+}
+
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func signature() *object.Signature {
+	return &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+}
+
+func testKeys(t *testing.T) *corev1.Secret {
+	t.Helper()
+	otherAge, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "sops-keys", Namespace: "default"},
+		Data: map[string][]byte{
+			"age":       fixture(t, "age.txt"),
+			"pgp":       fixture(t, "pgp.asc"),
+			"other-age": []byte(otherAge.String()),
+		},
+	}
+}
+
+func keyRef(key string) *smmeta.SecretKeySelector {
+	return &smmeta.SecretKeySelector{
+		LocalObjectReference: smmeta.LocalObjectReference{Name: "sops-keys"},
+		Key:                  key,
+	}
+}
+
+// newTestSops returns a client of the store with the given name reading the
+// repository at ref. Repositories are cached by store name.
+func newTestSops(t *testing.T, name, url string, ref *string, keys smv1alpha1.SopsKeys, objects ...runtime.Object) (*Sops, error) {
+	t.Helper()
+	store := &smv1alpha1.SecretStore{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: smv1alpha1.SecretStoreSpec{
+			Sops: &smv1alpha1.SopsStore{
+				Git:  smv1alpha1.SopsGitRepository{URL: url, Ref: ref},
+				Keys: keys,
+			},
+		},
+	}
+	objects = append(objects, testKeys(t))
+	ctx := ctxlog.IntoContext(context.Background(), ctrl.Log)
+	client, err := (&Sops{}).New(ctx, store, fakeclient.NewFakeClientWithScheme(scheme.Scheme, objects...), "default")
+	if err != nil {
+		return nil, err
+	}
+	return client.(*Sops), nil
+}
+
+const decryptedYAML = `db:
+  user: app
+  password: s3cr3t
+  port: 5432
+  ratio: 0.75
+  enabled: true
+  hosts:
+    - db-0.example.com
+    - db-1.example.com
+  empty: ""
+  nothing: null
+api_unencrypted: public-value
+multiline: |
+  line one
+  line two
+`
+
+func TestGetSecret(t *testing.T) {
+	repo := newTestRepository(t)
+	hash := repo.commit(map[string][]byte{
+		"apps/prod/secrets.yaml": fixture(t, "secrets.yaml"),
+		"apps/prod/secrets.json": fixture(t, "secrets.json"),
+		"certs/tls.crt":          fixture(t, "tls.crt"),
+		"apps/prod/.env":         fixture(t, "secrets.json"),
+	})
+	s, err := newTestSops(t, "get-secret", repo.url, nil, smv1alpha1.SopsKeys{Age: keyRef("age"), PGP: keyRef("pgp")})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+
+	tests := map[string]struct {
+		ref     smv1alpha1.RemoteReference
+		want    string
+		wantErr bool
+	}{
+		"yaml document": {
+			ref:  smv1alpha1.RemoteReference{Name: "apps/prod/secrets.yaml"},
+			want: decryptedYAML,
+		},
+		"yaml string": {
+			ref:  smv1alpha1.RemoteReference{Name: "/apps/prod/secrets.yaml", Property: smmeta.String("db.password")},
+			want: "s3cr3t",
+		},
+		"yaml number": {
+			ref:  smv1alpha1.RemoteReference{Name: "apps/prod/secrets.yaml", Property: smmeta.String("db.port")},
+			want: "5432",
+		},
+		"yaml boolean": {
+			ref:  smv1alpha1.RemoteReference{Name: "apps/prod/secrets.yaml", Property: smmeta.String("db.enabled")},
+			want: "true",
+		},
+		"yaml array item": {
+			ref:  smv1alpha1.RemoteReference{Name: "apps/prod/secrets.yaml", Property: smmeta.String("db.hosts.1")},
+			want: "db-1.example.com",
+		},
+		"yaml unencrypted value": {
+			ref:  smv1alpha1.RemoteReference{Name: "apps/prod/secrets.yaml", Property: smmeta.String("api_unencrypted")},
+			want: "public-value",
+		},
+		"yaml multiline string": {
+			ref:  smv1alpha1.RemoteReference{Name: "apps/prod/secrets.yaml", Property: smmeta.String("multiline")},
+			want: "line one\nline two\n",
+		},
+		"json document": {
+			ref:  smv1alpha1.RemoteReference{Name: "apps/prod/secrets.json"},
+			want: `{"list":[1,"two"],"nested":{"count":3,"flag":false},"token":"abc123"}`,
+		},
+		"json object": {
+			ref:  smv1alpha1.RemoteReference{Name: "apps/prod/secrets.json", Property: smmeta.String("nested")},
+			want: `{"count":3,"flag":false}`,
+		},
+		"binary": {
+			ref:  smv1alpha1.RemoteReference{Name: "certs/tls.crt"},
+			want: "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n",
+		},
+		"binary with property": {
+			ref:     smv1alpha1.RemoteReference{Name: "certs/tls.crt", Property: smmeta.String("data")},
+			wantErr: true,
+		},
+		"missing property": {
+			ref:     smv1alpha1.RemoteReference{Name: "apps/prod/secrets.yaml", Property: smmeta.String("db.missing")},
+			wantErr: true,
+		},
+		"missing file": {
+			ref:     smv1alpha1.RemoteReference{Name: "apps/dev/secrets.yaml"},
+			wantErr: true,
+		},
+		"dotenv": {
+			ref:     smv1alpha1.RemoteReference{Name: "apps/prod/.env"},
+			wantErr: true,
+		},
+		"version": {
+			ref:     smv1alpha1.RemoteReference{Name: "apps/prod/secrets.yaml", Version: smmeta.String(hash.String())},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, metadata, err := s.GetSecretWithMetadata(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+			if metadata.Version != hash.String() {
+				t.Errorf("expected version %s, got %s", hash, metadata.Version)
+			}
+		})
+	}
+}
+
+func TestGetSecretMap(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.commit(map[string][]byte{
+		"secrets.yaml": fixture(t, "secrets.yaml"),
+		"secrets.json": fixture(t, "secrets.json"),
+		"tls.crt":      fixture(t, "tls.crt"),
+	})
+	s, err := newTestSops(t, "get-secret-map", repo.url, nil, smv1alpha1.SopsKeys{PGP: keyRef("pgp")})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+
+	tests := map[string]struct {
+		ref  smv1alpha1.RemoteReference
+		want map[string][]byte
+	}{
+		"yaml": {
+			ref: smv1alpha1.RemoteReference{Name: "secrets.yaml"},
+			want: map[string][]byte{
+				"db":              []byte(`{"empty":"","enabled":true,"hosts":["db-0.example.com","db-1.example.com"],"nothing":null,"password":"s3cr3t","port":5432,"ratio":0.75,"user":"app"}`),
+				"api_unencrypted": []byte("public-value"),
+				"multiline":       []byte("line one\nline two\n"),
+			},
+		},
+		"json": {
+			ref: smv1alpha1.RemoteReference{Name: "secrets.json"},
+			want: map[string][]byte{
+				"token":  []byte("abc123"),
+				"nested": []byte(`{"count":3,"flag":false}`),
+				"list":   []byte(`[1,"two"]`),
+			},
+		},
+		"binary": {
+			ref: smv1alpha1.RemoteReference{Name: "tls.crt"},
+			want: map[string][]byte{
+				smv1alpha1.DefaultSecretKey: []byte("-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"),
+			},
+		},
+		"binary with property": {
+			ref: smv1alpha1.RemoteReference{Name: "tls.crt", Property: smmeta.String("tls.crt")},
+			want: map[string][]byte{
+				"tls.crt": []byte("-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := s.GetSecretMap(context.Background(), tc.ref)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDecrypt(t *testing.T) {
+	repo := newTestRepository(t)
+	encrypted := fixture(t, "secrets.yaml")
+	repo.commit(map[string][]byte{
+		"secrets.yaml":            encrypted,
+		"secrets.json":            fixture(t, "secrets.json"),
+		"tampered.yaml":           []byte(strings.Replace(string(encrypted), "public-value", "tampered-value", 1)),
+		"plain.yaml":              []byte("password: s3cr3t\n"),
+		"key-groups.json":         fixture(t, "key-groups.json"),
+		"mac-only-encrypted.yaml": []byte(strings.Replace(string(encrypted), "\nsops:\n", "\nsops:\n    mac_only_encrypted: true\n", 1)),
+	})
+
+	tests := map[string]struct {
+		keys    smv1alpha1.SopsKeys
+		file    string
+		want    string
+		wantErr string
+	}{
+		"age key": {
+			keys: smv1alpha1.SopsKeys{Age: keyRef("age")},
+			file: "secrets.yaml",
+			want: "s3cr3t",
+		},
+		"pgp key": {
+			keys: smv1alpha1.SopsKeys{PGP: keyRef("pgp")},
+			file: "secrets.yaml",
+			want: "s3cr3t",
+		},
+		"pgp key only recipient": {
+			keys: smv1alpha1.SopsKeys{PGP: keyRef("pgp")},
+			file: "secrets.json",
+		},
+		"age key not a recipient": {
+			keys:    smv1alpha1.SopsKeys{Age: keyRef("other-age")},
+			file:    "secrets.json",
+			wantErr: "none of the keys",
+		},
+		"other age key": {
+			keys:    smv1alpha1.SopsKeys{Age: keyRef("other-age")},
+			file:    "secrets.yaml",
+			wantErr: "none of the keys",
+		},
+		"tampered value": {
+			keys:    smv1alpha1.SopsKeys{Age: keyRef("age")},
+			file:    "tampered.yaml",
+			wantErr: "message authentication code mismatch",
+		},
+		"not encrypted": {
+			keys:    smv1alpha1.SopsKeys{Age: keyRef("age")},
+			file:    "plain.yaml",
+			wantErr: "not encrypted by sops",
+		},
+		"multiple key groups": {
+			keys:    smv1alpha1.SopsKeys{Age: keyRef("age"), PGP: keyRef("pgp")},
+			file:    "key-groups.json",
+			wantErr: "key groups",
+		},
+		"mac only encrypted": {
+			keys:    smv1alpha1.SopsKeys{Age: keyRef("age")},
+			file:    "mac-only-encrypted.yaml",
+			wantErr: "mac_only_encrypted",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := newTestSops(t, "decrypt", repo.url, nil, tc.keys)
+			if err != nil {
+				t.Fatalf("error creating client: %v", err)
+			}
+			got, err := s.GetSecretMap(context.Background(), smv1alpha1.RemoteReference{Name: tc.file})
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.want != "" && !strings.Contains(string(got["db"]), tc.want) {
+				t.Errorf("expected db to contain %q, got %q", tc.want, got["db"])
+			}
+		})
+	}
+}
+
+func TestRef(t *testing.T) {
+	repo := newTestRepository(t)
+	first := repo.commit(map[string][]byte{"first.json": fixture(t, "secrets.json")})
+	if _, err := repo.work.CreateTag("v1", first, &git.CreateTagOptions{Tagger: signature(), Message: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.work.Storer.SetReference(plumbing.NewHashReference("refs/heads/release", first)); err != nil {
+		t.Fatal(err)
+	}
+	second := repo.commit(map[string][]byte{"second.json": fixture(t, "secrets.json")})
+
+	tests := map[string]struct {
+		ref     *string
+		want    plumbing.Hash
+		wantErr bool
+	}{
+		"default branch": {want: second},
+		"branch":         {ref: smmeta.String("master"), want: second},
+		"other branch":   {ref: smmeta.String("release"), want: first},
+		"full ref name":  {ref: smmeta.String("refs/heads/release"), want: first},
+		"annotated tag":  {ref: smmeta.String("v1"), want: first},
+		"commit":         {ref: smmeta.String(first.String()), want: first},
+		"missing ref":    {ref: smmeta.String("missing"), wantErr: true},
+		"missing commit": {ref: smmeta.String(strings.Repeat("0", 39) + "1"), wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := newTestSops(t, "ref "+name, repo.url, tc.ref, smv1alpha1.SopsKeys{PGP: keyRef("pgp")})
+			if err != nil {
+				t.Fatalf("error creating client: %v", err)
+			}
+			_, metadata, err := s.GetSecretMapWithMetadata(context.Background(), smv1alpha1.RemoteReference{Name: "first.json"})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if metadata.Version != tc.want.String() {
+				t.Errorf("expected version %s, got %s", tc.want, metadata.Version)
+			}
+		})
+	}
+}
+
+func TestRepositoryCache(t *testing.T) {
+	repo := newTestRepository(t)
+	first := repo.commit(map[string][]byte{"secrets.json": fixture(t, "secrets.json")})
+	keys := smv1alpha1.SopsKeys{PGP: keyRef("pgp")}
+	newClient := func(storeName string, ref *string) *Sops {
+		t.Helper()
+		s, err := newTestSops(t, storeName, repo.url, ref, keys)
+		if err != nil {
+			t.Fatalf("error creating client: %v", err)
+		}
+		return s
+	}
+	version := func(s *Sops) (string, error) {
+		t.Helper()
+		_, metadata, err := s.GetSecretWithMetadata(context.Background(), smv1alpha1.RemoteReference{Name: "secrets.json"})
+		if err != nil {
+			return "", err
+		}
+		return metadata.Version, nil
+	}
+	read := func(storeName string, ref *string) (string, error) {
+		t.Helper()
+		return version(newClient(storeName, ref))
+	}
+	// commits returns the number of commits held by the repository of the
+	// store.
+	commits := func(storeName string) int {
+		t.Helper()
+		entry, ok := repositoryCache[smv1alpha1.SecretStoreKind+"/default/"+storeName]
+		if !ok {
+			t.Fatalf("repository of store %q is not cached", storeName)
+		}
+		iter, err := entry.repository.repo.CommitObjects()
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		if err := iter.ForEach(func(*object.Commit) error { n++; return nil }); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	if version, err := read("cache-branch", nil); err != nil || version != first.String() {
+		t.Fatalf("expected version %s, got %s: %v", first, version, err)
+	}
+	branchClient := newClient("cache-branch", nil)
+	if version, err := version(branchClient); err != nil || version != first.String() {
+		t.Fatalf("expected version %s, got %s: %v", first, version, err)
+	}
+
+	second := repo.commit(map[string][]byte{"other.json": fixture(t, "secrets.json")})
+	if version, err := version(branchClient); err != nil || version != first.String() {
+		t.Errorf("expected client to keep reading version %s, got %s: %v", first, version, err)
+	}
+	if version, err := read("cache-branch", nil); err != nil || version != second.String() {
+		t.Errorf("expected branch to be fetched at version %s, got %s: %v", second, version, err)
+	}
+	if n := commits("cache-branch"); n != 1 {
+		t.Errorf("expected repository of branch to hold 1 commit, got %d", n)
+	}
+	if version, err := read("cache-commit", smmeta.String(first.String())); err != nil || version != first.String() {
+		t.Fatalf("expected version %s, got %s: %v", first, version, err)
+	}
+	if n := commits("cache-commit"); n != 1 {
+		t.Errorf("expected repository of commit to hold 1 commit, got %d", n)
+	}
+
+	if err := os.RemoveAll(repo.url); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := read("cache-commit", smmeta.String(first.String())); err != nil || version != first.String() {
+		t.Errorf("expected fetched commit to be read without fetching, got %s: %v", version, err)
+	}
+	if _, err := read("cache-branch", nil); err == nil {
+		t.Error("expected error resolving branch of removed repository, got nil")
+	}
+
+	repositoryCacheLock.Lock()
+	repositoryCache[smv1alpha1.SecretStoreKind+"/default/cache-commit"].lastUsed = time.Now().Add(-repositoryCacheTTL - time.Minute)
+	repositoryCacheLock.Unlock()
+	newClient("cache-branch", nil).cachedRepository()
+	if _, ok := repositoryCache[smv1alpha1.SecretStoreKind+"/default/cache-commit"]; ok {
+		t.Error("expected unused repository to be evicted")
+	}
+	if _, ok := repositoryCache[smv1alpha1.SecretStoreKind+"/default/cache-branch"]; !ok {
+		t.Error("expected used repository to be kept")
+	}
+}
+
+func TestSetAuth(t *testing.T) {
+	clientKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(clientKey)})
+	hostKey := newHostKey(t)
+	otherHostKey := newHostKey(t)
+
+	credentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "git", Namespace: "default"},
+		Data: map[string][]byte{
+			"username":    []byte("deploy-bot"),
+			"token":       []byte("ghp-token\n"),
+			"ssh":         clientPEM,
+			"known_hosts": []byte(knownhosts.Line([]string{"git.example.com"}, hostKey)),
+		},
+	}
+	ref := func(key string) *smmeta.SecretKeySelector {
+		return &smmeta.SecretKeySelector{
+			LocalObjectReference: smmeta.LocalObjectReference{Name: "git"},
+			Key:                  key,
+		}
+	}
+
+	tests := map[string]struct {
+		url          string
+		auth         *smv1alpha1.SopsGitAuth
+		wantUser     string
+		wantPassword string
+		wantSSH      bool
+		wantErr      bool
+	}{
+		"anonymous": {
+			url: "https://git.example.com/org/repo.git",
+		},
+		"password": {
+			url:          "https://git.example.com/org/repo.git",
+			auth:         &smv1alpha1.SopsGitAuth{Password: ref("token")},
+			wantUser:     "git",
+			wantPassword: "ghp-token",
+		},
+		"password with username": {
+			url:          "https://git.example.com/org/repo.git",
+			auth:         &smv1alpha1.SopsGitAuth{Username: ref("username"), Password: ref("token")},
+			wantUser:     "deploy-bot",
+			wantPassword: "ghp-token",
+		},
+		"ssh user from url": {
+			url:      "ssh://deploy@git.example.com/org/repo.git",
+			auth:     &smv1alpha1.SopsGitAuth{SSHPrivateKey: ref("ssh"), KnownHosts: ref("known_hosts")},
+			wantUser: "deploy",
+			wantSSH:  true,
+		},
+		"ssh with username": {
+			url:      "git@git.example.com:org/repo.git",
+			auth:     &smv1alpha1.SopsGitAuth{Username: ref("username"), SSHPrivateKey: ref("ssh"), KnownHosts: ref("known_hosts")},
+			wantUser: "deploy-bot",
+			wantSSH:  true,
+		},
+		"ssh without known hosts": {
+			url:     "ssh://git@git.example.com/org/repo.git",
+			auth:    &smv1alpha1.SopsGitAuth{SSHPrivateKey: ref("ssh")},
+			wantErr: true,
+		},
+		"invalid ssh key": {
+			url:     "ssh://git@git.example.com/org/repo.git",
+			auth:    &smv1alpha1.SopsGitAuth{SSHPrivateKey: ref("token"), KnownHosts: ref("known_hosts")},
+			wantErr: true,
+		},
+		"multiple methods": {
+			url:     "https://git.example.com/org/repo.git",
+			auth:    &smv1alpha1.SopsGitAuth{Password: ref("token"), SSHPrivateKey: ref("ssh")},
+			wantErr: true,
+		},
+		"no method": {
+			url:     "https://git.example.com/org/repo.git",
+			auth:    &smv1alpha1.SopsGitAuth{Username: ref("username")},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := &Sops{
+				kube: fakeclient.NewFakeClientWithScheme(scheme.Scheme, credentials),
+				store: &smv1alpha1.SecretStore{
+					ObjectMeta: metav1.ObjectMeta{Name: "git", Namespace: "default"},
+				},
+				log: ctrl.Log,
+			}
+			err := s.setAuth(context.Background(), &smv1alpha1.SopsGitRepository{URL: tc.url, AuthSecretRef: tc.auth})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			switch auth := s.auth.(type) {
+			case nil:
+				if tc.wantUser != "" {
+					t.Errorf("expected authentication as %q, got none", tc.wantUser)
+				}
+			case *githttp.BasicAuth:
+				if tc.wantSSH || auth.Username != tc.wantUser || auth.Password != tc.wantPassword {
+					t.Errorf("expected basic auth %q:%q, got %q:%q", tc.wantUser, tc.wantPassword, auth.Username, auth.Password)
+				}
+			case *sshPublicKeys:
+				if !tc.wantSSH || auth.User != tc.wantUser {
+					t.Errorf("expected ssh auth as %q, got %q", tc.wantUser, auth.User)
+				}
+				if config, err := auth.ClientConfig(); err != nil || config.Timeout != requestTimeout {
+					t.Errorf("expected ssh dial timeout of %s: %v", requestTimeout, err)
+				}
+				addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22}
+				if err := auth.HostKeyCallback("git.example.com:22", addr, hostKey); err != nil {
+					t.Errorf("expected known host key to be accepted: %v", err)
+				}
+				if err := auth.HostKeyCallback("git.example.com:22", addr, otherHostKey); err == nil {
+					t.Error("expected unknown host key to be rejected")
+				}
+			default:
+				t.Errorf("unexpected auth method %T", auth)
+			}
+		})
+	}
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	hostKey := newHostKey(t)
+	otherHostKey := newHostKey(t)
+	line := func(marker string, hosts []string, key ssh.PublicKey) string {
+		if marker != "" {
+			marker += " "
+		}
+		return marker + knownhosts.Line(hosts, key) + "\n"
+	}
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22}
+
+	tests := map[string]struct {
+		knownHosts string
+		hostname   string
+		key        ssh.PublicKey
+		wantErr    bool
+		parseErr   bool
+	}{
+		"plain host": {
+			knownHosts: line("", []string{"git.example.com"}, hostKey),
+			hostname:   "git.example.com:22",
+			key:        hostKey,
+		},
+		"other key": {
+			knownHosts: line("", []string{"git.example.com"}, hostKey),
+			hostname:   "git.example.com:22",
+			key:        otherHostKey,
+			wantErr:    true,
+		},
+		"other host": {
+			knownHosts: line("", []string{"other.example.com"}, hostKey),
+			hostname:   "git.example.com:22",
+			key:        hostKey,
+			wantErr:    true,
+		},
+		"one of several keys": {
+			knownHosts: line("", []string{"git.example.com"}, otherHostKey) + "# comment\n" + line("", []string{"git.example.com"}, hostKey),
+			hostname:   "git.example.com:22",
+			key:        hostKey,
+		},
+		"non-standard port": {
+			knownHosts: line("", []string{"git.example.com:2222"}, hostKey),
+			hostname:   "git.example.com:2222",
+			key:        hostKey,
+		},
+		"standard port entry for non-standard port": {
+			knownHosts: line("", []string{"git.example.com"}, hostKey),
+			hostname:   "git.example.com:2222",
+			key:        hostKey,
+			wantErr:    true,
+		},
+		"address": {
+			knownHosts: line("", []string{"192.0.2.1"}, hostKey),
+			hostname:   "git.example.com:22",
+			key:        hostKey,
+		},
+		"hashed host": {
+			knownHosts: line("", []string{knownhosts.HashHostname("git.example.com")}, hostKey),
+			hostname:   "git.example.com:22",
+			key:        hostKey,
+		},
+		"hashed other host": {
+			knownHosts: line("", []string{knownhosts.HashHostname("other.example.com")}, hostKey),
+			hostname:   "git.example.com:22",
+			key:        hostKey,
+			wantErr:    true,
+		},
+		"wildcard": {
+			knownHosts: line("", []string{"*.example.co?"}, hostKey),
+			hostname:   "git.example.com:22",
+			key:        hostKey,
+		},
+		"negated host": {
+			knownHosts: line("", []string{"*.example.com", "!git.example.com"}, hostKey),
+			hostname:   "git.example.com:22",
+			key:        hostKey,
+			wantErr:    true,
+		},
+		"revoked key": {
+			knownHosts: line("", []string{"git.example.com"}, hostKey) + line("@revoked", []string{"*"}, hostKey),
+			hostname:   "git.example.com:22",
+			key:        hostKey,
+			wantErr:    true,
+		},
+		"certificate authority": {
+			knownHosts: line("@cert-authority", []string{"*.example.com"}, hostKey),
+			parseErr:   true,
+		},
+		"no host keys": {
+			knownHosts: "# comment\n",
+			parseErr:   true,
+		},
+		"invalid": {
+			knownHosts: "git.example.com ssh-rsa invalid\n",
+			parseErr:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			callback, err := hostKeyCallback(tc.knownHosts)
+			if tc.parseErr {
+				if err == nil {
+					t.Fatal("expected error parsing known hosts, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing known hosts: %v", err)
+			}
+			err = callback(tc.hostname, addr, tc.key)
+			if tc.wantErr && err == nil {
+				t.Error("expected host key to be rejected")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("expected host key to be accepted: %v", err)
+			}
+		})
+	}
+}
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
@@ -15,7 +15,9 @@ limitations under the License.
 package fake
 
 import (
+	"context"
 	"errors"
+	"net/url"
 
 	vault "github.com/hashicorp/vault/api"
 )
@@ -28,7 +30,6 @@ type Client struct {
 
 func NewFakeClient() *Client {
 	return &Client{
-		NewRequestS: new(vault.Request),
 		RawRequestFn: func(r *vault.Request) (*vault.Response, error) {
 			return nil, errors.New("unexpected RawRequest call")
 		},
@@ -47,8 +48,24 @@ func (c *Client) WithRawRequest(resp *vault.Response, err error) *Client {
 	return c
 }
 
+func (c *Client) WithRawRequestFn(fn func(r *vault.Request) (*vault.Response, error)) *Client {
+	c.RawRequestFn = fn
+	return c
+}
+
+// NewRequest returns the request set with WithNewRequest, or a new request
+// for the given method and path if none was set.
 func (c *Client) NewRequest(method, requestPath string) *vault.Request {
-	return c.NewRequestS
+	if c.NewRequestS != nil {
+		return c.NewRequestS
+	}
+	return &vault.Request{
+		Method: method,
+		URL: &url.URL{
+			Path: requestPath,
+		},
+		Params: make(url.Values),
+	}
 }
 
 func (c *Client) SetToken(v string) {
@@ -63,6 +80,10 @@ func (c *Client) RawRequest(r *vault.Request) (*vault.Response, error) {
 	return c.RawRequestFn(r)
 }
 
+func (c *Client) RawRequestWithContext(ctx context.Context, r *vault.Request) (*vault.Response, error) {
+	return c.RawRequestFn(r)
+}
+
 func (c *Client) Sys() *vault.Sys {
 	return nil
 }
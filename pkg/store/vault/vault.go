@@ -16,23 +16,30 @@ package vault
 
 import (
 	"context"
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 
 	vault "github.com/hashicorp/vault/api"
 
-	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
 	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
 	ctxlog "github.com/itscontained/secret-manager/pkg/log"
 	"github.com/itscontained/secret-manager/pkg/store"
 	"github.com/itscontained/secret-manager/pkg/store/schema"
+	"github.com/itscontained/secret-manager/pkg/util/storeref"
 
 	corev1 "k8s.io/api/core/v1"
 
@@ -42,6 +49,7 @@ import (
 )
 
 var _ store.Client = &Vault{}
+var _ store.MetadataClient = &Vault{}
 
 type Client interface {
 	NewRequest(method, requestPath string) *vault.Request
@@ -56,8 +64,28 @@ type Vault struct {
 	namespace string
 	log       logr.Logger
 	client    Client
+	// kvVersion is the KV engine version of the store, which is detected on
+	// the first read of the KV engine if not set.
+	kvVersion smv1alpha1.VaultKVStoreVersion
 }
 
+// kvVersionCacheTTL is how long the detected KV engine version of a store is
+// cached without being used, so that versions of deleted stores are evicted.
+const kvVersionCacheTTL = time.Hour
+
+// kvVersionCacheEntry is the detected KV engine version of a store
+// at a given generation of the store spec.
+type kvVersionCacheEntry struct {
+	generation int64
+	lastUsed   time.Time
+	version    smv1alpha1.VaultKVStoreVersion
+}
+
+var (
+	kvVersionCache     = make(map[string]*kvVersionCacheEntry)
+	kvVersionCacheLock sync.Mutex
+)
+
 func init() {
 	schema.Register(&Vault{}, &smv1alpha1.SecretStoreSpec{
 		Vault: &smv1alpha1.VaultStore{},
@@ -73,7 +101,7 @@ func (v *Vault) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctr
 		log:       log,
 	}
 
-	cfg, err := vClient.newConfig()
+	cfg, err := vClient.newConfig(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -97,14 +125,41 @@ func (v *Vault) New(ctx context.Context, store smv1alpha1.GenericStore, kube ctr
 }
 
 func (v *Vault) GetSecret(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, error) {
+	data, _, err := v.GetSecretWithMetadata(ctx, ref)
+	return data, err
+}
+
+func (v *Vault) GetSecretWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) ([]byte, *store.SecretMetadata, error) {
+	if ref.Find != nil {
+		return nil, nil, fmt.Errorf("find is only supported in dataFrom references")
+	}
+	if ref.Transit != nil {
+		plaintext, err := v.decryptTransit(ctx, ref.Name, ref.Transit)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ref.Property == nil {
+			return plaintext, nil, nil
+		}
+		data, err := parsePlaintextMap(plaintext)
+		if err != nil {
+			return nil, nil, err
+		}
+		value, exists := data[*ref.Property]
+		if !exists {
+			return nil, nil, fmt.Errorf("property %q not found in decrypted plaintext", *ref.Property)
+		}
+		return value, nil, nil
+	}
+
 	version := ""
 	if ref.Version != nil {
 		version = *ref.Version
 	}
 
-	data, err := v.readSecret(ctx, ref.Name, version)
+	data, metadata, err := v.readSecret(ctx, ref.Name, version)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	property := ""
 	if ref.Property != nil {
@@ -112,12 +167,33 @@ func (v *Vault) GetSecret(ctx context.Context, ref smv1alpha1.RemoteReference) (
 	}
 	value, exists := data[property]
 	if !exists {
-		return nil, fmt.Errorf("property %q not found in secret response", property)
+		return nil, nil, fmt.Errorf("property %q not found in secret response", property)
 	}
-	return value, nil
+	return value, metadata, nil
 }
 
 func (v *Vault) GetSecretMap(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, error) {
+	data, _, err := v.GetSecretMapWithMetadata(ctx, ref)
+	return data, err
+}
+
+func (v *Vault) GetSecretMapWithMetadata(ctx context.Context, ref smv1alpha1.RemoteReference) (map[string][]byte, *store.SecretMetadata, error) {
+	if ref.Transit != nil {
+		plaintext, err := v.decryptTransit(ctx, ref.Name, ref.Transit)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := parsePlaintextMap(plaintext)
+		return data, nil, err
+	}
+	if ref.Find != nil {
+		if ref.Version != nil {
+			return nil, nil, fmt.Errorf("version is not supported when finding secrets")
+		}
+		data, err := v.findSecrets(ctx, ref.Name, ref.Find)
+		return data, nil, err
+	}
+
 	version := ""
 	if ref.Version != nil {
 		version = *ref.Version
@@ -126,16 +202,14 @@ func (v *Vault) GetSecretMap(ctx context.Context, ref smv1alpha1.RemoteReference
 	return v.readSecret(ctx, ref.Name, version)
 }
 
-func (v *Vault) readSecret(ctx context.Context, path, version string) (map[string][]byte, error) {
-	storeSpec := v.store.GetSpec()
-	kvPath := storeSpec.Vault.Path
-
-	kvVersion := smv1alpha1.DefaultVaultKVEngineVersion
-	if storeSpec.Vault.Version != nil {
-		kvVersion = *storeSpec.Vault.Version
+func (v *Vault) readSecret(ctx context.Context, path, version string) (map[string][]byte, *store.SecretMetadata, error) {
+	kvPath := v.store.GetSpec().Vault.Path
+	kvVersion, err := v.kv(ctx)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if kvVersion == smv1alpha1.DefaultVaultKVEngineVersion {
+	if kvVersion == smv1alpha1.VaultKVStoreV2 {
 		if !strings.HasSuffix(kvPath, "/data") {
 			kvPath = fmt.Sprintf("%s/data", kvPath)
 		}
@@ -148,23 +222,29 @@ func (v *Vault) readSecret(ctx context.Context, path, version string) (map[strin
 
 	resp, err := v.client.RawRequestWithContext(ctx, req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	defer resp.Body.Close()
 	vaultSecret, err := vault.ParseSecret(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	secretData := vaultSecret.Data
-	if kvVersion == smv1alpha1.DefaultVaultKVEngineVersion {
+	var metadata *store.SecretMetadata
+	if kvVersion == smv1alpha1.VaultKVStoreV2 {
 		dataInt, ok := vaultSecret.Data["data"]
 		if !ok {
-			return nil, fmt.Errorf("unexpected secret data response")
+			return nil, nil, fmt.Errorf("unexpected secret data response")
 		}
 		secretData, ok = dataInt.(map[string]interface{})
 		if !ok {
-			return nil, fmt.Errorf("unexpected secret data format")
+			return nil, nil, fmt.Errorf("unexpected secret data format")
+		}
+		metadata, err = parseKVMetadata(vaultSecret.Data["metadata"])
+		if err != nil {
+			return nil, nil, err
 		}
 	}
 
@@ -172,30 +252,384 @@ func (v *Vault) readSecret(ctx context.Context, path, version string) (map[strin
 	for k, v := range secretData {
 		str, ok := v.(string)
 		if !ok {
-			return nil, fmt.Errorf("unexpected secret type")
+			return nil, nil, fmt.Errorf("unexpected secret type")
 		}
 		byteMap[k] = []byte(str)
 	}
 
-	return byteMap, nil
+	return byteMap, metadata, nil
 }
 
-func (v *Vault) newConfig() (*vault.Config, error) {
-	cfg := vault.DefaultConfig()
-	cfg.Address = v.store.GetSpec().Vault.Server
+// findSecrets reads every secret listed below basePath, flattening the path
+// of each secret relative to basePath and its keys into a single map. The
+// metadata of the secrets is dropped, as it cannot be attributed to a single
+// remote secret.
+func (v *Vault) findSecrets(ctx context.Context, basePath string, find *smv1alpha1.FindReference) (map[string][]byte, error) {
+	separator := smv1alpha1.DefaultFindSeparator
+	if find.Separator != nil {
+		separator = *find.Separator
+	}
+
+	// without recursion only the secrets directly below basePath are read
+	maxDepth := 1
+	if find.Recursive {
+		maxDepth = 0
+		if find.MaxDepth != nil {
+			maxDepth = int(*find.MaxDepth)
+		}
+	}
+
+	basePath = strings.Trim(basePath, "/")
+	secretPaths, err := v.listSecrets(ctx, basePath, "", 1, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(secretPaths)
+
+	secretMap := make(map[string][]byte)
+	for _, secretPath := range secretPaths {
+		data, _, err := v.readSecret(ctx, path.Join(basePath, secretPath), "")
+		if err != nil {
+			return nil, fmt.Errorf("error reading secret %q: %w", path.Join(basePath, secretPath), err)
+		}
+		prefix := strings.ReplaceAll(secretPath, "/", separator)
+		for k, value := range data {
+			secretMap[prefix+separator+k] = value
+		}
+	}
+
+	return secretMap, nil
+}
+
+// listSecrets returns the paths of the secrets below relPath, relative to
+// basePath, descending into nested paths until maxDepth is reached. A
+// maxDepth of 0 does not limit the depth.
+func (v *Vault) listSecrets(ctx context.Context, basePath, relPath string, depth, maxDepth int) ([]string, error) {
+	keys, err := v.listKeys(ctx, path.Join(basePath, relPath))
+	if err != nil {
+		return nil, err
+	}
+
+	var secretPaths []string
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/") {
+			if maxDepth > 0 && depth >= maxDepth {
+				continue
+			}
+			nested, err := v.listSecrets(ctx, basePath, path.Join(relPath, key), depth+1, maxDepth)
+			if err != nil {
+				return nil, err
+			}
+			secretPaths = append(secretPaths, nested...)
+			continue
+		}
+		secretPaths = append(secretPaths, path.Join(relPath, key))
+	}
+
+	return secretPaths, nil
+}
+
+// listKeys lists the keys at the given path of the KV engine. Keys ending
+// with a "/" denote nested paths.
+func (v *Vault) listKeys(ctx context.Context, listPath string) ([]string, error) {
+	kvPath := v.store.GetSpec().Vault.Path
+	kvVersion, err := v.kv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if kvVersion == smv1alpha1.VaultKVStoreV2 {
+		kvPath = fmt.Sprintf("%s/metadata", strings.TrimSuffix(kvPath, "/data"))
+	}
+
+	req := v.client.NewRequest(http.MethodGet, strings.TrimSuffix(fmt.Sprintf("/v1/%s/%s", kvPath, listPath), "/"))
+	req.Params.Set("list", "true")
+
+	resp, err := v.client.RawRequestWithContext(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("error listing secrets at %q: %w", listPath, err)
+	}
+
+	defer resp.Body.Close()
+	vaultSecret, err := vault.ParseSecret(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if vaultSecret == nil || vaultSecret.Data == nil {
+		return nil, nil
+	}
+
+	keysInt, ok := vaultSecret.Data["keys"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected list response format")
+	}
 
-	certs := v.store.GetSpec().Vault.CABundle
-	if len(certs) == 0 {
-		return cfg, nil
+	keys := make([]string, 0, len(keysInt))
+	for _, keyInt := range keysInt {
+		key, ok := keyInt.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected list key type")
+		}
+		keys = append(keys, key)
 	}
 
-	caCertPool := x509.NewCertPool()
-	ok := caCertPool.AppendCertsFromPEM(certs)
+	return keys, nil
+}
+
+// parseKVMetadata parses the metadata returned with a KV v2 secret, which
+// contains the version of the secret and optionally its custom metadata.
+func parseKVMetadata(metadataInt interface{}) (*store.SecretMetadata, error) {
+	if metadataInt == nil {
+		return nil, nil
+	}
+	metadataMap, ok := metadataInt.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("error loading Vault CA bundle")
+		return nil, fmt.Errorf("unexpected secret metadata format")
+	}
+
+	metadata := &store.SecretMetadata{}
+	if version, ok := metadataMap["version"]; ok && version != nil {
+		metadata.Version = fmt.Sprint(version)
+	}
+
+	customMetadataInt, ok := metadataMap["custom_metadata"].(map[string]interface{})
+	if ok {
+		metadata.CustomMetadata = make(map[string]string, len(customMetadataInt))
+		for k, v := range customMetadataInt {
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected custom metadata type for key %q", k)
+			}
+			metadata.CustomMetadata[k] = str
+		}
+	}
+
+	return metadata, nil
+}
+
+// decryptTransit decrypts the ciphertext of the reference with the Transit
+// secrets engine key given by name.
+func (v *Vault) decryptTransit(ctx context.Context, name string, transit *smv1alpha1.VaultTransitReference) ([]byte, error) {
+	ciphertext, err := v.transitCiphertext(ctx, transit)
+	if err != nil {
+		return nil, err
+	}
+
+	transitPath := transit.Path
+	if transitPath == "" {
+		transitPath = smv1alpha1.DefaultVaultTransitMountPath
 	}
 
-	cfg.HttpClient.Transport.(*http.Transport).TLSClientConfig.RootCAs = caCertPool
+	url := strings.Join([]string{"/v1", transitPath, "decrypt", name}, "/")
+	request := v.client.NewRequest(http.MethodPost, url)
+	err = request.SetJSONBody(map[string]string{
+		"ciphertext": ciphertext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding Vault parameters: %s", err.Error())
+	}
+
+	resp, err := v.client.RawRequestWithContext(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting ciphertext with Vault Transit key %q: %w", name, err)
+	}
+
+	defer resp.Body.Close()
+	vaultResult, err := vault.ParseSecret(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode JSON payload: %s", err.Error())
+	}
+	if vaultResult == nil || vaultResult.Data == nil {
+		return nil, fmt.Errorf("unexpected decrypt response")
+	}
+
+	plaintext, ok := vaultResult.Data["plaintext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected decrypt response format")
+	}
+
+	return base64.StdEncoding.DecodeString(plaintext)
+}
+
+func (v *Vault) transitCiphertext(ctx context.Context, transit *smv1alpha1.VaultTransitReference) (string, error) {
+	if transit.Ciphertext != nil && transit.CiphertextFrom != nil {
+		return "", fmt.Errorf("only one of ciphertext or ciphertextFrom may be specified")
+	}
+	if transit.Ciphertext != nil {
+		return strings.TrimSpace(*transit.Ciphertext), nil
+	}
+	if transit.CiphertextFrom == nil {
+		return "", fmt.Errorf("one of ciphertext or ciphertextFrom must be specified")
+	}
+
+	configMap := &corev1.ConfigMap{}
+	ref := types.NamespacedName{
+		Namespace: v.namespace,
+		Name:      transit.CiphertextFrom.Name,
+	}
+	if err := v.kube.Get(ctx, ref, configMap); err != nil {
+		return "", err
+	}
+
+	ciphertext, ok := configMap.Data[transit.CiphertextFrom.Key]
+	if !ok {
+		return "", fmt.Errorf("no data for %q in configmap '%s/%s'", transit.CiphertextFrom.Key, ref.Namespace, ref.Name)
+	}
+
+	return strings.TrimSpace(ciphertext), nil
+}
+
+// parsePlaintextMap parses decrypted plaintext holding a JSON object of
+// string values.
+func parsePlaintextMap(plaintext []byte) (map[string][]byte, error) {
+	plaintextMap := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &plaintextMap); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal decrypted plaintext: %w", err)
+	}
+
+	byteMap := make(map[string][]byte, len(plaintextMap))
+	for k, v := range plaintextMap {
+		byteMap[k] = []byte(v)
+	}
+
+	return byteMap, nil
+}
+
+// kv returns the KV engine version of the store, detecting it on first use.
+func (v *Vault) kv(ctx context.Context) (smv1alpha1.VaultKVStoreVersion, error) {
+	if v.kvVersion == "" {
+		version, err := v.getKVVersion(ctx)
+		if err != nil {
+			return "", err
+		}
+		v.kvVersion = version
+	}
+	return v.kvVersion, nil
+}
+
+// getKVVersion returns the KV engine version configured in the store spec.
+// If unset, the version is detected from the mount of the store path and
+// cached until the store spec changes or it is not used for
+// kvVersionCacheTTL.
+func (v *Vault) getKVVersion(ctx context.Context) (smv1alpha1.VaultKVStoreVersion, error) {
+	if version := v.store.GetSpec().Vault.Version; version != nil {
+		return *version, nil
+	}
+
+	cacheKey := fmt.Sprintf("%s/%s/%s", v.store.GetTypeMeta().Kind, v.store.GetNamespace(), v.store.GetName())
+	now := time.Now()
+	kvVersionCacheLock.Lock()
+	for key, entry := range kvVersionCache {
+		if now.Sub(entry.lastUsed) > kvVersionCacheTTL {
+			delete(kvVersionCache, key)
+		}
+	}
+	entry, ok := kvVersionCache[cacheKey]
+	if ok && entry.generation == v.store.GetGeneration() {
+		entry.lastUsed = now
+		kvVersionCacheLock.Unlock()
+		return entry.version, nil
+	}
+	kvVersionCacheLock.Unlock()
+
+	version, err := v.detectKVVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	kvVersionCacheLock.Lock()
+	kvVersionCache[cacheKey] = &kvVersionCacheEntry{
+		generation: v.store.GetGeneration(),
+		lastUsed:   now,
+		version:    version,
+	}
+	kvVersionCacheLock.Unlock()
+
+	return version, nil
+}
+
+func (v *Vault) detectKVVersion(ctx context.Context) (smv1alpha1.VaultKVStoreVersion, error) {
+	mountPath := strings.TrimSuffix(v.store.GetSpec().Vault.Path, "/data")
+	req := v.client.NewRequest(http.MethodGet, fmt.Sprintf("/v1/sys/internal/ui/mounts/%s", mountPath))
+
+	resp, err := v.client.RawRequestWithContext(ctx, req)
+	if err != nil {
+		var respErr *vault.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusForbidden {
+			return "", fmt.Errorf("not permitted to read Vault mount %q to detect its KV engine version, set spec.vault.version: %w", mountPath, err)
+		}
+		return "", fmt.Errorf("error detecting KV engine version of Vault mount %q: %w", mountPath, err)
+	}
+
+	defer resp.Body.Close()
+	mount, err := vault.ParseSecret(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error parsing Vault mount %q: %w", mountPath, err)
+	}
+	if mount == nil || mount.Data == nil {
+		return "", fmt.Errorf("no mount information returned for Vault path %q", mountPath)
+	}
+
+	mountType, _ := mount.Data["type"].(string)
+	if mountType != "kv" && mountType != "generic" {
+		return "", fmt.Errorf("vault path %q is not a KV secrets engine mount, found mount type %q", mountPath, mountType)
+	}
+
+	options, _ := mount.Data["options"].(map[string]interface{})
+	if version, _ := options["version"].(string); version == "2" {
+		return smv1alpha1.VaultKVStoreV2, nil
+	}
+
+	return smv1alpha1.VaultKVStoreV1, nil
+}
+
+func (v *Vault) newConfig(ctx context.Context) (*vault.Config, error) {
+	cfg := vault.DefaultConfig()
+	storeSpec := v.store.GetSpec().Vault
+	cfg.Address = storeSpec.Server
+
+	tlsConfig := cfg.HttpClient.Transport.(*http.Transport).TLSClientConfig
+
+	if len(storeSpec.CABundle) != 0 || storeSpec.CAProvider != nil {
+		caCertPool := x509.NewCertPool()
+
+		if len(storeSpec.CABundle) != 0 {
+			ok := caCertPool.AppendCertsFromPEM(storeSpec.CABundle)
+			if !ok {
+				return nil, fmt.Errorf("error loading Vault CA bundle")
+			}
+		}
+
+		if storeSpec.CAProvider != nil {
+			namespace := storeref.DefaultNamespace(v.store, storeSpec.CAProvider.Namespace, v.namespace)
+			certs, err := storeref.CAProviderData(ctx, v.kube, namespace, storeSpec.CAProvider)
+			if err != nil {
+				return nil, fmt.Errorf("error reading Vault CA provider: %w", err)
+			}
+			ok := caCertPool.AppendCertsFromPEM(certs)
+			if !ok {
+				return nil, fmt.Errorf("error loading Vault CA provider %s %q", storeSpec.CAProvider.Type, storeSpec.CAProvider.Name)
+			}
+		}
+
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	if storeSpec.TLSClientCert != nil {
+		certRef, keyRef := storeSpec.TLSClientCert.CertSecretRef, storeSpec.TLSClientCert.KeySecretRef
+		certPEM, err := storeref.SecretKeyIn(ctx, v.kube, storeref.DefaultNamespace(v.store, certRef.Namespace, v.namespace), certRef)
+		if err != nil {
+			return nil, fmt.Errorf("error reading Vault client certificate: %w", err)
+		}
+		keyPEM, err := storeref.SecretKeyIn(ctx, v.kube, storeref.DefaultNamespace(v.store, keyRef.Namespace, v.namespace), keyRef)
+		if err != nil {
+			return nil, fmt.Errorf("error reading Vault client certificate key: %w", err)
+		}
+		clientCert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("error loading Vault client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
 
 	return cfg, nil
 }
@@ -203,7 +637,7 @@ func (v *Vault) newConfig() (*vault.Config, error) {
 func (v *Vault) setToken(ctx context.Context, client Client) error {
 	tokenRef := v.store.GetSpec().Vault.Auth.TokenSecretRef
 	if tokenRef != nil {
-		token, err := v.secretKeyRef(ctx, tokenRef)
+		token, err := storeref.SecretKeyIn(ctx, v.kube, storeref.DefaultNamespace(v.store, tokenRef.Namespace, v.namespace), *tokenRef)
 		if err != nil {
 			return err
 		}
@@ -236,35 +670,11 @@ func (v *Vault) setToken(ctx context.Context, client Client) error {
 	return fmt.Errorf("error initializing Vault client: tokenSecretRef, appRoleSecretRef, or Kubernetes auth role not set")
 }
 
-func (v *Vault) secretKeyRef(ctx context.Context, secretRef *smmeta.SecretKeySelector) (string, error) {
-	secret := &corev1.Secret{}
-	ref := types.NamespacedName{
-		Namespace: v.namespace,
-		Name:      secretRef.Name,
-	}
-	if (v.store.GetTypeMeta().Kind == smv1alpha1.ClusterSecretStoreKind) && (secretRef.Namespace != nil) {
-		ref.Namespace = *secretRef.Namespace
-	}
-	err := v.kube.Get(ctx, ref, secret)
-	if err != nil {
-		return "", err
-	}
-
-	keyBytes, ok := secret.Data[secretRef.Key]
-	if !ok {
-		return "", fmt.Errorf("no data for %q in secret '%s/%s'", secretRef.Key, ref.Namespace, secretRef.Name)
-	}
-
-	value := string(keyBytes)
-	valueStr := strings.TrimSpace(value)
-
-	return valueStr, nil
-}
-
 func (v *Vault) requestTokenWithAppRoleRef(ctx context.Context, client Client, appRole *smv1alpha1.VaultAppRole) (string, error) {
 	roleID := strings.TrimSpace(appRole.RoleID)
 
-	secretID, err := v.secretKeyRef(ctx, &appRole.SecretRef)
+	secretRef := appRole.SecretRef
+	secretID, err := storeref.SecretKeyIn(ctx, v.kube, storeref.DefaultNamespace(v.store, secretRef.Namespace, v.namespace), secretRef)
 	if err != nil {
 		return "", err
 	}
@@ -330,7 +740,7 @@ func (v *Vault) requestTokenWithKubernetesAuth(ctx context.Context, client Clien
 			tokenRef = kubernetesAuth.SecretRef.DeepCopy()
 			tokenRef.Key = "token"
 		}
-		jwt, err = v.secretKeyRef(ctx, tokenRef)
+		jwt, err = storeref.SecretKeyIn(ctx, v.kube, storeref.DefaultNamespace(v.store, tokenRef.Namespace, v.namespace), *tokenRef)
 		if err != nil {
 			return "", err
 		}
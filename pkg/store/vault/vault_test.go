@@ -0,0 +1,648 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vault
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+	"github.com/itscontained/secret-manager/pkg/store"
+	"github.com/itscontained/secret-manager/pkg/store/vault/fake"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"k8s.io/client-go/kubernetes/scheme"
+
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ Client = &fake.Client{}
+
+func newTestStore(spec *smv1alpha1.VaultStore) *smv1alpha1.SecretStore {
+	return &smv1alpha1.SecretStore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vault-store",
+			Namespace: "default",
+		},
+		Spec: smv1alpha1.SecretStoreSpec{
+			Vault: spec,
+		},
+	}
+}
+
+func newTestVault(store smv1alpha1.GenericStore, objs ...runtime.Object) *Vault {
+	return &Vault{
+		kube:      fakeclient.NewFakeClientWithScheme(scheme.Scheme, objs...),
+		store:     store,
+		namespace: "default",
+		log:       log.Log,
+	}
+}
+
+func newVaultResponse(statusCode int, body string) *vault.Response {
+	return &vault.Response{
+		Response: &http.Response{
+			StatusCode: statusCode,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		},
+	}
+}
+
+func newTestCertificate(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "secret-manager-test"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error marshaling key: %v", err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	return certPEM, keyPEM
+}
+
+func TestNewConfigTLS(t *testing.T) {
+	certPEM, keyPEM := newTestCertificate(t)
+
+	tlsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vault-tls", Namespace: "default"},
+		Data: map[string][]byte{
+			"ca.crt":  certPEM,
+			"tls.crt": certPEM,
+			"tls.key": keyPEM,
+		},
+	}
+	caConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "vault-ca", Namespace: "default"},
+		Data: map[string]string{
+			"ca.crt": string(certPEM),
+		},
+	}
+
+	tests := map[string]struct {
+		spec       *smv1alpha1.VaultStore
+		wantErr    bool
+		wantCAs    bool
+		wantClient bool
+	}{
+		"no tls configuration": {
+			spec: &smv1alpha1.VaultStore{Server: "https://vault.example.com"},
+		},
+		"ca from secret": {
+			spec: &smv1alpha1.VaultStore{
+				Server: "https://vault.example.com",
+				CAProvider: &smv1alpha1.CAProvider{
+					Type: smv1alpha1.CAProviderTypeSecret,
+					Name: "vault-tls",
+					Key:  "ca.crt",
+				},
+			},
+			wantCAs: true,
+		},
+		"ca from configmap": {
+			spec: &smv1alpha1.VaultStore{
+				Server: "https://vault.example.com",
+				CAProvider: &smv1alpha1.CAProvider{
+					Type: smv1alpha1.CAProviderTypeConfigMap,
+					Name: "vault-ca",
+					Key:  "ca.crt",
+				},
+			},
+			wantCAs: true,
+		},
+		"ca key missing": {
+			spec: &smv1alpha1.VaultStore{
+				Server: "https://vault.example.com",
+				CAProvider: &smv1alpha1.CAProvider{
+					Type: smv1alpha1.CAProviderTypeConfigMap,
+					Name: "vault-ca",
+					Key:  "missing",
+				},
+			},
+			wantErr: true,
+		},
+		"client certificate": {
+			spec: &smv1alpha1.VaultStore{
+				Server: "https://vault.example.com",
+				TLSClientCert: &smv1alpha1.VaultClientCertificate{
+					CertSecretRef: smmeta.SecretKeySelector{
+						LocalObjectReference: smmeta.LocalObjectReference{Name: "vault-tls"},
+						Key:                  "tls.crt",
+					},
+					KeySecretRef: smmeta.SecretKeySelector{
+						LocalObjectReference: smmeta.LocalObjectReference{Name: "vault-tls"},
+						Key:                  "tls.key",
+					},
+				},
+			},
+			wantClient: true,
+		},
+		"client certificate key mismatch": {
+			spec: &smv1alpha1.VaultStore{
+				Server: "https://vault.example.com",
+				TLSClientCert: &smv1alpha1.VaultClientCertificate{
+					CertSecretRef: smmeta.SecretKeySelector{
+						LocalObjectReference: smmeta.LocalObjectReference{Name: "vault-tls"},
+						Key:                  "tls.crt",
+					},
+					KeySecretRef: smmeta.SecretKeySelector{
+						LocalObjectReference: smmeta.LocalObjectReference{Name: "vault-tls"},
+						Key:                  "tls.crt",
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVault(newTestStore(tc.spec), tlsSecret, caConfigMap)
+			cfg, err := v.newConfig(context.Background())
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tlsConfig := cfg.HttpClient.Transport.(*http.Transport).TLSClientConfig
+			if gotCAs := tlsConfig.RootCAs != nil; gotCAs != tc.wantCAs {
+				t.Errorf("expected root CAs set to be %t, got %t", tc.wantCAs, gotCAs)
+			}
+			if gotClient := len(tlsConfig.Certificates) == 1; gotClient != tc.wantClient {
+				t.Errorf("expected client certificate set to be %t, got %t", tc.wantClient, gotClient)
+			}
+		})
+	}
+}
+
+func TestGetKVVersion(t *testing.T) {
+	tests := map[string]struct {
+		version  *smv1alpha1.VaultKVStoreVersion
+		response *vault.Response
+		err      error
+		want     smv1alpha1.VaultKVStoreVersion
+		wantErr  bool
+	}{
+		"version set in store": {
+			version: func() *smv1alpha1.VaultKVStoreVersion { v := smv1alpha1.VaultKVStoreV1; return &v }(),
+			want:    smv1alpha1.VaultKVStoreV1,
+		},
+		"kv v2 mount": {
+			response: newVaultResponse(http.StatusOK, `{"data": {"path": "secret/", "type": "kv", "options": {"version": "2"}}}`),
+			want:     smv1alpha1.VaultKVStoreV2,
+		},
+		"kv v1 mount": {
+			response: newVaultResponse(http.StatusOK, `{"data": {"path": "secret/", "type": "kv", "options": {"version": "1"}}}`),
+			want:     smv1alpha1.VaultKVStoreV1,
+		},
+		"generic mount": {
+			response: newVaultResponse(http.StatusOK, `{"data": {"path": "secret/", "type": "generic", "options": null}}`),
+			want:     smv1alpha1.VaultKVStoreV1,
+		},
+		"not a kv mount": {
+			response: newVaultResponse(http.StatusOK, `{"data": {"path": "transit/", "type": "transit", "options": null}}`),
+			wantErr:  true,
+		},
+		"permission denied": {
+			err:     &vault.ResponseError{StatusCode: http.StatusForbidden},
+			wantErr: true,
+		},
+		"server error": {
+			err:     &vault.ResponseError{StatusCode: http.StatusInternalServerError},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := newTestStore(&smv1alpha1.VaultStore{
+				Server:  "https://vault.example.com",
+				Path:    "secret",
+				Version: tc.version,
+			})
+			store.Name = strings.ReplaceAll(name, " ", "-")
+			v := newTestVault(store)
+			v.client = fake.NewFakeClient().WithRawRequestFn(func(r *vault.Request) (*vault.Response, error) {
+				if r.URL.Path != "/v1/sys/internal/ui/mounts/secret" {
+					t.Errorf("unexpected request path %q", r.URL.Path)
+				}
+				return tc.response, tc.err
+			})
+
+			got, err := v.getKVVersion(context.Background())
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected version %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestGetKVVersionCache(t *testing.T) {
+	store := newTestStore(&smv1alpha1.VaultStore{
+		Server: "https://vault.example.com",
+		Path:   "secret",
+	})
+	store.Name = "cached"
+	v := newTestVault(store)
+
+	requests := 0
+	v.client = fake.NewFakeClient().WithRawRequestFn(func(r *vault.Request) (*vault.Response, error) {
+		requests++
+		return newVaultResponse(http.StatusOK, `{"data": {"type": "kv", "options": {"version": "1"}}}`), nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := v.getKVVersion(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected mount to be read once, got %d requests", requests)
+	}
+
+	store.Generation++
+	if _, err := v.getKVVersion(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected mount to be read again after store change, got %d requests", requests)
+	}
+
+	kvVersionCacheLock.Lock()
+	kvVersionCache["SecretStore/default/cached"].lastUsed = time.Now().Add(-kvVersionCacheTTL - time.Minute)
+	kvVersionCacheLock.Unlock()
+	if _, err := v.getKVVersion(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("expected mount to be read again after unused version was evicted, got %d requests", requests)
+	}
+}
+
+func TestGetKVVersionErrorNotCached(t *testing.T) {
+	store := newTestStore(&smv1alpha1.VaultStore{
+		Server: "https://vault.example.com",
+		Path:   "secret",
+	})
+	store.Name = "denied"
+	v := newTestVault(store)
+
+	requests := 0
+	v.client = fake.NewFakeClient().WithRawRequestFn(func(r *vault.Request) (*vault.Response, error) {
+		requests++
+		return nil, &vault.ResponseError{StatusCode: http.StatusForbidden}
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err := v.getKVVersion(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "spec.vault.version") {
+			t.Fatalf("expected error asking to set spec.vault.version, got %v", err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("expected mount to be read again after an error, got %d requests", requests)
+	}
+}
+
+func TestReadSecretDetectsKVVersion(t *testing.T) {
+	store := newTestStore(&smv1alpha1.VaultStore{
+		Server: "https://vault.example.com",
+		Path:   "secret",
+	})
+	store.Name = "lazy"
+	v := newTestVault(store)
+
+	var paths []string
+	v.client = fake.NewFakeClient().WithRawRequestFn(func(r *vault.Request) (*vault.Response, error) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/v1/sys/internal/ui/mounts/secret" {
+			return newVaultResponse(http.StatusOK, `{"data": {"type": "kv", "options": {"version": "2"}}}`), nil
+		}
+		return newVaultResponse(http.StatusOK, `{"data": {"data": {"username": "bob"}, "metadata": {"version": 1}}}`), nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := v.readSecret(context.Background(), "foo", ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	want := []string{"/v1/sys/internal/ui/mounts/secret", "/v1/secret/data/foo", "/v1/secret/data/foo"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("expected requests %v, got %v", want, paths)
+	}
+}
+
+func TestReadSecret(t *testing.T) {
+	tests := map[string]struct {
+		kvVersion    smv1alpha1.VaultKVStoreVersion
+		body         string
+		wantPath     string
+		wantData     map[string][]byte
+		wantMetadata *store.SecretMetadata
+		wantErr      bool
+	}{
+		"kv v1": {
+			kvVersion: smv1alpha1.VaultKVStoreV1,
+			body:      `{"data": {"username": "bob"}}`,
+			wantPath:  "/v1/secret/foo",
+			wantData:  map[string][]byte{"username": []byte("bob")},
+		},
+		"kv v2 with metadata": {
+			kvVersion: smv1alpha1.VaultKVStoreV2,
+			body: `{"data": {"data": {"username": "bob"}, "metadata": {"version": 3, "created_time": "2020-11-01T00:00:00Z",
+				"custom_metadata": {"owner": "team-a"}}}}`,
+			wantPath: "/v1/secret/data/foo",
+			wantData: map[string][]byte{"username": []byte("bob")},
+			wantMetadata: &store.SecretMetadata{
+				Version:        "3",
+				CustomMetadata: map[string]string{"owner": "team-a"},
+			},
+		},
+		"kv v2 without custom metadata": {
+			kvVersion: smv1alpha1.VaultKVStoreV2,
+			body:      `{"data": {"data": {"username": "bob"}, "metadata": {"version": 1, "custom_metadata": null}}}`,
+			wantPath:  "/v1/secret/data/foo",
+			wantData:  map[string][]byte{"username": []byte("bob")},
+			wantMetadata: &store.SecretMetadata{
+				Version: "1",
+			},
+		},
+		"kv v2 unexpected response": {
+			kvVersion: smv1alpha1.VaultKVStoreV2,
+			body:      `{"data": {"username": "bob"}}`,
+			wantErr:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVault(newTestStore(&smv1alpha1.VaultStore{
+				Server: "https://vault.example.com",
+				Path:   "secret",
+			}))
+			v.kvVersion = tc.kvVersion
+			v.client = fake.NewFakeClient().WithRawRequestFn(func(r *vault.Request) (*vault.Response, error) {
+				if tc.wantPath != "" && r.URL.Path != tc.wantPath {
+					t.Errorf("expected request path %q, got %q", tc.wantPath, r.URL.Path)
+				}
+				return newVaultResponse(http.StatusOK, tc.body), nil
+			})
+
+			data, metadata, err := v.readSecret(context.Background(), "foo", "")
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(data, tc.wantData) {
+				t.Errorf("expected data %v, got %v", tc.wantData, data)
+			}
+			if !reflect.DeepEqual(metadata, tc.wantMetadata) {
+				t.Errorf("expected metadata %+v, got %+v", tc.wantMetadata, metadata)
+			}
+		})
+	}
+}
+
+func TestDecryptTransit(t *testing.T) {
+	const ciphertext = "vault:v1:8SDd3WHDOjf7mq69CyCqYjBXAiQQAVZRkFM13ok481zoCmHnSeDX9vyf7w=="
+
+	ciphertextConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "ciphertexts", Namespace: "default"},
+		Data: map[string]string{
+			"password": ciphertext + "\n",
+		},
+	}
+
+	tests := map[string]struct {
+		ref       smv1alpha1.RemoteReference
+		plaintext string
+		want      []byte
+		wantErr   bool
+	}{
+		"inline ciphertext": {
+			ref: smv1alpha1.RemoteReference{
+				Name: "my-key",
+				Transit: &smv1alpha1.VaultTransitReference{
+					Ciphertext: smmeta.String(ciphertext),
+				},
+			},
+			plaintext: "hunter2",
+			want:      []byte("hunter2"),
+		},
+		"ciphertext from configmap": {
+			ref: smv1alpha1.RemoteReference{
+				Name: "my-key",
+				Transit: &smv1alpha1.VaultTransitReference{
+					CiphertextFrom: &smmeta.ConfigMapKeySelector{
+						LocalObjectReference: smmeta.LocalObjectReference{Name: "ciphertexts"},
+						Key:                  "password",
+					},
+				},
+			},
+			plaintext: "hunter2",
+			want:      []byte("hunter2"),
+		},
+		"property of json plaintext": {
+			ref: smv1alpha1.RemoteReference{
+				Name:     "my-key",
+				Property: smmeta.String("password"),
+				Transit: &smv1alpha1.VaultTransitReference{
+					Ciphertext: smmeta.String(ciphertext),
+				},
+			},
+			plaintext: `{"password": "hunter2"}`,
+			want:      []byte("hunter2"),
+		},
+		"no ciphertext": {
+			ref: smv1alpha1.RemoteReference{
+				Name:    "my-key",
+				Transit: &smv1alpha1.VaultTransitReference{},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVault(newTestStore(&smv1alpha1.VaultStore{
+				Server: "https://vault.example.com",
+				Path:   "secret",
+			}), ciphertextConfigMap)
+			v.client = fake.NewFakeClient().WithRawRequestFn(func(r *vault.Request) (*vault.Response, error) {
+				if r.URL.Path != "/v1/transit/decrypt/my-key" {
+					t.Errorf("unexpected request path %q", r.URL.Path)
+				}
+				if !strings.Contains(string(r.BodyBytes), ciphertext) {
+					t.Errorf("expected ciphertext in request body, got %s", r.BodyBytes)
+				}
+				body, _ := json.Marshal(map[string]interface{}{
+					"data": map[string]string{
+						"plaintext": base64.StdEncoding.EncodeToString([]byte(tc.plaintext)),
+					},
+				})
+				return newVaultResponse(http.StatusOK, string(body)), nil
+			})
+
+			got, err := v.GetSecret(context.Background(), tc.ref)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFindSecrets(t *testing.T) {
+	responses := map[string]string{
+		"/v1/secret/metadata/apps":                 `{"data": {"keys": ["db", "nested/"]}}`,
+		"/v1/secret/metadata/apps/nested":          `{"data": {"keys": ["api", "deeper/"]}}`,
+		"/v1/secret/metadata/apps/nested/deeper":   `{"data": {"keys": ["queue"]}}`,
+		"/v1/secret/data/apps/db":                  `{"data": {"data": {"password": "a"}, "metadata": {"version": 1}}}`,
+		"/v1/secret/data/apps/nested/api":          `{"data": {"data": {"token": "b"}, "metadata": {"version": 1}}}`,
+		"/v1/secret/data/apps/nested/deeper/queue": `{"data": {"data": {"url": "c"}, "metadata": {"version": 1}}}`,
+	}
+
+	tests := map[string]struct {
+		find *smv1alpha1.FindReference
+		want map[string][]byte
+	}{
+		"direct secrets only": {
+			find: &smv1alpha1.FindReference{},
+			want: map[string][]byte{
+				"db_password": []byte("a"),
+			},
+		},
+		"recursive": {
+			find: &smv1alpha1.FindReference{Recursive: true},
+			want: map[string][]byte{
+				"db_password":             []byte("a"),
+				"nested_api_token":        []byte("b"),
+				"nested_deeper_queue_url": []byte("c"),
+			},
+		},
+		"recursive with max depth": {
+			find: &smv1alpha1.FindReference{
+				Recursive: true,
+				MaxDepth:  func() *int32 { d := int32(2); return &d }(),
+			},
+			want: map[string][]byte{
+				"db_password":      []byte("a"),
+				"nested_api_token": []byte("b"),
+			},
+		},
+		"custom separator": {
+			find: &smv1alpha1.FindReference{
+				Recursive: true,
+				Separator: smmeta.String("."),
+			},
+			want: map[string][]byte{
+				"db.password":             []byte("a"),
+				"nested.api.token":        []byte("b"),
+				"nested.deeper.queue.url": []byte("c"),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVault(newTestStore(&smv1alpha1.VaultStore{
+				Server: "https://vault.example.com",
+				Path:   "secret",
+			}))
+			v.kvVersion = smv1alpha1.VaultKVStoreV2
+			v.client = fake.NewFakeClient().WithRawRequestFn(func(r *vault.Request) (*vault.Response, error) {
+				body, ok := responses[r.URL.Path]
+				if !ok {
+					return nil, &vault.ResponseError{StatusCode: http.StatusNotFound}
+				}
+				return newVaultResponse(http.StatusOK, body), nil
+			})
+
+			got, err := v.GetSecretMap(context.Background(), smv1alpha1.RemoteReference{
+				Name: "apps/",
+				Find: tc.find,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package property extracts secret values from JSON encoded secrets.
+//
+// String values are returned as is, while numbers, booleans, objects and
+// arrays are returned as compact JSON.
+package property
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNotJSONObject is returned if a secret is not a JSON object.
+var ErrNotJSONObject = errors.New("secret value is not a JSON object")
+
+// Map returns the top level values of the JSON object data.
+func Map(data []byte) (map[string][]byte, error) {
+	object := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, ErrNotJSONObject
+	}
+	values := make(map[string][]byte, len(object))
+	for k, raw := range object {
+		value, err := decodeValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k, err)
+		}
+		values[k] = value
+	}
+	return values, nil
+}
+
+// Get returns the value at the given path of the JSON object data. The path
+// is a dot separated list of object keys or array indices, e.g.
+// "db.primary.password" or "hosts.0". Keys containing dots are matched
+// before the path is split at a dot.
+func Get(data []byte, path string) ([]byte, error) {
+	object := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, ErrNotJSONObject
+	}
+	raw, ok := lookup(object, path)
+	if !ok {
+		return nil, fmt.Errorf("property %q not found in secret", path)
+	}
+	return decodeValue(raw)
+}
+
+// lookup finds the value at path in the JSON object.
+func lookup(object map[string]json.RawMessage, path string) (json.RawMessage, bool) {
+	if raw, ok := object[path]; ok {
+		return raw, true
+	}
+	// prefer the longest key matching a prefix of the path
+	for i := strings.LastIndex(path, "."); i > 0; i = strings.LastIndex(path[:i], ".") {
+		raw, ok := object[path[:i]]
+		if !ok {
+			continue
+		}
+		if value, ok := lookupValue(raw, path[i+1:]); ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// lookupValue finds the value at path in the JSON value raw, which must be
+// an object or an array.
+func lookupValue(raw json.RawMessage, path string) (json.RawMessage, bool) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return nil, false
+	}
+	switch trimmed[0] {
+	case '{':
+		object := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(trimmed, &object); err != nil {
+			return nil, false
+		}
+		return lookup(object, path)
+	case '[':
+		var array []json.RawMessage
+		if err := json.Unmarshal(trimmed, &array); err != nil {
+			return nil, false
+		}
+		index, rest := path, ""
+		if i := strings.Index(path, "."); i >= 0 {
+			index, rest = path[:i], path[i+1:]
+		}
+		i, err := strconv.Atoi(index)
+		if err != nil || i < 0 || i >= len(array) {
+			return nil, false
+		}
+		if rest == "" {
+			return array[i], true
+		}
+		return lookupValue(array[i], rest)
+	default:
+		return nil, false
+	}
+}
+
+// decodeValue returns JSON strings unquoted and any other JSON value as
+// compact JSON.
+func decodeValue(raw json.RawMessage) ([]byte, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && trimmed[0] == '"' {
+		var str string
+		if err := json.Unmarshal(trimmed, &str); err != nil {
+			return nil, err
+		}
+		return []byte(str), nil
+	}
+	compacted := &bytes.Buffer{}
+	if err := json.Compact(compacted, trimmed); err != nil {
+		return nil, err
+	}
+	return compacted.Bytes(), nil
+}
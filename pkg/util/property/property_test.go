@@ -0,0 +1,93 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package property
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testSecret = `{
+	"username": "bob",
+	"port": 5432,
+	"ratio": 1.50,
+	"enabled": true,
+	"nothing": null,
+	"db": {"primary": {"password": "abc123", "hosts": ["a.example.com", "b.example.com"]}},
+	"db.primary": "dotted",
+	"tags": [{"name": "team"}]
+}`
+
+func TestGet(t *testing.T) {
+	tests := map[string]struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		"string":              {path: "username", want: "bob"},
+		"number":              {path: "port", want: "5432"},
+		"number formatting":   {path: "ratio", want: "1.50"},
+		"boolean":             {path: "enabled", want: "true"},
+		"null":                {path: "nothing", want: "null"},
+		"nested string":       {path: "db.primary.password", want: "abc123"},
+		"nested object":       {path: "db.primary.hosts", want: `["a.example.com","b.example.com"]`},
+		"array index":         {path: "db.primary.hosts.1", want: "b.example.com"},
+		"object in array":     {path: "tags.0.name", want: "team"},
+		"key containing dots": {path: "db.primary", want: "dotted"},
+		"missing":             {path: "db.secondary.password", wantErr: true},
+		"index out of range":  {path: "db.primary.hosts.2", wantErr: true},
+		"path into string":    {path: "username.first", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Get([]byte(testSecret), tc.path)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMap(t *testing.T) {
+	got, err := Map([]byte(`{"username": "bob", "port": 5432, "db": {"password": "abc123"}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string][]byte{
+		"username": []byte("bob"),
+		"port":     []byte("5432"),
+		"db":       []byte(`{"password":"abc123"}`),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	for _, notObject := range []string{"abc123", `"abc123"`, `["a"]`, "42"} {
+		if _, err := Map([]byte(notObject)); !errors.Is(err, ErrNotJSONObject) {
+			t.Errorf("expected ErrNotJSONObject for %q, got %v", notObject, err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package serviceaccount requests tokens for Kubernetes ServiceAccounts,
+// which are exchanged for credentials by providers trusting the cluster as an
+// OIDC identity provider.
+package serviceaccount
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+const (
+	// DefaultExpirationSeconds is the requested lifetime of ServiceAccount
+	// tokens.
+	DefaultExpirationSeconds = int64(600)
+
+	// requestTimeout bounds token requests to the Kubernetes API.
+	requestTimeout = 30 * time.Second
+)
+
+var (
+	sharedClient     typedcorev1.ServiceAccountsGetter
+	sharedClientLock sync.Mutex
+)
+
+// Client returns a client of the Kubernetes API the controller runs against,
+// used to request ServiceAccount tokens. The client is created on the first
+// successful call and shared by later calls.
+func Client() (typedcorev1.ServiceAccountsGetter, error) {
+	sharedClientLock.Lock()
+	defer sharedClientLock.Unlock()
+	if sharedClient != nil {
+		return sharedClient, nil
+	}
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading kubernetes client config: %w", err)
+	}
+	cfg.Timeout = requestTimeout
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+	sharedClient = clientset.CoreV1()
+	return sharedClient, nil
+}
+
+// Token requests a token with the given audiences for the ServiceAccount.
+func Token(ctx context.Context, client typedcorev1.ServiceAccountsGetter, namespace, name string, audiences []string) (string, error) {
+	expirationSeconds := DefaultExpirationSeconds
+	tokenRequest := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         audiences,
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}
+	resp, err := client.ServiceAccounts(namespace).CreateToken(ctx, name, tokenRequest, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("error requesting token for service account '%s/%s': %w", namespace, name, err)
+	}
+	return resp.Status.Token, nil
+}
@@ -0,0 +1,118 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serviceaccount
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestToken(t *testing.T) {
+	tests := map[string]struct {
+		namespace string
+		name      string
+		audiences []string
+		createErr error
+		want      string
+		wantErr   bool
+	}{
+		"single audience": {
+			namespace: "default",
+			name:      "app",
+			audiences: []string{"sts.amazonaws.com"},
+			want:      "token-default-app",
+		},
+		"multiple audiences": {
+			namespace: "team-a",
+			name:      "reader",
+			audiences: []string{"https://iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/pool/providers/k8s", "other"},
+			want:      "token-team-a-reader",
+		},
+		"missing service account": {
+			namespace: "default",
+			name:      "missing",
+			audiences: []string{"sts.amazonaws.com"},
+			wantErr:   true,
+		},
+		"request denied": {
+			namespace: "default",
+			name:      "app",
+			audiences: []string{"sts.amazonaws.com"},
+			createErr: fmt.Errorf("forbidden"),
+			wantErr:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}},
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "team-a"}},
+			)
+			var requested *authenticationv1.TokenRequest
+			clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				create, ok := action.(k8stesting.CreateAction)
+				if !ok || action.GetSubresource() != "token" {
+					return false, nil, nil
+				}
+				if tc.createErr != nil {
+					return true, nil, tc.createErr
+				}
+				saName := create.(k8stesting.CreateActionImpl).Name
+				if _, err := clientset.Tracker().Get(corev1.SchemeGroupVersion.WithResource("serviceaccounts"), action.GetNamespace(), saName); err != nil {
+					return true, nil, err
+				}
+				requested = create.GetObject().(*authenticationv1.TokenRequest).DeepCopy()
+				resp := requested.DeepCopy()
+				resp.Status.Token = fmt.Sprintf("token-%s-%s", action.GetNamespace(), saName)
+				return true, resp, nil
+			})
+
+			got, err := Token(context.Background(), clientset.CoreV1(), tc.namespace, tc.name, tc.audiences)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got token %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected token %q, got %q", tc.want, got)
+			}
+			if requested == nil {
+				t.Fatal("no token request was made")
+			}
+			if !reflect.DeepEqual(requested.Spec.Audiences, tc.audiences) {
+				t.Errorf("expected audiences %v, got %v", tc.audiences, requested.Spec.Audiences)
+			}
+			if requested.Spec.ExpirationSeconds == nil || *requested.Spec.ExpirationSeconds != DefaultExpirationSeconds {
+				t.Errorf("expected expiration of %d seconds, got %v", DefaultExpirationSeconds, requested.Spec.ExpirationSeconds)
+			}
+		})
+	}
+}
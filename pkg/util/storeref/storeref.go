@@ -0,0 +1,121 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package storeref resolves Kubernetes resources referenced by stores, such
+// as the Secrets holding their credentials.
+package storeref
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Namespace returns the namespace of a resource referenced by the store in
+// field. SecretStores reference resources of their own namespace, ignoring
+// namespace, while ClusterSecretStores must declare it.
+func Namespace(store smv1alpha1.GenericStore, field string, namespace *string) (string, error) {
+	if store.GetTypeMeta().Kind != smv1alpha1.ClusterSecretStoreKind {
+		return store.GetNamespace(), nil
+	}
+	if namespace == nil {
+		return "", fmt.Errorf("%s namespace required when cluster-scoped", field)
+	}
+	return *namespace, nil
+}
+
+// DefaultNamespace returns the namespace of a resource referenced by the
+// store like Namespace, but references of ClusterSecretStores without a
+// declared namespace resolve to namespace, the namespace of the
+// ExternalSecret.
+func DefaultNamespace(store smv1alpha1.GenericStore, declared *string, namespace string) string {
+	if store.GetTypeMeta().Kind != smv1alpha1.ClusterSecretStoreKind {
+		return store.GetNamespace()
+	}
+	if declared == nil {
+		return namespace
+	}
+	return *declared
+}
+
+// SecretKey returns the value of the key of a Secret referenced by the store
+// in field, with leading and trailing white space removed.
+func SecretKey(ctx context.Context, kube ctrlclient.Client, store smv1alpha1.GenericStore, field string, secretRef smmeta.SecretKeySelector) (string, error) {
+	namespace, err := Namespace(store, field, secretRef.Namespace)
+	if err != nil {
+		return "", err
+	}
+	return SecretKeyIn(ctx, kube, namespace, secretRef)
+}
+
+// SecretKeyIn returns the value of the key of the referenced Secret in
+// namespace, with leading and trailing white space removed. The namespace of
+// the reference is ignored.
+func SecretKeyIn(ctx context.Context, kube ctrlclient.Client, namespace string, secretRef smmeta.SecretKeySelector) (string, error) {
+	var secret corev1.Secret
+	ref := types.NamespacedName{
+		Namespace: namespace,
+		Name:      secretRef.Name,
+	}
+	if err := kube.Get(ctx, ref, &secret); err != nil {
+		return "", err
+	}
+	keyBytes, ok := secret.Data[secretRef.Key]
+	if !ok {
+		return "", fmt.Errorf("no data for %q in secret '%s/%s'", secretRef.Key, namespace, secretRef.Name)
+	}
+	return strings.TrimSpace(string(keyBytes)), nil
+}
+
+// CAProviderData returns the certificates of the Secret or ConfigMap of the
+// CA provider in namespace. The namespace of the CA provider is ignored.
+func CAProviderData(ctx context.Context, kube ctrlclient.Client, namespace string, caProvider *smv1alpha1.CAProvider) ([]byte, error) {
+	ref := types.NamespacedName{
+		Namespace: namespace,
+		Name:      caProvider.Name,
+	}
+	switch caProvider.Type {
+	case smv1alpha1.CAProviderTypeSecret:
+		var secret corev1.Secret
+		if err := kube.Get(ctx, ref, &secret); err != nil {
+			return nil, err
+		}
+		certs, ok := secret.Data[caProvider.Key]
+		if !ok {
+			return nil, fmt.Errorf("no data for %q in secret '%s/%s'", caProvider.Key, ref.Namespace, ref.Name)
+		}
+		return certs, nil
+	case smv1alpha1.CAProviderTypeConfigMap:
+		var configMap corev1.ConfigMap
+		if err := kube.Get(ctx, ref, &configMap); err != nil {
+			return nil, err
+		}
+		certs, ok := configMap.Data[caProvider.Key]
+		if !ok {
+			return nil, fmt.Errorf("no data for %q in configmap '%s/%s'", caProvider.Key, ref.Namespace, ref.Name)
+		}
+		return []byte(certs), nil
+	default:
+		return nil, fmt.Errorf("unknown CA provider type %q", caProvider.Type)
+	}
+}
@@ -0,0 +1,206 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storeref
+
+import (
+	"context"
+	"testing"
+
+	smmeta "github.com/itscontained/secret-manager/pkg/apis/meta/v1"
+	smv1alpha1 "github.com/itscontained/secret-manager/pkg/apis/secretmanager/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/kubernetes/scheme"
+
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSecretKey(t *testing.T) {
+	kube := fakeclient.NewFakeClientWithScheme(scheme.Scheme,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "app"},
+			Data:       map[string][]byte{"token": []byte("app-token\n")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "shared"},
+			Data:       map[string][]byte{"token": []byte("shared-token")},
+		},
+	)
+	secretStore := &smv1alpha1.SecretStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "store", Namespace: "app"},
+	}
+	clusterStore := &smv1alpha1.ClusterSecretStore{
+		TypeMeta:   metav1.TypeMeta{Kind: smv1alpha1.ClusterSecretStoreKind},
+		ObjectMeta: metav1.ObjectMeta{Name: "store"},
+	}
+	selector := func(key string, namespace *string) smmeta.SecretKeySelector {
+		return smmeta.SecretKeySelector{
+			LocalObjectReference: smmeta.LocalObjectReference{Name: "creds"},
+			Key:                  key,
+			Namespace:            namespace,
+		}
+	}
+
+	tests := map[string]struct {
+		store   smv1alpha1.GenericStore
+		ref     smmeta.SecretKeySelector
+		want    string
+		wantErr string
+	}{
+		"store namespace": {
+			store: secretStore,
+			ref:   selector("token", nil),
+			want:  "app-token",
+		},
+		"declared namespace ignored by namespaced store": {
+			store: secretStore,
+			ref:   selector("token", smmeta.String("shared")),
+			want:  "app-token",
+		},
+		"declared namespace of cluster store": {
+			store: clusterStore,
+			ref:   selector("token", smmeta.String("shared")),
+			want:  "shared-token",
+		},
+		"missing namespace of cluster store": {
+			store:   clusterStore,
+			ref:     selector("token", nil),
+			wantErr: "token namespace required when cluster-scoped",
+		},
+		"missing key": {
+			store:   secretStore,
+			ref:     selector("password", nil),
+			wantErr: `no data for "password" in secret 'app/creds'`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := SecretKey(context.Background(), kube, tc.store, "token", tc.ref)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDefaultNamespace(t *testing.T) {
+	secretStore := &smv1alpha1.SecretStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "store", Namespace: "app"},
+	}
+	clusterStore := &smv1alpha1.ClusterSecretStore{
+		TypeMeta:   metav1.TypeMeta{Kind: smv1alpha1.ClusterSecretStoreKind},
+		ObjectMeta: metav1.ObjectMeta{Name: "store"},
+	}
+
+	tests := map[string]struct {
+		store    smv1alpha1.GenericStore
+		declared *string
+		want     string
+	}{
+		"namespaced store": {
+			store: secretStore,
+			want:  "app",
+		},
+		"declared namespace ignored by namespaced store": {
+			store:    secretStore,
+			declared: smmeta.String("shared"),
+			want:     "app",
+		},
+		"declared namespace of cluster store": {
+			store:    clusterStore,
+			declared: smmeta.String("shared"),
+			want:     "shared",
+		},
+		"default namespace of cluster store": {
+			store: clusterStore,
+			want:  "external-secret",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := DefaultNamespace(tc.store, tc.declared, "external-secret"); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCAProviderData(t *testing.T) {
+	kube := fakeclient.NewFakeClientWithScheme(scheme.Scheme,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "app"},
+			Data:       map[string][]byte{"ca.crt": []byte("secret-ca")},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "app"},
+			Data:       map[string]string{"ca.crt": "configmap-ca"},
+		},
+	)
+
+	tests := map[string]struct {
+		caProvider smv1alpha1.CAProvider
+		want       string
+		wantErr    string
+	}{
+		"secret": {
+			caProvider: smv1alpha1.CAProvider{Type: smv1alpha1.CAProviderTypeSecret, Name: "ca", Key: "ca.crt"},
+			want:       "secret-ca",
+		},
+		"configmap": {
+			caProvider: smv1alpha1.CAProvider{Type: smv1alpha1.CAProviderTypeConfigMap, Name: "ca", Key: "ca.crt"},
+			want:       "configmap-ca",
+		},
+		"missing key": {
+			caProvider: smv1alpha1.CAProvider{Type: smv1alpha1.CAProviderTypeConfigMap, Name: "ca", Key: "tls.crt"},
+			wantErr:    `no data for "tls.crt" in configmap 'app/ca'`,
+		},
+		"unknown type": {
+			caProvider: smv1alpha1.CAProvider{Type: "Vault", Name: "ca", Key: "ca.crt"},
+			wantErr:    `unknown CA provider type "Vault"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := CAProviderData(context.Background(), kube, "app", &tc.caProvider)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}